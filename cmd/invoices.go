@@ -0,0 +1,59 @@
+// This file contains the commands for auditing the fiscal invoices.
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/spf13/cobra"
+)
+
+// InvoicesProcess represents the process of auditing the invoices chain.
+type InvoicesProcess struct {
+	Config config.Config
+	Logger logger.ILogger
+}
+
+// GetCmd returns the cobra command for the invoices operations.
+func (ip *InvoicesProcess) GetCmd() (*cobra.Command, error) {
+
+	cmd := &cobra.Command{
+		Use:   "invoices",
+		Short: "Fiscal invoices operations.",
+	}
+
+	verify_cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Walk the invoices hash chain and report gaps or altered records.",
+		Run: func(cmd *cobra.Command, args []string) {
+			invoice_svc := services.InvoiceService{
+				Logger: ip.Logger,
+				Config: ip.Config,
+			}
+
+			checked, issues, err := invoice_svc.VerifyChain()
+			if err != nil {
+				ip.Logger.Error(err.Error())
+				os.Exit(1)
+			}
+
+			for _, issue := range issues {
+				fmt.Printf("[%s] %s\n", issue.Type, issue.Message)
+			}
+
+			if len(issues) > 0 {
+				fmt.Printf("%d invoices checked, %d issues found\n", checked, len(issues))
+				os.Exit(1)
+			}
+
+			fmt.Printf("%d invoices checked, chain is valid\n", checked)
+		},
+	}
+
+	cmd.AddCommand(verify_cmd)
+
+	return cmd, nil
+}
@@ -389,6 +389,42 @@ func (root *RootProcess) Execute() error {
 
 	root.cmd.AddCommand(seedCmd)
 
+	invoicesService := InvoicesProcess{
+		Config: root.Config,
+		Logger: root.Logger,
+	}
+
+	invoicesCmd, err := invoicesService.GetCmd()
+	if err != nil {
+		return err
+	}
+
+	root.cmd.AddCommand(invoicesCmd)
+
+	salesService := SalesProcess{
+		Config: root.Config,
+		Logger: root.Logger,
+	}
+
+	salesCmd, err := salesService.GetCmd()
+	if err != nil {
+		return err
+	}
+
+	root.cmd.AddCommand(salesCmd)
+
+	traceabilityService := TraceabilityProcess{
+		Config: root.Config,
+		Logger: root.Logger,
+	}
+
+	traceabilityCmd, err := traceabilityService.GetCmd()
+	if err != nil {
+		return err
+	}
+
+	root.cmd.AddCommand(traceabilityCmd)
+
 	if err := root.cmd.Execute(); err != nil {
 		return err
 	}
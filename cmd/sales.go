@@ -0,0 +1,128 @@
+// This file contains the commands for maintaining the sales days.
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/spf13/cobra"
+)
+
+// SalesProcess represents the process of maintaining the sales days.
+type SalesProcess struct {
+	Config config.Config
+	Logger logger.ILogger
+}
+
+// GetCmd returns the cobra command for the sales operations.
+func (sp *SalesProcess) GetCmd() (*cobra.Command, error) {
+
+	cmd := &cobra.Command{
+		Use:   "sales",
+		Short: "Sales days operations.",
+	}
+
+	var dry_run bool
+
+	recompute_cmd := &cobra.Command{
+		Use:   "recompute-days",
+		Short: "Move the historical sales to their business day using the configured timezone and cutoff hour.",
+		Long:  "Move the historical sales to their business day using the configured timezone and cutoff hour. It should be run while no orders are being finished or refunded, an interrupted run is completed by running it again.",
+		Run: func(cmd *cobra.Command, args []string) {
+			sales_svc := services.SalesService{
+				Logger: sp.Logger,
+				Config: sp.Config,
+			}
+
+			if !dry_run {
+				sp.Logger.Warning("recomputing the business days, no orders should be finished or refunded until it is done")
+			}
+
+			result, err := sales_svc.RecomputeBusinessDays(dry_run)
+			if err != nil {
+				sp.Logger.Error(err.Error())
+				os.Exit(1)
+			}
+
+			fmt.Printf("%d business days, %d orders and %d refunds moved\n", result.Days, result.MovedOrders, result.MovedRefunds)
+			if result.Changed > 0 {
+				fmt.Printf("%d sales days got new sales while being rebuilt and were left as they were, run it again once no orders are being taken\n", result.Changed)
+			}
+			if dry_run {
+				fmt.Println("dry run, nothing was written")
+			}
+		},
+	}
+
+	recompute_cmd.Flags().BoolVar(&dry_run, "dry-run", false, "report the changes without writing them")
+
+	var from, to string
+	var rewrite bool
+
+	reconcile_cmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Compare the sales days with the sales rebuilt from the logs and orders, and optionally rewrite them.",
+		Long:  "Compare the sales days with the sales rebuilt from the logs and orders, and optionally rewrite them. Rewriting should be done while no orders are being finished or refunded.",
+		Run: func(cmd *cobra.Command, args []string) {
+			sales_svc := services.SalesService{
+				Logger: sp.Logger,
+				Config: sp.Config,
+			}
+
+			if to == "" {
+				to = from
+			}
+
+			result, err := sales_svc.ReconcileSalesDays(from, to, rewrite)
+			if err != nil {
+				sp.Logger.Error(err.Error())
+				os.Exit(1)
+			}
+
+			for _, day := range result.Discrepancies {
+				fmt.Printf("%s: %d documents, orders %d/%d, sales %.2f/%.2f, costs %.2f/%.2f, tips %.2f/%.2f, refunds %d/%d, refunds value %.2f/%.2f\n",
+					day.Date, day.Documents,
+					day.StoredOrders, day.ExpectedOrders,
+					day.StoredTotalSales, day.ExpectedTotalSales,
+					day.StoredCosts, day.ExpectedCosts,
+					day.StoredTips, day.ExpectedTips,
+					day.StoredRefunds, day.ExpectedRefunds,
+					day.StoredRefundsValue, day.ExpectedRefundsValue,
+				)
+
+				for _, list := range []struct {
+					title string
+					ids   []string
+				}{
+					{"missing orders", day.MissingOrders},
+					{"duplicate orders", day.DuplicateOrders},
+					{"unlogged orders", day.UnloggedOrders},
+					{"missing refunds", day.MissingRefunds},
+				} {
+					if len(list.ids) > 0 {
+						fmt.Printf("  %s: %s\n", list.title, strings.Join(list.ids, ", "))
+					}
+				}
+			}
+
+			fmt.Printf("%d business days checked, %d with discrepancies (stored/expected)\n", result.Days, len(result.Discrepancies))
+			if result.Rewritten {
+				fmt.Println("the days with discrepancies were rewritten")
+			}
+		},
+	}
+
+	reconcile_cmd.Flags().StringVar(&from, "from", "", "first business day (2006-01-02)")
+	reconcile_cmd.Flags().StringVar(&to, "to", "", "last business day (2006-01-02), defaults to from")
+	reconcile_cmd.Flags().BoolVar(&rewrite, "rewrite", false, "replace the days with discrepancies by their rebuilt version")
+	reconcile_cmd.MarkFlagRequired("from")
+
+	cmd.AddCommand(recompute_cmd)
+	cmd.AddCommand(reconcile_cmd)
+
+	return cmd, nil
+}
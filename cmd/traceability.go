@@ -0,0 +1,74 @@
+// This file contains the commands for tracing the material lots.
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/spf13/cobra"
+)
+
+// TraceabilityProcess represents the process of tracing the material lots.
+type TraceabilityProcess struct {
+	Config config.Config
+	Logger logger.ILogger
+}
+
+// GetCmd returns the cobra command for the traceability operations.
+func (tp *TraceabilityProcess) GetCmd() (*cobra.Command, error) {
+
+	var query models.TraceQuery
+
+	cmd := &cobra.Command{
+		Use:   "trace",
+		Short: "List the orders, products and customers that used a material entry, SKU or supplier lot.",
+		Run: func(cmd *cobra.Command, args []string) {
+			traceability_svc := services.TraceabilityService{
+				Logger: tp.Logger,
+				Config: tp.Config,
+			}
+
+			report, err := traceability_svc.TraceLot(query)
+			if err != nil {
+				tp.Logger.Error(err.Error())
+				os.Exit(1)
+			}
+
+			for _, entry := range report.Entries {
+				fmt.Printf("entry %s: %s, sku %s, lot %s, from %s, %.2f %s left", entry.EntryId, entry.MaterialName, entry.SKU, entry.LotNumber, entry.Company, entry.Quantity, entry.Unit)
+				if entry.SourceEntryId != "" {
+					fmt.Printf(", transferred from %s", entry.SourceEntryId)
+				}
+				fmt.Println()
+			}
+
+			for _, order := range report.Orders {
+				customer := order.Customer.Name
+				if order.Customer.Phone != "" {
+					customer = strings.TrimSpace(customer + " " + order.Customer.Phone)
+				}
+
+				fmt.Printf("order %s (%s) %s, %s, customer: %s, products: %s", order.OrderId, order.DisplayId, order.SubmittedAt.Format("2006-01-02 15:04"), order.State, customer, strings.Join(order.Products, ", "))
+				if order.Via == models.TraceViaReadyProduct {
+					fmt.Printf(", served from ready stock")
+				} else {
+					fmt.Printf(", %.2f used", order.Quantity)
+				}
+				fmt.Println()
+			}
+
+			fmt.Printf("%d entries, %d orders, %d products and %d customers traced\n", len(report.Entries), len(report.Orders), len(report.Products), len(report.Customers))
+		},
+	}
+
+	cmd.Flags().StringVar(&query.EntryId, "entry", "", "material entry id")
+	cmd.Flags().StringVar(&query.SKU, "sku", "", "material entry SKU")
+	cmd.Flags().StringVar(&query.LotNumber, "lot", "", "supplier lot number")
+
+	return cmd, nil
+}
@@ -44,6 +44,16 @@ type AuthConfig struct {
 	Enabled      bool   `mapstructure:"enabled" yaml:"enabled"`
 }
 
+// DigitalReceiptsConfig holds the configuration for the public digital receipt links
+type DigitalReceiptsConfig struct {
+	Enabled bool `mapstructure:"enabled" yaml:"enabled"`
+	// BaseURL is the public url of the backend used to build the receipt links, e.g. https://pos.example.com
+	BaseURL string `mapstructure:"base_url" yaml:"base_url"`
+	// Secret signs the receipt tokens, auth.jwt_secret is used when empty
+	Secret    string `mapstructure:"secret" yaml:"secret"`
+	ExpireHrs int    `mapstructure:"expire_hrs" yaml:"expire_hrs"`
+}
+
 // Config represents the overall configuration structure
 type Config struct {
 	Databases     []Database    `mapstructure:"databases" yaml:"databases"`
@@ -53,6 +63,11 @@ type Config struct {
 	TimeZone      string        `mapstructure:"timezone" yaml:"timezone"`
 	UploadsPath   string        `mapstructure:"uploads_path" yaml:"uploads_path"`
 	ServeFrontEnd bool          `mapstructure:"serve_frontend" yaml:"serve_frontend"`
+	// BusinessDayCutoffHour is the hour (0-23) in TimeZone at which the business day rolls over,
+	// sales made before it count towards the previous day
+	BusinessDayCutoffHour int `mapstructure:"business_day_cutoff_hour" yaml:"business_day_cutoff_hour"`
+	// DigitalReceipts configures the signed public receipt links
+	DigitalReceipts DigitalReceiptsConfig `mapstructure:"digital_receipts" yaml:"digital_receipts"`
 }
 
 // Database holds the configuration for database connections
@@ -6,6 +6,7 @@
 package core
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"time"
@@ -46,10 +47,28 @@ type Core struct {
 	NotificationSvc services.INotificationService
 }
 
-// OnStart is called when the core module is started.
+// OnStart is called when the core module is started, it creates the database indexes the services rely on.
 func (c *Core) OnStart() func() error {
 	return func() error {
-		return nil
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+
+		barcode_svc := services.BarcodeService{
+			Logger: c.Logger,
+			Config: c.Config,
+		}
+
+		err := barcode_svc.EnsureIndexes(ctx)
+		if err != nil {
+			return err
+		}
+
+		invoice_svc := services.InvoiceService{
+			Logger: c.Logger,
+			Config: c.Config,
+		}
+
+		return invoice_svc.EnsureIndexes(ctx)
 	}
 }
 
@@ -138,6 +157,12 @@ func (c *Core) RegisterBackgroundWorkers() []modules.Worker {
 				services.CheckExpirationDates(c.Logger, c.Config, c.NotificationSvc)
 			},
 		},
+		{
+			Interval: 1 * time.Hour,
+			Task: func() {
+				services.SendWasteDigest(c.Logger, c.Config, c.NotificationSvc)
+			},
+		},
 	}
 
 	return workers
@@ -184,18 +209,65 @@ func (c *Core) RegisterHttpHandlers(router *mux.Router, prefix string) {
 	router.Handle(prefix+"/api/customers/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetCustomer(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/customers", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetCustomers(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/customers", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.AddCustomer(c.Config, c.Logger), "admin", "cashier"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/suppliers/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetSupplier(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/suppliers/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.UpdateSupplier(c.Config, c.Logger), "admin"))).Methods("PATCH", "OPTIONS")
+	router.Handle(prefix+"/api/suppliers/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeleteSupplier(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
+	router.Handle(prefix+"/api/suppliers", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetSuppliers(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/suppliers", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.AddSupplier(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/purchaseorders/{id}/send", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.SendPurchaseOrder(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/purchaseorders/{id}/cancel", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.CancelPurchaseOrder(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/purchaseorders/{id}/receive", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.ReceivePurchaseOrder(c.Config, c.Logger), "admin", "chef"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/purchaseorders/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetPurchaseOrder(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/purchaseorders/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.UpdatePurchaseOrder(c.Config, c.Logger), "admin"))).Methods("PATCH", "OPTIONS")
+	router.Handle(prefix+"/api/purchaseorders/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeletePurchaseOrder(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
+	router.Handle(prefix+"/api/purchaseorders", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetPurchaseOrders(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/purchaseorders", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.AddPurchaseOrder(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/expiry", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetExpiryReport(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/reorder", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetReorderSuggestions(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/reorder/purchaseorders", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.CreateReorderPurchaseOrders(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/reorder/shoppinglist", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.ExportShoppingList(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/locations/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetLocation(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/locations/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.UpdateLocation(c.Config, c.Logger), "admin"))).Methods("PATCH", "OPTIONS")
+	router.Handle(prefix+"/api/locations/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeleteLocation(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
+	router.Handle(prefix+"/api/locations", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetLocations(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/locations", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.AddLocation(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/stocktransfers/intransit", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetInTransit(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/stocktransfers/{id}/receive", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.ReceiveStockTransfer(c.Config, c.Logger), "admin", "chef"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/stocktransfers/{id}/cancel", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.CancelStockTransfer(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/stocktransfers/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetStockTransfer(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/stocktransfers", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetStockTransfers(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/stocktransfers", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DispatchStockTransfer(c.Config, c.Logger), "admin", "chef"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/traceability", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetLotTrace(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/stocktakes/report", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetStockTakeReport(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/stocktakes/{id}/counts", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.RecordStockTakeCounts(c.Config, c.Logger), "admin", "chef"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/stocktakes/{id}/post", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.PostStockTake(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/stocktakes/{id}/cancel", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.CancelStockTake(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/stocktakes/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetStockTake(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/stocktakes/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeleteStockTake(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
+	router.Handle(prefix+"/api/stocktakes", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetStockTakes(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/stocktakes", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.AddStockTake(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/logs/salesperday", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetSalesPerDay(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/logs/salesperday/exportcsv", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.ExportSalesCSV(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/sales/analytics", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetSalesAnalytics(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/sales/export", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.ExportSales(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/sales/profitloss", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetProfitLoss(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/waste/analytics", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetWasteAnalytics(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/materials/barcode/{code}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetMaterialByBarcode(c.Config, c.Logger), "admin", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/scan", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.Scan(c.Config, c.Logger), "admin", "cashier", "chef"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/materials", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetMaterials(c.Config, c.Logger), "admin", "cashier", "chef"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/materials", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.AddMaterial(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/materials/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.EditMaterial(c.Config, c.Logger), "admin"))).Methods("PATCH", "OPTIONS")
 	router.Handle(prefix+"/api/materials/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeleteMaterial(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
 	router.Handle(prefix+"/api/materials/{id}/logs", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetMaterialLogs(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/materials/{id}/costs", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetMaterialCostHistory(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/materialcosts", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetMaterialCostTrends(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/materials/{material_id}/entries/{entry_id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeleteEntry(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
 	router.Handle(prefix+"/api/materials/{material_id}/entries/{entry_id}/cost", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.CalculateMaterialExactCost(c.Config, c.Logger), "admin", "cashier", "chef"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/materials/{id}/entries", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.PushMaterialEntry(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/materials/{id}/entries/{entry_id}/label", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.PrintMaterialEntryLabel(c.Config, c.Logger), "admin", "chef"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/materials/{material_id}/entries", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetMaterialEntries(c.Config, c.Logger), "admin", "cashier"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/materials/{material_id}/avgcost", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.CalculateMaterialAverageCost(c.Config, c.Logger), "admin", "cashier"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/units", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetUnits(c.Config, c.Logger), "admin", "cashier", "chef"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/categories", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetCategories(c.Config, c.Logger), "admin", "cashier"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/categories", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.InsertCategory(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/categories/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeleteCategory(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
@@ -211,19 +283,42 @@ func (c *Core) RegisterHttpHandlers(router *mux.Router, prefix string) {
 	router.Handle(prefix+"/api/orders/{id}/pay", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.Payorder(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/orders/{id}/printkitchenreceipt", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.PrintKitchenReceipt(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/orders/{id}/printclientreceipt", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.PrintClientReceipt(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/orders/{id}/reprintclientreceipt", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.ReprintOrderReceipt(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/orders/{id}/receipts", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetOrderReceipts(c.Config, c.Logger), "admin", "cashier"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/orders/{id}/invoice.pdf", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetOrderInvoicePDF(c.Config, c.Logger), "admin", "cashier"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/orders/{id}/digitalreceipt", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetDigitalReceiptLink(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/public/receipts/{token}", core_middlewares.AllowCors(handlers.GetPublicReceipt(c.Config, c.Logger, c.Settings))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/receipts/{id}/reprint", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.ReprintReceipt(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/orders/{order_id}/addtips", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.OrderAddTip(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("PATCH", "OPTIONS")
 	router.Handle(prefix+"/api/orders/{order_id}/removetips", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.OrderRemoveTip(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("PATCH", "OPTIONS")
 	router.Handle(prefix+"/api/orders/{order_id}/items/{item_id}/refund", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.RefundOrderItem(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/orders/{order_id}/items/{item_id}/waste", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.WasteOrderItem(c.Config, c.Logger, c.Settings), "admin", "cashier"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/orders/{id}/customdata", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.UpdateOrderCustomData(c.Config, c.Logger), "admin", "cashier"))).Methods("PATCH", "OPTIONS")
+	router.Handle(prefix+"/api/products/barcode/{code}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetProductByBarcode(c.Config, c.Logger), "admin", "cashier", "chef"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/products/availability", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetRecipeAvailability(c.Config, c.Logger), "admin", "chef", "cashier"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/products/{id}/recipetree", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetRecipeTree(c.Config, c.Logger), "admin", "cashier", "chef"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/products/{id}/label", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.PrintProductLabel(c.Config, c.Logger), "admin", "chef"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/products/{id}/image", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.UpdateProductImage(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/products/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetProduct(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/products/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeleteProduct(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
 	router.Handle(prefix+"/api/products/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.UpdateProduct(c.Config, c.Logger), "admin"))).Methods("PATCH", "OPTIONS")
 	router.Handle(prefix+"/api/products", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetProducts(c.Config, c.Logger), "admin", "cashier", "chef"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/products", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.InesrtNewProduct(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/cashdrawer/open", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.OpenCashDrawer(c.Config, c.Logger), "admin", "cashier"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/cashdrawer/openings", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetCashDrawerOpenings(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/labeltemplates", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetLabelTemplates(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/labeltemplates", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.InsertLabelTemplate(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/labeltemplates/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetLabelTemplate(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/labeltemplates/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.UpdateLabelTemplate(c.Config, c.Logger), "admin"))).Methods("PATCH", "OPTIONS")
+	router.Handle(prefix+"/api/labeltemplates/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeleteLabelTemplate(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
+	router.Handle(prefix+"/api/receipttemplates", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetReceiptTemplates(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/receipttemplates", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.InsertReceiptTemplate(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/receipttemplates/preview", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.PreviewReceiptTemplate(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
+	router.Handle(prefix+"/api/receipttemplates/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetReceiptTemplate(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/receipttemplates/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.UpdateReceiptTemplate(c.Config, c.Logger), "admin"))).Methods("PATCH", "OPTIONS")
+	router.Handle(prefix+"/api/receipttemplates/{id}", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.DeleteReceiptTemplate(c.Config, c.Logger), "admin"))).Methods("DELETE", "OPTIONS")
+	router.Handle(prefix+"/api/receipttemplates/{id}/preview", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.PreviewReceiptTemplate(c.Config, c.Logger), "admin"))).Methods("GET", "OPTIONS")
+	router.Handle(prefix+"/api/receipttemplates/{id}/versions/{version}/activate", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.ActivateReceiptTemplateVersion(c.Config, c.Logger), "admin"))).Methods("POST", "OPTIONS")
 	router.Handle(prefix+"/api/settings", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.GetSettings(c.Config, c.Logger), "admin", "cashier", "chef"))).Methods("GET", "OPTIONS")
 	router.Handle(prefix+"/api/settings", core_middlewares.AllowCors(auth_svc.AllowAnyOfRoles(handlers.UpdateSettings(c.Config, c.Logger), "admin"))).Methods("PATCH", "OPTIONS")
 	router.Handle(prefix+"/api/languages", core_middlewares.AllowCors(handlers.GetAvailableLanguages(c.Config, c.Logger))).Methods("GET", "OPTIONS")
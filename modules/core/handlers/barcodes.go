@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// barcodeError writes the error of a barcode lookup with its matching status code.
+func barcodeError(w http.ResponseWriter, logger logger.ILogger, err error) {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		http.Error(w, "no product or material has this barcode", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidBarcode):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// GetProductByBarcode returns a HTTP handler function to find the product with an entry carrying the barcode.
+// The optional format query string sets the barcode symbology, it is recognized from the code otherwise.
+func GetProductByBarcode(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		barcode, err := services.ValidateBarcode(params["code"], r.URL.Query().Get("format"))
+		if err != nil {
+			barcodeError(w, logger, err)
+			return
+		}
+
+		barcode_svc := services.BarcodeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		product, entry, err := barcode_svc.LookupProduct(barcode)
+		if err != nil {
+			barcodeError(w, logger, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: models.ScanResult{
+			Barcode:      barcode,
+			Found:        true,
+			Product:      &product,
+			ProductEntry: &entry,
+		}}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetMaterialByBarcode returns a HTTP handler function to find the material whose entries, or supplier, carry
+// the barcode. The optional format query string sets the barcode symbology, it is recognized from the code otherwise.
+func GetMaterialByBarcode(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		barcode, err := services.ValidateBarcode(params["code"], r.URL.Query().Get("format"))
+		if err != nil {
+			barcodeError(w, logger, err)
+			return
+		}
+
+		barcode_svc := services.BarcodeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		material, supplier, supplier_material, err := barcode_svc.LookupMaterial(barcode)
+		if err != nil {
+			barcodeError(w, logger, err)
+			return
+		}
+
+		result := models.ScanResult{
+			Barcode:  barcode,
+			Found:    true,
+			Material: &material,
+		}
+
+		if supplier_material.MaterialId != "" {
+			result.SupplierId = supplier.Id
+			result.SupplierName = supplier.Name
+			result.SupplierMaterial = &supplier_material
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: result}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// Scan returns a HTTP handler function resolving a scanned code, the code query string, to a product to add to
+// an order or a material to receive. The mode query string is order or receive and defaults to the shop mode,
+// the format one sets the barcode symbology. Codes nothing carries are answered with found set to false.
+func Scan(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		settings_svc := services.SettingsService{
+			Config: config,
+		}
+
+		settings, err := settings_svc.GetSettings()
+		if err != nil {
+			barcodeError(w, logger, err)
+			return
+		}
+
+		barcode_svc := services.BarcodeService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		result, err := barcode_svc.Scan(r.URL.Query().Get("code"), r.URL.Query().Get("format"), r.URL.Query().Get("mode"))
+		if err != nil {
+			barcodeError(w, logger, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: result}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/zitadel/oidc/v3/pkg/oidc"
+)
+
+// OpenCashDrawer returns a HTTP handler function to open the cash drawer without a sale, the opening is audited.
+func OpenCashDrawer(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		settings_svc := services.SettingsService{
+			Config: config,
+		}
+
+		settings, err := settings_svc.GetSettings()
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		drawer_svc := services.CashDrawerService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		err = drawer_svc.OpenDrawer(user_id)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// GetCashDrawerOpenings returns a HTTP handler function to list the audited cash drawer openings.
+func GetCashDrawerOpenings(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		page_number, err := strconv.Atoi(r.URL.Query().Get("page[number]"))
+		if err != nil || page_number == 0 {
+			page_number = 1
+		}
+
+		page_size, err := strconv.Atoi(r.URL.Query().Get("page[size]"))
+		if err != nil {
+			page_size = 50
+		}
+
+		drawer_svc := services.CashDrawerService{
+			Logger: logger,
+			Config: config,
+		}
+
+		openings, total_records, err := drawer_svc.GetOpenings(page_number, page_size)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: openings,
+			Meta: JSONAPIMeta{
+				TotalRecords: int(total_records),
+				PageNumber:   page_number,
+				PageSize:     page_size,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/services"
+)
+
+// GetExpiryReport returns a HTTP handler function to retrieve the material entries with stock that expired or
+// expire within the warning days of their material. The optional days query string overrides the warning days.
+func GetExpiryReport(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+
+		settings_svc := services.SettingsService{
+			Config: config,
+		}
+
+		settings, err := settings_svc.GetSettings()
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		expiry_svc := services.ExpiryService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		report, err := expiry_svc.GetExpiryReport(days)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: report,
+			Meta: JSONAPIMeta{
+				TotalRecords: len(report.Entries),
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
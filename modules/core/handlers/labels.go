@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// labelService returns a label service with the current settings, labels are configured at runtime.
+func labelService(config config.Config, logger logger.ILogger) (services.LabelService, error) {
+	settings_svc := services.SettingsService{
+		Config: config,
+	}
+
+	settings, err := settings_svc.GetSettings()
+
+	return services.LabelService{
+		Logger:   logger,
+		Config:   config,
+		Settings: settings,
+	}, err
+}
+
+// GetLabelTemplates returns a HTTP handler function to retrieve a list of label templates.
+func GetLabelTemplates(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		page_number, err := strconv.Atoi(r.URL.Query().Get("page[number]"))
+		if err != nil || page_number == 0 {
+			page_number = 1
+		}
+
+		page_size, err := strconv.Atoi(r.URL.Query().Get("page[size]"))
+		if err != nil {
+			page_size = 50
+		}
+
+		label_svc := services.LabelService{
+			Logger: logger,
+			Config: config,
+		}
+
+		templates, total_records, err := label_svc.GetTemplates(page_number, page_size)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: templates,
+			Meta: JSONAPIMeta{
+				TotalRecords: int(total_records),
+				PageNumber:   page_number,
+				PageSize:     page_size,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetLabelTemplate returns a HTTP handler function to retrieve a label template.
+func GetLabelTemplate(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		label_svc := services.LabelService{
+			Logger: logger,
+			Config: config,
+		}
+
+		template, err := label_svc.GetTemplate(id_param)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: template,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// InsertLabelTemplate returns a HTTP handler function to add a new label template.
+func InsertLabelTemplate(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		request := struct {
+			Data models.LabelTemplate `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		label_svc := services.LabelService{
+			Logger: logger,
+			Config: config,
+		}
+
+		template, err := label_svc.InsertTemplate(request.Data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: template,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// UpdateLabelTemplate returns a HTTP handler function to update a label template.
+func UpdateLabelTemplate(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		request := struct {
+			Data models.LabelTemplate `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		label_svc := services.LabelService{
+			Logger: logger,
+			Config: config,
+		}
+
+		template, err := label_svc.UpdateTemplate(id_param, request.Data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: template,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// DeleteLabelTemplate returns a HTTP handler function to delete a label template.
+func DeleteLabelTemplate(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		label_svc, err := labelService(config, logger)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		err = label_svc.DeleteTemplate(id_param)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// PrintMaterialEntryLabel returns a HTTP handler function to print the expiry label of a material entry.
+func PrintMaterialEntryLabel(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		material_id := params["id"]
+		entry_id := params["entry_id"]
+
+		request := struct {
+			Data struct {
+				Copies int `json:"copies"`
+			} `json:"data"`
+		}{}
+
+		if r.ContentLength > 0 {
+			err := json.NewDecoder(r.Body).Decode(&request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		label_svc, err := labelService(config, logger)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		label, err := label_svc.MaterialEntryLabel(material_id, entry_id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		err = label_svc.PrintLabel(label, request.Data.Copies)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+// PrintProductLabel returns a HTTP handler function to print prep labels for ready stock of a product.
+func PrintProductLabel(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		product_id := params["id"]
+
+		request := struct {
+			Data struct {
+				Quantity float64 `json:"quantity"`
+				Copies   int     `json:"copies"`
+			} `json:"data"`
+		}{}
+
+		if r.ContentLength > 0 {
+			err := json.NewDecoder(r.Body).Decode(&request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		label_svc, err := labelService(config, logger)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		product_svc := services.RecipeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		product, err := product_svc.GetProduct(product_id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		label := label_svc.ProductLabel(product, request.Data.Quantity, primitive.NewObjectID().Hex())
+
+		err = label_svc.PrintLabel(label, request.Data.Copies)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}
+}
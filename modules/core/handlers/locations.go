@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// locationError writes the error of a location operation with its matching status code.
+func locationError(w http.ResponseWriter, logger logger.ILogger, err error) {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		http.Error(w, "location not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidLocation):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeLocation writes the location as the response.
+func writeLocation(w http.ResponseWriter, status int, location models.Location) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: location}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetLocations returns a HTTP handler function to list the locations holding stock, the default one first.
+func GetLocations(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		location_svc := services.LocationService{
+			Logger: logger,
+			Config: config,
+		}
+
+		locations, err := location_svc.GetLocations()
+		if err != nil {
+			locationError(w, logger, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: locations}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func GetLocation(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		location_svc := services.LocationService{
+			Logger: logger,
+			Config: config,
+		}
+
+		location, err := location_svc.GetLocation(params["id"])
+		if err != nil {
+			locationError(w, logger, err)
+			return
+		}
+
+		writeLocation(w, http.StatusOK, location)
+	}
+}
+
+// AddLocation returns a HTTP handler function to add a location, the first one added is the default location.
+func AddLocation(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		request := struct {
+			Data models.Location `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		location_svc := services.LocationService{
+			Logger: logger,
+			Config: config,
+		}
+
+		location, err := location_svc.CreateLocation(request.Data)
+		if err != nil {
+			locationError(w, logger, err)
+			return
+		}
+
+		writeLocation(w, http.StatusCreated, location)
+	}
+}
+
+// UpdateLocation returns a HTTP handler function to rename a location or make it the default one.
+func UpdateLocation(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		request := struct {
+			Data models.Location `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		location_svc := services.LocationService{
+			Logger: logger,
+			Config: config,
+		}
+
+		location, err := location_svc.UpdateLocation(params["id"], request.Data)
+		if err != nil {
+			locationError(w, logger, err)
+			return
+		}
+
+		writeLocation(w, http.StatusOK, location)
+	}
+}
+
+// DeleteLocation returns a HTTP handler function to delete a location holding no stock.
+func DeleteLocation(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		location_svc := services.LocationService{
+			Logger: logger,
+			Config: config,
+		}
+
+		err := location_svc.DeleteLocation(params["id"])
+		if err != nil {
+			locationError(w, logger, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/services"
+)
+
+// GetMaterialCostHistory returns a HTTP handler function to retrieve the unit cost history of a material.
+//
+// The from and to query strings are dates (2006-01-02) or RFC3339 times and default to the last 7 business days,
+// the change is measured against the period of the same length before from. The optional supplier query string,
+// a supplier id or company name, limits the history to its purchases.
+func GetMaterialCostHistory(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+
+		from, to, err := salesDateRange(r, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		material_svc := services.MaterialService{
+			Logger: logger,
+			Config: config,
+		}
+
+		trend, err := material_svc.GetMaterialCostHistory(params["id"], from, to, r.URL.Query().Get("supplier"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: trend,
+		}
+
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
+
+// GetMaterialCostTrends returns a HTTP handler function to retrieve the unit cost change of every purchased material,
+// along with the cheapest recent supplier of each, the largest increases first.
+func GetMaterialCostTrends(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		from, to, err := salesDateRange(r, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		material_svc := services.MaterialService{
+			Logger: logger,
+			Config: config,
+		}
+
+		trends, err := material_svc.GetMaterialCostTrends(from, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: trends,
+			Meta: JSONAPIMeta{
+				TotalRecords: len(trends),
+			},
+		}
+
+		jsonResponse, err := json.Marshal(response)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(jsonResponse)
+	}
+}
@@ -98,7 +98,7 @@ func CalculateMaterialAverageCost(config config.Config, logger logger.ILogger) h
 			Config: config,
 		}
 
-		cost, err := materialService.CalculateMaterialAverageCost(material_id_param, quantity)
+		cost, err := materialService.CalculateMaterialAverageCost(material_id_param, quantity, r.URL.Query().Get("unit"))
 
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -151,7 +151,7 @@ func CalculateMaterialExactCost(config config.Config, logger logger.ILogger) htt
 			Config: config,
 		}
 
-		cost, err := materialService.CalculateMaterialExactCost(entry_id_param, material_id_param, quantity)
+		cost, err := materialService.CalculateMaterialExactCost(entry_id_param, material_id_param, quantity, r.URL.Query().Get("unit"))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -368,7 +368,7 @@ func PushMaterialEntry(config config.Config, logger logger.ILogger) http.Handler
 			Config: config,
 		}
 
-		err = materialService.PushMaterialEntry(material_id, request.Data, user_id)
+		_, err = materialService.PushMaterialEntry(material_id, request.Data, user_id)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -15,7 +15,6 @@ import (
 	"log"
 	"math"
 	"net/http"
-	"os"
 	"strconv"
 	"strings"
 
@@ -283,13 +282,13 @@ func PrintKitchenReceipt(config config.Config, logger logger.ILogger, settings m
 			}
 		}
 
-		pwd, err := os.Getwd()
-		if err != nil {
-			logger.Error(err.Error())
-			return
+		tickets_svc := services.KitchenTicketService{
+			Config:   config,
+			Logger:   logger,
+			Settings: settings,
 		}
 
-		err = orderService.PrintReceipt(order, pwd+"/assets/core/templates/kitchen_receipt_0.handlebars", lang, settings.KitchenReceiptPrinter.Host)
+		err = tickets_svc.PrintTickets(order, lang)
 		if err != nil {
 			logger.Error(err.Error())
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -343,13 +342,31 @@ func PrintClientReceipt(config config.Config, logger logger.ILogger, settings mo
 			}
 		}
 
-		pwd, err := os.Getwd()
+		template_svc := services.ReceiptTemplateService{
+			Config:   config,
+			Logger:   logger,
+			Settings: settings,
+		}
+
+		template, err := template_svc.GetPrinterTemplate(settings.ClientReceiptPrinter, services.DefaultClientReceiptTemplateFile)
 		if err != nil {
 			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		err = orderService.PrintReceipt(order, pwd+"/assets/core/templates/order_receipt_0.handlebars", lang, settings.ClientReceiptPrinter.Host)
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		archive_svc := services.ReceiptArchiveService{
+			Config:   config,
+			Logger:   logger,
+			Settings: settings,
+		}
+
+		_, err = archive_svc.PrintClientReceipt(order, lang, template, settings.ClientReceiptPrinter.Host, user_id)
 		if err != nil {
 			logger.Error(err.Error())
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -397,13 +414,52 @@ func Payorder(config config.Config, logger logger.ILogger, settings models.Setti
 			Config: config,
 		}
 
-		err := orderService.PayUnpaidOrder(id_param)
+		paid, err := orderService.PayUnpaidOrder(id_param)
 		if err != nil {
 			logger.Error(err.Error())
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		// the drawer only opens for the payment, not for paying an order again
+		if !paid {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		go func() {
+			settings_svc := services.SettingsService{
+				Config: config,
+			}
+
+			settings, err := settings_svc.GetSettings()
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+
+			order, err := orderService.GetOrder(id_param)
+			if err != nil {
+				logger.Error(err.Error())
+				return
+			}
+
+			drawer_svc := services.CashDrawerService{
+				Config:   config,
+				Logger:   logger,
+				Settings: settings,
+			}
+
+			if err := drawer_svc.KickForOrder(order, user_id); err != nil {
+				logger.Error(err.Error())
+			}
+		}()
+
 		w.WriteHeader(http.StatusNoContent)
 	}
 }
@@ -611,14 +667,25 @@ func SubmitOrder(config config.Config, logger logger.ILogger, settings models.Se
 			return
 		}
 
-		receipt_svc := services.ReceiptService{
-			Config:   config,
-			Logger:   logger,
-			Settings: settings,
+		submitter_id := "0"
+		if config.Zitadel.Enabled {
+			submitter_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
 		}
 
 		go func() {
 
+			if order.State != "stashed" {
+				drawer_svc := services.CashDrawerService{
+					Config:   config,
+					Logger:   logger,
+					Settings: settings,
+				}
+
+				if err := drawer_svc.KickForOrder(order, submitter_id); err != nil {
+					logger.Error(err.Error())
+				}
+			}
+
 			lang_svc := services.LanguageService{
 				Config:   config,
 				Logger:   logger,
@@ -644,14 +711,23 @@ func SubmitOrder(config config.Config, logger logger.ILogger, settings models.Se
 				}
 			}
 
-			pwd, err := os.Getwd()
-			if err != nil {
-				logger.Error(err.Error())
-				return
+			template_svc := services.ReceiptTemplateService{
+				Config:   config,
+				Logger:   logger,
+				Settings: settings,
 			}
 
 			if !order.IsPayLater && request.Meta.IsPrintClientReceipt {
-				err = receipt_svc.Print(order, order.Discount, 0, order.SubmittedAt, lang, pwd+"/assets/core/templates/order_receipt_0.handlebars", settings.ClientReceiptPrinter.Host, settings.ShopMode)
+				archive_svc := services.ReceiptArchiveService{
+					Config:   config,
+					Logger:   logger,
+					Settings: settings,
+				}
+
+				template, err := template_svc.GetPrinterTemplate(settings.ClientReceiptPrinter, services.DefaultClientReceiptTemplateFile)
+				if err == nil {
+					_, err = archive_svc.PrintClientReceipt(order, lang, template, settings.ClientReceiptPrinter.Host, submitter_id)
+				}
 				if err != nil {
 					logger.Error(err.Error())
 
@@ -662,7 +738,13 @@ func SubmitOrder(config config.Config, logger logger.ILogger, settings models.Se
 			}
 
 			if request.Meta.IsPrintKitchenReceipt {
-				err = receipt_svc.Print(order, order.Discount, 0, order.SubmittedAt, lang, pwd+"/assets/core/templates/kitchen_receipt_0.handlebars", settings.KitchenReceiptPrinter.Host, settings.ShopMode)
+				tickets_svc := services.KitchenTicketService{
+					Config:   config,
+					Logger:   logger,
+					Settings: settings,
+				}
+
+				err := tickets_svc.PrintTickets(order, lang)
 				if err != nil {
 					logger.Error(err.Error())
 					return
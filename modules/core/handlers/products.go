@@ -357,7 +357,7 @@ func GetRecipeTree(config config.Config, logger logger.ILogger) http.HandlerFunc
 }
 
 // GetRecipeAvailability returns a HTTP handler function to check the availability of multiple recipes.
-// The recipe IDs are required as query string, comma separated.
+// The recipe IDs are required as query string, comma separated, location_id limits it to the stock of a location.
 func GetRecipeAvailability(config config.Config, logger logger.ILogger) http.HandlerFunc {
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -375,7 +375,7 @@ func GetRecipeAvailability(config config.Config, logger logger.ILogger) http.Han
 			Config: config,
 		}
 
-		availabilities, err := recipeService.CheckRecipesAvailability(ids)
+		availabilities, err := recipeService.CheckRecipesAvailability(ids, r.URL.Query().Get("location_id"))
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -0,0 +1,286 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/zitadel/oidc/v3/pkg/oidc"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// purchaseOrderError writes the error of a purchase order operation with its matching status code.
+func purchaseOrderError(w http.ResponseWriter, logger logger.ILogger, err error) {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		http.Error(w, "purchase order not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidPurchaseOrder), errors.Is(err, services.ErrIncompatibleUnits):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrInvalidPurchaseOrderState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writePurchaseOrder writes the purchase order as the response.
+func writePurchaseOrder(w http.ResponseWriter, status int, purchase_order models.PurchaseOrder) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: purchase_order}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetPurchaseOrders returns a HTTP handler function to list the purchase orders, newest first.
+// It accepts the state and supplier_id query string parameters as filters.
+func GetPurchaseOrders(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := services.GetPurchaseOrdersParams{
+			State:      r.URL.Query().Get("state"),
+			SupplierId: r.URL.Query().Get("supplier_id"),
+		}
+
+		page_number, err := strconv.Atoi(r.URL.Query().Get("page[number]"))
+		if err != nil || page_number == 0 {
+			params.PageNumber = 1
+		} else {
+			params.PageNumber = page_number
+		}
+
+		page_size, err := strconv.Atoi(r.URL.Query().Get("page[size]"))
+		if err != nil {
+			params.PageSize = 50
+		} else {
+			params.PageSize = page_size
+		}
+
+		purchase_order_svc := services.PurchaseOrderService{
+			Logger: logger,
+			Config: config,
+		}
+
+		purchase_orders, total_records, err := purchase_order_svc.GetPurchaseOrders(params)
+		if err != nil {
+			purchaseOrderError(w, logger, err)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: purchase_orders,
+			Meta: JSONAPIMeta{
+				TotalRecords: total_records,
+				PageNumber:   params.PageNumber,
+				PageSize:     params.PageSize,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func GetPurchaseOrder(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		purchase_order_svc := services.PurchaseOrderService{
+			Logger: logger,
+			Config: config,
+		}
+
+		purchase_order, err := purchase_order_svc.GetPurchaseOrder(params["id"])
+		if err != nil {
+			purchaseOrderError(w, logger, err)
+			return
+		}
+
+		writePurchaseOrder(w, http.StatusOK, purchase_order)
+	}
+}
+
+// AddPurchaseOrder returns a HTTP handler function to create a draft purchase order.
+func AddPurchaseOrder(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		request := struct {
+			Data models.PurchaseOrder `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		purchase_order_svc := services.PurchaseOrderService{
+			Logger: logger,
+			Config: config,
+		}
+
+		purchase_order, err := purchase_order_svc.CreatePurchaseOrder(request.Data, user_id)
+		if err != nil {
+			purchaseOrderError(w, logger, err)
+			return
+		}
+
+		writePurchaseOrder(w, http.StatusCreated, purchase_order)
+	}
+}
+
+// UpdatePurchaseOrder returns a HTTP handler function to edit a draft purchase order.
+func UpdatePurchaseOrder(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		request := struct {
+			Data models.PurchaseOrder `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		purchase_order_svc := services.PurchaseOrderService{
+			Logger: logger,
+			Config: config,
+		}
+
+		purchase_order, err := purchase_order_svc.UpdatePurchaseOrder(params["id"], request.Data)
+		if err != nil {
+			purchaseOrderError(w, logger, err)
+			return
+		}
+
+		writePurchaseOrder(w, http.StatusOK, purchase_order)
+	}
+}
+
+func DeletePurchaseOrder(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		purchase_order_svc := services.PurchaseOrderService{
+			Logger: logger,
+			Config: config,
+		}
+
+		err := purchase_order_svc.DeletePurchaseOrder(params["id"])
+		if err != nil {
+			purchaseOrderError(w, logger, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// SendPurchaseOrder returns a HTTP handler function to mark a draft purchase order as sent to its supplier.
+func SendPurchaseOrder(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		purchase_order_svc := services.PurchaseOrderService{
+			Logger: logger,
+			Config: config,
+		}
+
+		purchase_order, err := purchase_order_svc.SendPurchaseOrder(params["id"])
+		if err != nil {
+			purchaseOrderError(w, logger, err)
+			return
+		}
+
+		writePurchaseOrder(w, http.StatusOK, purchase_order)
+	}
+}
+
+func CancelPurchaseOrder(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		purchase_order_svc := services.PurchaseOrderService{
+			Logger: logger,
+			Config: config,
+		}
+
+		purchase_order, err := purchase_order_svc.CancelPurchaseOrder(params["id"])
+		if err != nil {
+			purchaseOrderError(w, logger, err)
+			return
+		}
+
+		writePurchaseOrder(w, http.StatusOK, purchase_order)
+	}
+}
+
+// ReceivePurchaseOrder returns a HTTP handler function to receive goods against a purchase order. The request
+// data holds the received lines and close, which closes the purchase order even if lines were received short.
+func ReceivePurchaseOrder(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		params := mux.Vars(r)
+
+		request := struct {
+			Data struct {
+				Lines []models.PurchaseOrderReceiptLine `json:"lines"`
+				Close bool                              `json:"close"`
+			} `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		settings_svc := services.SettingsService{
+			Config: config,
+		}
+
+		settings, err := settings_svc.GetSettings()
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		purchase_order_svc := services.PurchaseOrderService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		purchase_order, err := purchase_order_svc.ReceivePurchaseOrder(params["id"], request.Data.Lines, request.Data.Close, user_id)
+		if err != nil {
+			purchaseOrderError(w, logger, err)
+			return
+		}
+
+		writePurchaseOrder(w, http.StatusOK, purchase_order)
+	}
+}
@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/zitadel/oidc/v3/pkg/oidc"
+)
+
+// requestLanguage returns the first language code of the Accept-Language header that has a language pack, defaults to "en".
+func requestLanguage(r *http.Request, lang_svc services.LanguageService) string {
+	lang := "en"
+
+	acceptLanguage := r.Header.Get("Accept-Language")
+	if acceptLanguage == "" {
+		return lang
+	}
+
+	for _, l := range strings.Split(acceptLanguage, ",") {
+		code := strings.TrimSpace(strings.Split(l, ";")[0])
+		code = strings.ToLower(strings.Split(code, "-")[0])
+		if _, err := lang_svc.GetLanguage(code); err == nil {
+			return code
+		}
+	}
+
+	return lang
+}
+
+// GetReceiptTemplates returns a HTTP handler function to retrieve a list of receipt templates.
+func GetReceiptTemplates(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		page_number, err := strconv.Atoi(r.URL.Query().Get("page[number]"))
+		if err != nil || page_number == 0 {
+			page_number = 1
+		}
+
+		page_size, err := strconv.Atoi(r.URL.Query().Get("page[size]"))
+		if err != nil {
+			page_size = 50
+		}
+
+		template_svc := services.ReceiptTemplateService{
+			Logger: logger,
+			Config: config,
+		}
+
+		templates, total_records, err := template_svc.GetTemplates(page_number, page_size)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: templates,
+			Meta: JSONAPIMeta{
+				TotalRecords: int(total_records),
+				PageNumber:   page_number,
+				PageSize:     page_size,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetReceiptTemplate returns a HTTP handler function to retrieve a receipt template with its versions.
+func GetReceiptTemplate(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		template_svc := services.ReceiptTemplateService{
+			Logger: logger,
+			Config: config,
+		}
+
+		template, err := template_svc.GetTemplate(id_param)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: template,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// InsertReceiptTemplate returns a HTTP handler function to add a new receipt template,
+// the content is validated as a handlebars template before saving.
+func InsertReceiptTemplate(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		request := struct {
+			Data models.ReceiptTemplate `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		template_svc := services.ReceiptTemplateService{
+			Logger: logger,
+			Config: config,
+		}
+
+		template, err := template_svc.InsertTemplate(request.Data, user_id)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: template,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			logger.Error(err.Error())
+			return
+		}
+	}
+}
+
+// UpdateReceiptTemplate returns a HTTP handler function to update a receipt template,
+// a changed content is saved as a new active version.
+func UpdateReceiptTemplate(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		request := struct {
+			Data models.ReceiptTemplate `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		template_svc := services.ReceiptTemplateService{
+			Logger: logger,
+			Config: config,
+		}
+
+		template, err := template_svc.UpdateTemplate(id_param, request.Data, user_id)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: template,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ActivateReceiptTemplateVersion returns a HTTP handler function to roll a receipt template back to one of its versions.
+func ActivateReceiptTemplateVersion(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		version, err := strconv.Atoi(params["version"])
+		if err != nil {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+
+		template_svc := services.ReceiptTemplateService{
+			Logger: logger,
+			Config: config,
+		}
+
+		err = template_svc.ActivateVersion(id_param, version)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DeleteReceiptTemplate returns a HTTP handler function to delete a receipt template.
+func DeleteReceiptTemplate(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		settings_svc := services.SettingsService{
+			Config: config,
+			Logger: logger,
+		}
+
+		settings, err := settings_svc.GetSettings()
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		template_svc := services.ReceiptTemplateService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		err = template_svc.DeleteTemplate(id_param)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PreviewReceiptTemplate returns a HTTP handler function that renders a sample order
+// with a receipt template using the same pipeline used for printing.
+//
+// The template is either the stored template of the {id} route param or the
+// content sent in the request body, the format query string is html or png (default).
+func PreviewReceiptTemplate(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		settings_svc := services.SettingsService{
+			Config: config,
+			Logger: logger,
+		}
+
+		settings, err := settings_svc.GetSettings()
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		template_svc := services.ReceiptTemplateService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		content := ""
+		if id_param != "" {
+			template, err := template_svc.GetTemplate(id_param)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			content = template.Content
+		} else {
+			request := struct {
+				Data struct {
+					Content string `json:"content"`
+				} `json:"data"`
+			}{}
+
+			err = json.NewDecoder(r.Body).Decode(&request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			content = request.Data.Content
+		}
+
+		err = template_svc.ValidateTemplate(content)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		lang_svc := services.LanguageService{
+			Config:   config,
+			Logger:   logger,
+			Settings: settings,
+		}
+
+		receipt_svc := services.ReceiptService{
+			Config:   config,
+			Logger:   logger,
+			Settings: settings,
+		}
+
+		order := template_svc.SampleOrder()
+
+		html, err := receipt_svc.RenderHTML(order, order.Discount, 0, order.SubmittedAt, requestLanguage(r, lang_svc), content, settings.ShopMode)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if r.URL.Query().Get("format") == "html" {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(html))
+			return
+		}
+
+		image, err := receipt_svc.RenderPNG(html)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(image)
+	}
+}
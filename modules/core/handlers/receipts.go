@@ -0,0 +1,303 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/zitadel/oidc/v3/pkg/oidc"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetOrderReceipts returns a HTTP handler function to list the archived client receipts of an order.
+func GetOrderReceipts(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		archive_svc := services.ReceiptArchiveService{
+			Logger: logger,
+			Config: config,
+		}
+
+		receipts, err := archive_svc.GetOrderReceipts(id_param)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: receipts,
+			Meta: JSONAPIMeta{
+				TotalRecords: len(receipts),
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// reprintReceipt reprints the archived receipt as a duplicate on the printer given in the request body,
+// or on the printer of the original receipt when none is given.
+func reprintReceipt(w http.ResponseWriter, r *http.Request, receipt_id string, config config.Config, logger logger.ILogger, settings models.Settings) {
+	request := struct {
+		Data struct {
+			PrinterHost string `json:"printer_host"`
+		} `json:"data"`
+	}{}
+
+	if r.ContentLength > 0 {
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	user_id := "0"
+	if config.Zitadel.Enabled {
+		user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+	}
+
+	archive_svc := services.ReceiptArchiveService{
+		Logger:   logger,
+		Config:   config,
+		Settings: settings,
+	}
+
+	err := archive_svc.Reprint(receipt_id, request.Data.PrinterHost, user_id)
+	if err != nil {
+		logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// ReprintReceipt returns a HTTP handler function to reprint an archived client receipt marked as duplicate.
+func ReprintReceipt(config config.Config, logger logger.ILogger, settings models.Settings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		reprintReceipt(w, r, id_param, config, logger, settings)
+	}
+}
+
+// ReprintOrderReceipt returns a HTTP handler function to reprint the original client receipt of an order marked as duplicate.
+func ReprintOrderReceipt(config config.Config, logger logger.ILogger, settings models.Settings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		archive_svc := services.ReceiptArchiveService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		receipts, err := archive_svc.GetOrderReceipts(id_param)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if len(receipts) == 0 {
+			http.Error(w, "order has no printed receipt", http.StatusNotFound)
+			return
+		}
+
+		reprintReceipt(w, r, receipts[0].Id, config, logger, settings)
+	}
+}
+
+// GetDigitalReceiptLink returns a HTTP handler function to get the signed public link of an order receipt.
+func GetDigitalReceiptLink(config config.Config, logger logger.ILogger, settings models.Settings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		digital_receipt_svc := services.DigitalReceiptService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		url, expires_at, err := digital_receipt_svc.ReceiptURL(id_param)
+		if err != nil {
+			if errors.Is(err, services.ErrDigitalReceiptsDisabled) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: struct {
+				Url       string    `json:"url"`
+				ExpiresAt time.Time `json:"expires_at"`
+			}{
+				Url:       url,
+				ExpiresAt: expires_at,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// GetPublicReceipt returns an unauthenticated HTTP handler function serving the receipt behind a signed token,
+// as html by default or as a pdf with ?format=pdf.
+func GetPublicReceipt(config config.Config, logger logger.ILogger, settings models.Settings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		token_param := params["token"]
+
+		lang_svc := services.LanguageService{
+			Config:   config,
+			Logger:   logger,
+			Settings: settings,
+		}
+
+		digital_receipt_svc := services.DigitalReceiptService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		output, err := digital_receipt_svc.RenderHTML(token_param, requestLanguage(r, lang_svc))
+		if err != nil {
+			switch {
+			case errors.Is(err, services.ErrDigitalReceiptsDisabled), errors.Is(err, services.ErrInvalidReceiptToken), errors.Is(err, mongo.ErrNoDocuments):
+				http.Error(w, "receipt not found", http.StatusNotFound)
+			case errors.Is(err, services.ErrExpiredReceiptToken):
+				http.Error(w, err.Error(), http.StatusGone)
+			default:
+				logger.Error(err.Error())
+				http.Error(w, "can't render receipt", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		if r.URL.Query().Get("format") == "pdf" {
+			receipt_svc := services.ReceiptService{
+				Config:   config,
+				Logger:   logger,
+				Settings: settings,
+			}
+
+			buf, err := receipt_svc.RenderPDF(output, services.PDFPaper80mm)
+			if err != nil {
+				logger.Error(err.Error())
+				http.Error(w, "can't render receipt", http.StatusInternalServerError)
+				return
+			}
+
+			w.Header().Set("Content-Type", "application/pdf")
+			w.Header().Set("Content-Disposition", `inline; filename="receipt.pdf"`)
+			w.Write(buf)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(output))
+	}
+}
+
+// GetOrderInvoicePDF returns a HTTP handler function to download the order invoice as a pdf,
+// ?size=80mm renders the roll receipt instead of the default A4 invoice.
+func GetOrderInvoicePDF(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := mux.Vars(r)
+		id_param := params["id"]
+
+		paper := services.PDFPaperA4
+		switch r.URL.Query().Get("size") {
+		case "", "a4":
+		case "80mm":
+			paper = services.PDFPaper80mm
+		default:
+			http.Error(w, "size must be a4 or 80mm", http.StatusBadRequest)
+			return
+		}
+
+		settings_svc := services.SettingsService{
+			Config: config,
+		}
+
+		settings, err := settings_svc.GetSettings()
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		order_svc := services.OrderService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		order, err := order_svc.GetOrder(id_param)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				http.Error(w, "order not found", http.StatusNotFound)
+				return
+			}
+
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		lang_svc := services.LanguageService{
+			Config:   config,
+			Logger:   logger,
+			Settings: settings,
+		}
+
+		receipt_svc := services.ReceiptService{
+			Config:   config,
+			Logger:   logger,
+			Settings: settings,
+		}
+
+		buf, err := receipt_svc.RenderInvoicePDF(order, requestLanguage(r, lang_svc), paper)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`inline; filename="invoice-%s.pdf"`, order.DisplayId))
+		w.Write(buf)
+	}
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/zitadel/oidc/v3/pkg/oidc"
+)
+
+// reorderService returns the reorder service with the current settings.
+func reorderService(config config.Config, logger logger.ILogger) (services.ReorderService, error) {
+	settings_svc := services.SettingsService{
+		Config: config,
+	}
+
+	settings, err := settings_svc.GetSettings()
+	if err != nil {
+		return services.ReorderService{}, err
+	}
+
+	return services.ReorderService{
+		Logger:   logger,
+		Config:   config,
+		Settings: settings,
+	}, nil
+}
+
+// GetReorderSuggestions returns a HTTP handler function to retrieve the materials to restock and how much of them
+// to order. The optional days query string is the number of past days the consumption is averaged over, and all
+// returns the materials not needing a restock as well.
+func GetReorderSuggestions(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+		all := r.URL.Query().Get("all") == "true"
+
+		reorder_svc, err := reorderService(config, logger)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report, err := reorder_svc.GetReorderSuggestions(days, all)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: report,
+			Meta: JSONAPIMeta{
+				TotalRecords: len(report.Suggestions),
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// CreateReorderPurchaseOrders returns a HTTP handler function to turn the reorder suggestions into draft purchase
+// orders, one per supplier. The request data may limit them to material_ids and set the consumption days.
+func CreateReorderPurchaseOrders(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		request := struct {
+			Data struct {
+				MaterialIds []string `json:"material_ids"`
+				Days        int      `json:"days"`
+			} `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reorder_svc, err := reorderService(config, logger)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		purchase_orders, err := reorder_svc.CreateReorderPurchaseOrders(request.Data.MaterialIds, request.Data.Days, user_id)
+		if err != nil {
+			purchaseOrderError(w, logger, err)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: purchase_orders,
+			Meta: JSONAPIMeta{
+				TotalRecords: len(purchase_orders),
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ExportShoppingList returns a HTTP handler function to download the reorder suggestions as a shopping list.
+// The format query string is csv (default) or xlsx and days sets the consumption days.
+func ExportShoppingList(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		days, _ := strconv.Atoi(r.URL.Query().Get("days"))
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = services.SalesExportFormatCSV
+		}
+
+		writer, err := services.NewSalesExportWriter(format, w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		reorder_svc, err := reorderService(config, logger)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		report, err := reorder_svc.GetReorderSuggestions(days, false)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		content_type := "text/csv"
+		if format == services.SalesExportFormatXLSX {
+			content_type = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		}
+
+		w.Header().Set("Content-Type", content_type)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=shopping-list-%s.%s", time.Now().Format("20060102"), format))
+
+		// the headers are already sent while writing, failures can only be logged
+		err = services.WriteShoppingList(report, writer)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
+}
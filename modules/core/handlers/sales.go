@@ -12,9 +12,11 @@ package handlers
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/nutrixpos/pos/common/config"
@@ -113,3 +115,197 @@ func GetSalesPerDay(config config.Config, logger logger.ILogger) http.HandlerFun
 		}
 	}
 }
+
+// salesDateRange parses the from and to query string parameters as business days (2006-01-02) or RFC3339 times,
+// a business day in to is inclusive. The range defaults to the last 7 business days.
+func salesDateRange(r *http.Request, config config.Config) (from time.Time, to time.Time, err error) {
+	business_day, err := services.NewBusinessDay(config)
+	if err != nil {
+		return from, to, err
+	}
+
+	today := business_day.Today()
+	from, to, err = business_day.Range(today, today)
+	if err != nil {
+		return from, to, err
+	}
+	from = from.AddDate(0, 0, -6)
+
+	if to_param := r.URL.Query().Get("to"); to_param != "" {
+		if _, to, err = business_day.Range(to_param, to_param); err != nil {
+			if to, err = time.Parse(time.RFC3339, to_param); err != nil {
+				return from, to, errors.New("to must be a date (2006-01-02) or an RFC3339 time")
+			}
+		}
+	}
+
+	if from_param := r.URL.Query().Get("from"); from_param != "" {
+		if from, err = business_day.Start(from_param); err != nil {
+			if from, err = time.Parse(time.RFC3339, from_param); err != nil {
+				return from, to, errors.New("from must be a date (2006-01-02) or an RFC3339 time")
+			}
+		}
+	}
+
+	if !from.Before(to) {
+		return from, to, errors.New("from must be before to")
+	}
+
+	return from, to, nil
+}
+
+// GetSalesAnalytics returns a HTTP handler function to aggregate the sales of a date range.
+// It accepts the query string parameters:
+// group_by: hour, weekday, product, category, payment_source, service_style or user
+// from, to: the date range, see salesDateRange
+func GetSalesAnalytics(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		from, to, err := salesDateRange(r, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		salesService := services.SalesService{
+			Logger: logger,
+			Config: config,
+		}
+
+		groups, err := salesService.GetSalesAnalytics(r.URL.Query().Get("group_by"), from, to)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidSalesGroupBy) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Meta: JSONAPIMeta{
+				TotalRecords: len(groups),
+			},
+			Data: groups,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+// ExportSales returns a HTTP handler function to download a line per sold order item of a date range.
+// It accepts the query string parameters:
+// from, to: the date range, see salesDateRange
+// format: csv (default) or xlsx
+// columns: comma separated column keys, all the columns by default
+func ExportSales(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		from, to, err := salesDateRange(r, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if format == "" {
+			format = services.SalesExportFormatCSV
+		}
+
+		var keys []string
+		if columns_param := r.URL.Query().Get("columns"); columns_param != "" {
+			keys = strings.Split(columns_param, ",")
+		}
+
+		columns, err := services.GetSalesExportColumns(keys)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		writer, err := services.NewSalesExportWriter(format, w)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		settings_svc := services.SettingsService{
+			Config: config,
+		}
+
+		settings, err := settings_svc.GetSettings()
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		salesService := services.SalesService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		content_type := "text/csv"
+		if format == services.SalesExportFormatXLSX {
+			content_type = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+		}
+
+		filename := fmt.Sprintf("sales-%s-%s.%s", from.Format("20060102"), to.Add(-time.Nanosecond).Format("20060102"), format)
+
+		w.Header().Set("Content-Type", content_type)
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+
+		// the headers are already sent while streaming, failures can only be logged
+		err = salesService.ExportSales(from, to, columns, writer)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+
+		err = writer.Close()
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
+}
+
+// GetProfitLoss returns a HTTP handler function to get the profit and loss of a date range,
+// see salesDateRange for the from and to query string parameters.
+func GetProfitLoss(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		from, to, err := salesDateRange(r, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		salesService := services.SalesService{
+			Logger: logger,
+			Config: config,
+		}
+
+		report, err := salesService.GetProfitLoss(from, to)
+		if err != nil {
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: report,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/zitadel/oidc/v3/pkg/oidc"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// stockTakeError writes the error of a stock take operation with its matching status code.
+func stockTakeError(w http.ResponseWriter, logger logger.ILogger, err error) {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		http.Error(w, "stock take not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidStockTake), errors.Is(err, services.ErrIncompatibleUnits):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrInvalidStockTakeState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeStockTake writes the stock take as the response.
+func writeStockTake(w http.ResponseWriter, status int, stock_take models.StockTake) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: stock_take}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetStockTakes returns a HTTP handler function to list the stock takes without their lines, newest first.
+// It accepts the state query string parameter as a filter.
+func GetStockTakes(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := services.GetStockTakesParams{
+			State: r.URL.Query().Get("state"),
+		}
+
+		page_number, err := strconv.Atoi(r.URL.Query().Get("page[number]"))
+		if err != nil || page_number == 0 {
+			params.PageNumber = 1
+		} else {
+			params.PageNumber = page_number
+		}
+
+		page_size, err := strconv.Atoi(r.URL.Query().Get("page[size]"))
+		if err != nil {
+			params.PageSize = 50
+		} else {
+			params.PageSize = page_size
+		}
+
+		stock_take_svc := services.StockTakeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		stock_takes, total_records, err := stock_take_svc.GetStockTakes(params)
+		if err != nil {
+			stockTakeError(w, logger, err)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: stock_takes,
+			Meta: JSONAPIMeta{
+				TotalRecords: total_records,
+				PageNumber:   params.PageNumber,
+				PageSize:     params.PageSize,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func GetStockTake(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		stock_take_svc := services.StockTakeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		stock_take, err := stock_take_svc.GetStockTake(params["id"])
+		if err != nil {
+			stockTakeError(w, logger, err)
+			return
+		}
+
+		writeStockTake(w, http.StatusOK, stock_take)
+	}
+}
+
+// AddStockTake returns a HTTP handler function to open a stock take, snapshotting the expected quantity of the
+// entries of the given material_ids, or of every material when none is given.
+func AddStockTake(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		request := struct {
+			Data models.StockTake `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stock_take_svc := services.StockTakeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		stock_take, err := stock_take_svc.CreateStockTake(request.Data, user_id)
+		if err != nil {
+			stockTakeError(w, logger, err)
+			return
+		}
+
+		writeStockTake(w, http.StatusCreated, stock_take)
+	}
+}
+
+// RecordStockTakeCounts returns a HTTP handler function to record counted quantities in an open stock take,
+// the request data is a list of counts and the entries left out stay uncounted.
+func RecordStockTakeCounts(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		params := mux.Vars(r)
+
+		request := struct {
+			Data []models.StockTakeCount `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stock_take_svc := services.StockTakeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		stock_take, err := stock_take_svc.RecordCounts(params["id"], request.Data, user_id)
+		if err != nil {
+			stockTakeError(w, logger, err)
+			return
+		}
+
+		writeStockTake(w, http.StatusOK, stock_take)
+	}
+}
+
+// PostStockTake returns a HTTP handler function to adjust the stock to the counted quantities of an open stock take.
+func PostStockTake(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		params := mux.Vars(r)
+
+		stock_take_svc := services.StockTakeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		stock_take, err := stock_take_svc.PostStockTake(params["id"], user_id)
+		if err != nil {
+			stockTakeError(w, logger, err)
+			return
+		}
+
+		writeStockTake(w, http.StatusOK, stock_take)
+	}
+}
+
+func CancelStockTake(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		stock_take_svc := services.StockTakeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		stock_take, err := stock_take_svc.CancelStockTake(params["id"])
+		if err != nil {
+			stockTakeError(w, logger, err)
+			return
+		}
+
+		writeStockTake(w, http.StatusOK, stock_take)
+	}
+}
+
+func DeleteStockTake(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		stock_take_svc := services.StockTakeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		err := stock_take_svc.DeleteStockTake(params["id"])
+		if err != nil {
+			stockTakeError(w, logger, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GetStockTakeReport returns a HTTP handler function to retrieve the history of the posted stock takes and the
+// variance per material over them. The from and to query strings are dates (2006-01-02) or RFC3339 times and
+// default to the last 7 business days.
+func GetStockTakeReport(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		from, to, err := salesDateRange(r, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stock_take_svc := services.StockTakeService{
+			Logger: logger,
+			Config: config,
+		}
+
+		report, err := stock_take_svc.GetStockTakeReport(from, to)
+		if err != nil {
+			stockTakeError(w, logger, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: report}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"github.com/zitadel/oidc/v3/pkg/oidc"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// stockTransferError writes the error of a stock transfer operation with its matching status code.
+func stockTransferError(w http.ResponseWriter, logger logger.ILogger, err error) {
+	switch {
+	case errors.Is(err, mongo.ErrNoDocuments):
+		http.Error(w, "stock transfer not found", http.StatusNotFound)
+	case errors.Is(err, services.ErrInvalidStockTransfer), errors.Is(err, services.ErrIncompatibleUnits):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, services.ErrInvalidStockTransferState):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		logger.Error(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeStockTransfer writes the stock transfer as the response.
+func writeStockTransfer(w http.ResponseWriter, status int, transfer models.StockTransfer) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: transfer}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// GetStockTransfers returns a HTTP handler function to list the stock transfers, the latest dispatched first.
+// It accepts the state and location_id query string parameters as filters.
+func GetStockTransfers(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := services.GetStockTransfersParams{
+			State:      r.URL.Query().Get("state"),
+			LocationId: r.URL.Query().Get("location_id"),
+		}
+
+		page_number, err := strconv.Atoi(r.URL.Query().Get("page[number]"))
+		if err != nil || page_number == 0 {
+			params.PageNumber = 1
+		} else {
+			params.PageNumber = page_number
+		}
+
+		page_size, err := strconv.Atoi(r.URL.Query().Get("page[size]"))
+		if err != nil {
+			params.PageSize = 50
+		} else {
+			params.PageSize = page_size
+		}
+
+		transfer_svc := services.StockTransferService{
+			Logger: logger,
+			Config: config,
+		}
+
+		transfers, total_records, err := transfer_svc.GetStockTransfers(params)
+		if err != nil {
+			stockTransferError(w, logger, err)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: transfers,
+			Meta: JSONAPIMeta{
+				TotalRecords: total_records,
+				PageNumber:   params.PageNumber,
+				PageSize:     params.PageSize,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func GetStockTransfer(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		transfer_svc := services.StockTransferService{
+			Logger: logger,
+			Config: config,
+		}
+
+		transfer, err := transfer_svc.GetStockTransfer(params["id"])
+		if err != nil {
+			stockTransferError(w, logger, err)
+			return
+		}
+
+		writeStockTransfer(w, http.StatusOK, transfer)
+	}
+}
+
+// DispatchStockTransfer returns a HTTP handler function to send stock from a location to another, taking it out
+// of the source location right away.
+func DispatchStockTransfer(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		request := struct {
+			Data models.StockTransfer `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		settings_svc := services.SettingsService{
+			Config: config,
+		}
+
+		settings, err := settings_svc.GetSettings()
+		if err != nil {
+			stockTransferError(w, logger, err)
+			return
+		}
+
+		transfer_svc := services.StockTransferService{
+			Logger:   logger,
+			Config:   config,
+			Settings: settings,
+		}
+
+		transfer, err := transfer_svc.DispatchStockTransfer(request.Data, user_id)
+		if err != nil {
+			stockTransferError(w, logger, err)
+			return
+		}
+
+		writeStockTransfer(w, http.StatusCreated, transfer)
+	}
+}
+
+// ReceiveStockTransfer returns a HTTP handler function to receive a dispatched stock transfer at its destination.
+// The request data lists the lines received short, the other lines are received in full.
+func ReceiveStockTransfer(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		params := mux.Vars(r)
+
+		request := struct {
+			Data []models.StockTransferReceiptLine `json:"data"`
+		}{}
+
+		// the body is optional, an empty one receives every line in full
+		if r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&request)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+
+		transfer_svc := services.StockTransferService{
+			Logger: logger,
+			Config: config,
+		}
+
+		transfer, err := transfer_svc.ReceiveStockTransfer(params["id"], request.Data, user_id)
+		if err != nil {
+			stockTransferError(w, logger, err)
+			return
+		}
+
+		writeStockTransfer(w, http.StatusOK, transfer)
+	}
+}
+
+// CancelStockTransfer returns a HTTP handler function to cancel a dispatched stock transfer, returning its stock
+// to the source location.
+func CancelStockTransfer(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		user_id := "0"
+		if config.Zitadel.Enabled {
+			user_id = r.Context().Value("auth_ctx").(oidc.IntrospectionResponse).Subject
+		}
+
+		params := mux.Vars(r)
+
+		transfer_svc := services.StockTransferService{
+			Logger: logger,
+			Config: config,
+		}
+
+		transfer, err := transfer_svc.CancelStockTransfer(params["id"], user_id)
+		if err != nil {
+			stockTransferError(w, logger, err)
+			return
+		}
+
+		writeStockTransfer(w, http.StatusOK, transfer)
+	}
+}
+
+// GetInTransit returns a HTTP handler function to retrieve the quantity of each material dispatched between
+// locations and not received yet.
+func GetInTransit(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		transfer_svc := services.StockTransferService{
+			Logger: logger,
+			Config: config,
+		}
+
+		in_transit, err := transfer_svc.GetInTransit()
+		if err != nil {
+			stockTransferError(w, logger, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: in_transit}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetSuppliers returns a HTTP handler function to list the suppliers, material_id filters the suppliers selling it.
+func GetSuppliers(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		params := services.GetSuppliersParams{
+			MaterialId: r.URL.Query().Get("material_id"),
+		}
+
+		page_number, err := strconv.Atoi(r.URL.Query().Get("page[number]"))
+		if err != nil || page_number == 0 {
+			params.PageNumber = 1
+		} else {
+			params.PageNumber = page_number
+		}
+
+		page_size, err := strconv.Atoi(r.URL.Query().Get("page[size]"))
+		if err != nil {
+			params.PageSize = 50
+		} else {
+			params.PageSize = page_size
+		}
+
+		supplier_svc := services.SupplierService{
+			Logger: logger,
+			Config: config,
+		}
+
+		suppliers, total_records, err := supplier_svc.GetSuppliers(params)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: suppliers,
+			Meta: JSONAPIMeta{
+				TotalRecords: total_records,
+				PageNumber:   params.PageNumber,
+				PageSize:     params.PageSize,
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func GetSupplier(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		supplier_svc := services.SupplierService{
+			Logger: logger,
+			Config: config,
+		}
+
+		supplier, err := supplier_svc.GetSupplier(params["id"])
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				http.Error(w, "supplier not found", http.StatusNotFound)
+				return
+			}
+
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: supplier}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func AddSupplier(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Data models.Supplier `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		supplier_svc := services.SupplierService{
+			Logger: logger,
+			Config: config,
+		}
+
+		supplier, err := supplier_svc.InsertSupplier(request.Data)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidSupplier) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: supplier}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func UpdateSupplier(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		request := struct {
+			Data models.Supplier `json:"data"`
+		}{}
+
+		err := json.NewDecoder(r.Body).Decode(&request)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		supplier_svc := services.SupplierService{
+			Logger: logger,
+			Config: config,
+		}
+
+		supplier, err := supplier_svc.UpdateSupplier(request.Data, params["id"])
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidSupplier) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(JSONApiOkResponse{Data: supplier}); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
+
+func DeleteSupplier(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		params := mux.Vars(r)
+
+		supplier_svc := services.SupplierService{
+			Logger: logger,
+			Config: config,
+		}
+
+		err := supplier_svc.DeleteSupplier(params["id"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
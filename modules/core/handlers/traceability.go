@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/nutrixpos/pos/modules/core/services"
+)
+
+// GetLotTrace returns a HTTP handler function to trace a material lot to the orders, products and customers that
+// used it. The lot is selected by the entry_id, sku or lot_number query string, at least one of them is required.
+func GetLotTrace(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		query := models.TraceQuery{
+			EntryId:   r.URL.Query().Get("entry_id"),
+			SKU:       r.URL.Query().Get("sku"),
+			LotNumber: r.URL.Query().Get("lot_number"),
+		}
+
+		traceability_svc := services.TraceabilityService{
+			Logger: logger,
+			Config: config,
+		}
+
+		report, err := traceability_svc.TraceLot(query)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidTraceQuery) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Data: report,
+			Meta: JSONAPIMeta{
+				TotalRecords: len(report.Orders),
+			},
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
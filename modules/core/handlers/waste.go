@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/services"
+)
+
+// GetWasteAnalytics returns a HTTP handler function to report the waste and disposals of a date range
+// compared to the period before it.
+// It accepts the query string parameters:
+// group_by: material (default), product, reason, user, source, day, week or month
+// from, to: the date range, see salesDateRange
+// top: the number of groups returned, 10 by default and 0 for all of them
+func GetWasteAnalytics(config config.Config, logger logger.ILogger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		from, to, err := salesDateRange(r, config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		group_by := r.URL.Query().Get("group_by")
+		if group_by == "" {
+			group_by = services.WasteGroupByMaterial
+		}
+
+		top, err := strconv.Atoi(r.URL.Query().Get("top"))
+		if err != nil {
+			top = 10
+		}
+
+		waste_svc := services.WasteService{
+			Logger: logger,
+			Config: config,
+		}
+
+		analytics, err := waste_svc.GetWasteAnalytics(group_by, from, to, top)
+		if err != nil {
+			if errors.Is(err, services.ErrInvalidWasteGroupBy) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			logger.Error(err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response := JSONApiOkResponse{
+			Meta: JSONAPIMeta{
+				TotalRecords: len(analytics.Groups),
+			},
+			Data: analytics,
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+const (
+	BarcodeFormatEAN13   = "ean13"
+	BarcodeFormatEAN8    = "ean8"
+	BarcodeFormatUPCA    = "upca"
+	BarcodeFormatCode128 = "code128"
+)
+
+const (
+	// ScanModeOrder resolves a scan to a product to add to an order, as the retail mode does.
+	ScanModeOrder = "order"
+	// ScanModeReceive resolves a scan to a material to receive stock of.
+	ScanModeReceive = "receive"
+)
+
+// Barcode is a scanned code along with the symbology it was recognized as.
+type Barcode struct {
+	Code   string `json:"code" bson:"code" mapstructure:"code"`
+	Format string `json:"format" bson:"format" mapstructure:"format"`
+}
+
+// ScanResult is what a scanned code resolved to. A product is returned with the entry carrying the code, a material
+// with its entries carrying it, the latest first, and with the supplier selling it under the code when there is one.
+type ScanResult struct {
+	Barcode          Barcode           `json:"barcode" bson:"barcode" mapstructure:"barcode"`
+	Mode             string            `json:"mode" bson:"mode" mapstructure:"mode"`
+	Found            bool              `json:"found" bson:"found" mapstructure:"found"`
+	Product          *Product          `json:"product,omitempty" bson:"product,omitempty" mapstructure:"product"`
+	ProductEntry     *ProductEntry     `json:"product_entry,omitempty" bson:"product_entry,omitempty" mapstructure:"product_entry"`
+	Material         *Material         `json:"material,omitempty" bson:"material,omitempty" mapstructure:"material"`
+	SupplierId       string            `json:"supplier_id,omitempty" bson:"supplier_id,omitempty" mapstructure:"supplier_id"`
+	SupplierName     string            `json:"supplier_name,omitempty" bson:"supplier_name,omitempty" mapstructure:"supplier_name"`
+	SupplierMaterial *SupplierMaterial `json:"supplier_material,omitempty" bson:"supplier_material,omitempty" mapstructure:"supplier_material"`
+}
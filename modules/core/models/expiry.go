@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+const (
+	ExpiryStatusExpiring = "expiring"
+	ExpiryStatusExpired  = "expired"
+)
+
+// ExpiryReportEntry is a material entry with stock that expired or expires within the warning days of its material.
+type ExpiryReportEntry struct {
+	MaterialId     string    `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	MaterialName   string    `json:"material_name" bson:"material_name" mapstructure:"material_name"`
+	Unit           string    `json:"unit" bson:"unit" mapstructure:"unit"`
+	EntryId        string    `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	SKU            string    `json:"sku" bson:"sku" mapstructure:"sku"`
+	Company        string    `json:"company" bson:"company" mapstructure:"company"`
+	Quantity       float64   `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	ExpirationDate time.Time `json:"expiration_date" bson:"expiration_date" mapstructure:"expiration_date"`
+	// DaysLeft is negative once the entry expired.
+	DaysLeft float64 `json:"days_left" bson:"days_left" mapstructure:"days_left"`
+	Status   string  `json:"status" bson:"status" mapstructure:"status"`
+	// Value is the purchase cost of the quantity left.
+	Value float64 `json:"value" bson:"value" mapstructure:"value"`
+}
+
+// ExpiryReport lists the entries at risk, soonest to expire first.
+type ExpiryReport struct {
+	Date          time.Time           `json:"date" bson:"date" mapstructure:"date"`
+	ExpiredValue  float64             `json:"expired_value" bson:"expired_value" mapstructure:"expired_value"`
+	ExpiringValue float64             `json:"expiring_value" bson:"expiring_value" mapstructure:"expiring_value"`
+	Entries       []ExpiryReportEntry `json:"entries" bson:"entries" mapstructure:"entries"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// InvoiceItem is an order item snapshot as it was invoiced.
+type InvoiceItem struct {
+	ItemId    string  `json:"item_id" bson:"item_id" mapstructure:"item_id"`
+	ProductId string  `json:"product_id" bson:"product_id" mapstructure:"product_id"`
+	Name      string  `json:"name" bson:"name" mapstructure:"name"`
+	Quantity  float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	SalePrice float64 `json:"sale_price" bson:"sale_price" mapstructure:"sale_price"`
+}
+
+// Invoice is a fiscal invoice issued for a paid order, invoices have a gap-free
+// sequential number and each one carries the hash of the previous invoice to form a tamper-evident chain.
+type Invoice struct {
+	Id            string        `json:"id" bson:"id" mapstructure:"id"`
+	Number        uint64        `json:"number" bson:"number" mapstructure:"number"`
+	OrderId       string        `json:"order_id" bson:"order_id" mapstructure:"order_id"`
+	DisplayId     string        `json:"display_id" bson:"display_id" mapstructure:"display_id"`
+	IssuedAt      time.Time     `json:"issued_at" bson:"issued_at" mapstructure:"issued_at"`
+	Items         []InvoiceItem `json:"items" bson:"items" mapstructure:"items"`
+	Discount      float64       `json:"discount" bson:"discount" mapstructure:"discount"`
+	Total         float64       `json:"total" bson:"total" mapstructure:"total"`
+	PaymentSource string        `json:"payment_source" bson:"payment_source" mapstructure:"payment_source"`
+	PreviousHash  string        `json:"previous_hash" bson:"previous_hash" mapstructure:"previous_hash"`
+	Hash          string        `json:"hash" bson:"hash" mapstructure:"hash"`
+}
+
+// InvoiceChainIssue describes a problem found while verifying the invoices chain.
+type InvoiceChainIssue struct {
+	Number  uint64 `json:"number"`
+	Type    string `json:"type"` // gap, previous_hash_mismatch or hash_mismatch
+	Message string `json:"message"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+const (
+	LabelFormatZPL    = "zpl"
+	LabelFormatESCPOS = "escpos"
+
+	LabelTypeProduct  = "product"
+	LabelTypeMaterial = "material"
+)
+
+// LabelTemplate is a handlebars template rendering a label into ZPL or ESC/POS printer commands.
+type LabelTemplate struct {
+	Id        string    `json:"id" bson:"id" mapstructure:"id"`
+	Name      string    `json:"name" bson:"name" mapstructure:"name"`
+	Format    string    `json:"format" bson:"format" mapstructure:"format"` // zpl or escpos
+	Content   string    `json:"content" bson:"content" mapstructure:"content"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at" mapstructure:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at" mapstructure:"updated_at"`
+}
+
+// Label holds the data printed on a prep or expiry label.
+type Label struct {
+	Type           string    `json:"type" bson:"type" mapstructure:"type"` // product or material
+	ItemId         string    `json:"item_id" bson:"item_id" mapstructure:"item_id"`
+	Name           string    `json:"name" bson:"name" mapstructure:"name"`
+	BatchId        string    `json:"batch_id" bson:"batch_id" mapstructure:"batch_id"`
+	Quantity       float64   `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	Unit           string    `json:"unit" bson:"unit" mapstructure:"unit"`
+	PreparedAt     time.Time `json:"prepared_at" bson:"prepared_at" mapstructure:"prepared_at"`
+	ExpirationDate time.Time `json:"expiration_date" bson:"expiration_date" mapstructure:"expiration_date"`
+	// Barcode is the value encoded in the label barcode, defaults to the batch id.
+	Barcode string `json:"barcode" bson:"barcode" mapstructure:"barcode"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+const (
+	StockTransferStateDispatched = "dispatched"
+	// StockTransferStateReceiving and StockTransferStateCancelling hold a transfer while its stock is moved so a
+	// concurrent receipt or cancellation can't move it twice.
+	StockTransferStateReceiving  = "receiving"
+	StockTransferStateReceived   = "received"
+	StockTransferStateCancelling = "cancelling"
+	StockTransferStateCancelled  = "cancelled"
+)
+
+// Location is a place holding stock, e.g. a central kitchen or an outlet. The entries without a location
+// are at the default one.
+type Location struct {
+	Id        string `json:"id" bson:"id" mapstructure:"id"`
+	Name      string `json:"name" bson:"name" mapstructure:"name"`
+	Address   string `json:"address" bson:"address" mapstructure:"address"`
+	IsDefault bool   `json:"is_default" bson:"is_default" mapstructure:"is_default"`
+}
+
+// StockTransferLine is a quantity of a material sent to another location, in Unit when given. Shares are the
+// source entries it was taken from, in the material unit.
+type StockTransferLine struct {
+	Id           string  `json:"id" bson:"id" mapstructure:"id"`
+	MaterialId   string  `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	MaterialName string  `json:"material_name" bson:"material_name" mapstructure:"material_name"`
+	EntryId      string  `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	Quantity     float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	Unit         string  `json:"unit" bson:"unit" mapstructure:"unit"`
+	// ReceivedQuantity is in the material unit, the difference with the shares is lost in transit.
+	ReceivedQuantity float64              `json:"received_quantity" bson:"received_quantity" mapstructure:"received_quantity"`
+	Shares           []MaterialEntryShare `json:"shares" bson:"shares" mapstructure:"shares"`
+	// ReceivedEntryIds are the entries received from each share, in the order of the shares, empty for the shares
+	// lost in transit. LostLogIds are the waste logs of the quantity of each share lost in transit, empty for the
+	// shares fully received. Both are decided before receiving so that an interrupted receipt completes once.
+	ReceivedEntryIds []string `json:"received_entry_ids" bson:"received_entry_ids" mapstructure:"received_entry_ids"`
+	LostLogIds       []string `json:"lost_log_ids" bson:"lost_log_ids" mapstructure:"lost_log_ids"`
+}
+
+// StockTransferReceiptLine is the quantity of a transfer line received in the material unit.
+type StockTransferReceiptLine struct {
+	LineId   string  `json:"line_id" bson:"line_id" mapstructure:"line_id"`
+	Quantity float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+}
+
+// StockTransfer moves stock between locations, the stock leaves the source location when dispatched and is
+// in transit until received.
+type StockTransfer struct {
+	Id             string              `json:"id" bson:"id" mapstructure:"id"`
+	FromLocationId string              `json:"from_location_id" bson:"from_location_id" mapstructure:"from_location_id"`
+	ToLocationId   string              `json:"to_location_id" bson:"to_location_id" mapstructure:"to_location_id"`
+	State          string              `json:"state" bson:"state" mapstructure:"state"`
+	Lines          []StockTransferLine `json:"lines" bson:"lines" mapstructure:"lines"`
+	Notes          string              `json:"notes" bson:"notes" mapstructure:"notes"`
+	DispatchedAt   time.Time           `json:"dispatched_at" bson:"dispatched_at" mapstructure:"dispatched_at"`
+	DispatchedBy   string              `json:"dispatched_by" bson:"dispatched_by" mapstructure:"dispatched_by"`
+	ReceivedAt     time.Time           `json:"received_at" bson:"received_at" mapstructure:"received_at"`
+	ReceivedBy     string              `json:"received_by" bson:"received_by" mapstructure:"received_by"`
+}
+
+// InTransitQuantity is the quantity of a material dispatched between two locations and not received yet.
+type InTransitQuantity struct {
+	MaterialId     string  `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	MaterialName   string  `json:"material_name" bson:"material_name" mapstructure:"material_name"`
+	FromLocationId string  `json:"from_location_id" bson:"from_location_id" mapstructure:"from_location_id"`
+	ToLocationId   string  `json:"to_location_id" bson:"to_location_id" mapstructure:"to_location_id"`
+	Quantity       float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	Transfers      int     `json:"transfers" bson:"transfers" mapstructure:"transfers"`
+}
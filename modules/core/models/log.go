@@ -13,9 +13,22 @@ const (
 	LogTypeMaterialConsume         = "component_consume"
 	LogTypeMaterialAdd             = "component_add"
 	LogTypeMaterialWaste           = "material_waste"
+	LogTypeProductWaste            = "product_waste"
+	LogTypeOrderItemWaste          = "waste_orderitem"
 	LogTypeProductIncrease         = "product_increase"
 	LogTypeSalesPerDayOrder        = "sales_per_day_order"
 	LogTypeSalesPerDayRefund       = "sales_per_day_refund"
+	LogTypeReceiptReprint          = "receipt_reprint"
+	LogTypeCashDrawerOpen          = "cash_drawer_open"
+	LogTypeWasteDigest             = "waste_digest"
+	LogTypePurchaseOrderReceive    = "purchase_order_receive"
+	LogTypePurchaseOrderMismatch   = "purchase_order_mismatch"
+	LogTypeStockTakeAdjustment     = "stock_take_adjustment"
+	LogTypeExpiryAlert             = "expiry_alert"
+	LogTypeStockTransferDispatch   = "stock_transfer_dispatch"
+	LogTypeStockTransferReceive    = "stock_transfer_receive"
+	LogTypeStockTransferCancel     = "stock_transfer_cancel"
+	LogTypeProductConsume          = "product_consume"
 )
 
 type Log struct {
@@ -74,6 +87,15 @@ type LogProductIncrease struct {
 	OrderId   string  `json:"order_id" bson:"order_id" mapstructure:"order_id"`
 }
 
+// LogProductConsume records an order item served from the ready stock of a product.
+type LogProductConsume struct {
+	Log            `json:",inline" bson:",inline" mapstructure:",squash"`
+	ProductId      string  `json:"product_id" bson:"product_id" mapstructure:"product_id"`
+	OrderId        string  `json:"order_id" bson:"order_id" mapstructure:"order_id"`
+	OrderItemIndex int     `json:"order_item_index" bson:"order_item_index" mapstructure:"order_item_index"`
+	Quantity       float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+}
+
 type LogWasteOrderItem struct {
 	Log      `json:",inline" bson:",inline" mapstructure:",squash"`
 	Item     OrderItem `json:"item" bson:"item" mapstructure:"item"`
@@ -85,6 +107,8 @@ type LogWasteOrderItem struct {
 type LogDisposalMaterialAdd struct {
 	Log      `json:",inline" bson:",inline" mapstructure:",squash"`
 	Disposal MaterialDisposal `json:"disposal" mapstructure:"disposal"`
+	// Value is the purchase cost of the disposed quantity.
+	Value float64 `json:"value" bson:"value" mapstructure:"value"`
 }
 
 type LogDisposalProductAdd struct {
@@ -100,6 +124,9 @@ type LogWasteMaterial struct {
 	Reason     string  `json:"reason" bson:"reason" mapstructure:"reason"`
 	IsConsume  bool    `json:"is_consume" bson:"is_consume" mapstructure:"is_consume"`
 	Quantity   float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	// Value is the purchase cost of the wasted quantity.
+	Value      float64 `json:"value" bson:"value" mapstructure:"value"`
+	LocationId string  `json:"location_id,omitempty" bson:"location_id,omitempty" mapstructure:"location_id"`
 }
 
 type LogMaterialConsume struct {
@@ -134,3 +161,73 @@ type LogSalesPerDayOrder struct {
 	Log              `json:",inline" bson:",inline" mapstructure:",squash"`
 	SalesPerDayOrder SalesPerDayOrder `json:"sales_per_day_order" bson:"sales_per_day_order" mapstructure:"sales_per_day_order"`
 }
+
+type LogReceiptReprint struct {
+	Log         `json:",inline" bson:",inline" mapstructure:",squash"`
+	ReceiptId   string `json:"receipt_id" bson:"receipt_id" mapstructure:"receipt_id"`
+	OrderId     string `json:"order_id" bson:"order_id" mapstructure:"order_id"`
+	PrinterHost string `json:"printer_host" bson:"printer_host" mapstructure:"printer_host"`
+}
+
+type LogCashDrawerOpen struct {
+	Log         `json:",inline" bson:",inline" mapstructure:",squash"`
+	Reason      string `json:"reason" bson:"reason" mapstructure:"reason"` // order_payment or no_sale
+	OrderId     string `json:"order_id,omitempty" bson:"order_id,omitempty" mapstructure:"order_id,omitempty"`
+	PrinterHost string `json:"printer_host" bson:"printer_host" mapstructure:"printer_host"`
+}
+
+type LogWasteDigest struct {
+	Log     `json:",inline" bson:",inline" mapstructure:",squash"`
+	From    time.Time `json:"from" bson:"from" mapstructure:"from"`
+	To      time.Time `json:"to" bson:"to" mapstructure:"to"`
+	Message string    `json:"message" bson:"message" mapstructure:"message"`
+}
+
+type LogPurchaseOrderReceive struct {
+	Log             `json:",inline" bson:",inline" mapstructure:",squash"`
+	PurchaseOrderId string               `json:"purchase_order_id" bson:"purchase_order_id" mapstructure:"purchase_order_id"`
+	SupplierId      string               `json:"supplier_id" bson:"supplier_id" mapstructure:"supplier_id"`
+	Receipt         PurchaseOrderReceipt `json:"receipt" bson:"receipt" mapstructure:"receipt"`
+}
+
+// LogPurchaseOrderMismatch records a received quantity or an invoiced price differing from the purchase order.
+type LogPurchaseOrderMismatch struct {
+	Log             `json:",inline" bson:",inline" mapstructure:",squash"`
+	PurchaseOrderId string  `json:"purchase_order_id" bson:"purchase_order_id" mapstructure:"purchase_order_id"`
+	SupplierId      string  `json:"supplier_id" bson:"supplier_id" mapstructure:"supplier_id"`
+	LineId          string  `json:"line_id" bson:"line_id" mapstructure:"line_id"`
+	MaterialId      string  `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	Kind            string  `json:"kind" bson:"kind" mapstructure:"kind"` // over_received, short_received or price
+	Expected        float64 `json:"expected" bson:"expected" mapstructure:"expected"`
+	Actual          float64 `json:"actual" bson:"actual" mapstructure:"actual"`
+	Comment         string  `json:"comment" bson:"comment" mapstructure:"comment"`
+}
+
+// LogStockTakeAdjustment records a material entry quantity corrected to the one counted in a stock take.
+type LogStockTakeAdjustment struct {
+	Log         `json:",inline" bson:",inline" mapstructure:",squash"`
+	StockTakeId string  `json:"stock_take_id" bson:"stock_take_id" mapstructure:"stock_take_id"`
+	MaterialId  string  `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	EntryId     string  `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	Previous    float64 `json:"previous" bson:"previous" mapstructure:"previous"`
+	Counted     float64 `json:"counted" bson:"counted" mapstructure:"counted"`
+	Quantity    float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	Value       float64 `json:"value" bson:"value" mapstructure:"value"`
+}
+
+// LogExpiryAlert records an expiry notification sent for a material entry, so it is only sent once.
+type LogExpiryAlert struct {
+	Log            `json:",inline" bson:",inline" mapstructure:",squash"`
+	MaterialId     string    `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	EntryId        string    `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	Status         string    `json:"status" bson:"status" mapstructure:"status"` // expiring or expired
+	ExpirationDate time.Time `json:"expiration_date" bson:"expiration_date" mapstructure:"expiration_date"`
+	Quantity       float64   `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	Wasted         bool      `json:"wasted" bson:"wasted" mapstructure:"wasted"`
+}
+
+// LogStockTransfer records a stock transfer being dispatched, received or cancelled.
+type LogStockTransfer struct {
+	Log      `json:",inline" bson:",inline" mapstructure:",squash"`
+	Transfer StockTransfer `json:"transfer" bson:"transfer" mapstructure:"transfer"`
+}
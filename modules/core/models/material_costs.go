@@ -0,0 +1,47 @@
+package models
+
+import "time"
+
+// MaterialCostPoint is the unit cost a material was purchased at.
+type MaterialCostPoint struct {
+	Date       time.Time `json:"date"`
+	EntryId    string    `json:"entry_id"`
+	SupplierId string    `json:"supplier_id"`
+	Supplier   string    `json:"supplier"`
+	Quantity   float64   `json:"quantity"`
+	Price      float64   `json:"price"`
+	UnitCost   float64   `json:"unit_cost"`
+}
+
+// MaterialSupplierCost summarizes the purchases of a material from one supplier, suppliers are told apart by
+// their id, or by company name for the entries added without a purchase order.
+type MaterialSupplierCost struct {
+	SupplierId      string    `json:"supplier_id"`
+	Supplier        string    `json:"supplier"`
+	Purchases       int       `json:"purchases"`
+	Quantity        float64   `json:"quantity"`
+	AverageUnitCost float64   `json:"average_unit_cost"`
+	MinUnitCost     float64   `json:"min_unit_cost"`
+	MaxUnitCost     float64   `json:"max_unit_cost"`
+	LastUnitCost    float64   `json:"last_unit_cost"`
+	LastDate        time.Time `json:"last_date"`
+}
+
+// MaterialCostTrend is how the unit cost of a material moved over a period compared to the period of the same
+// length before it, average unit costs are weighted by the purchased quantity.
+type MaterialCostTrend struct {
+	MaterialId              string                 `json:"material_id"`
+	MaterialName            string                 `json:"material_name"`
+	Unit                    string                 `json:"unit"`
+	From                    time.Time              `json:"from"`
+	To                      time.Time              `json:"to"`
+	Purchases               int                    `json:"purchases"`
+	AverageUnitCost         float64                `json:"average_unit_cost"`
+	PreviousAverageUnitCost float64                `json:"previous_average_unit_cost"`
+	ChangePercent           *float64               `json:"change_percent"`
+	FirstUnitCost           float64                `json:"first_unit_cost"`
+	LastUnitCost            float64                `json:"last_unit_cost"`
+	CheapestSupplier        *MaterialSupplierCost  `json:"cheapest_supplier"`
+	Suppliers               []MaterialSupplierCost `json:"suppliers"`
+	History                 []MaterialCostPoint    `json:"history,omitempty"`
+}
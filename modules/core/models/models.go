@@ -93,6 +93,13 @@ type OrderItemMaterial struct {
 	RefundReason string        `json:"refund_reason" bson:"refund_reason" mapstructure:"refund_reason"`
 }
 
+// MaterialEntryShare is the part of a material quantity allocated to one of its entries.
+type MaterialEntryShare struct {
+	EntryId  string  `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	Quantity float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	UnitCost float64 `json:"unit_cost" bson:"unit_cost" mapstructure:"unit_cost"`
+}
+
 // OrderItem represents an item in an order, including product details, materials, and pricing.
 type OrderItem struct {
 	Id                 string              `json:"id" bson:"id" mapstructure:"id"`
@@ -146,6 +153,10 @@ type Order struct {
 	IsDineIn     bool               `json:"is_dine_in" bson:"is_dine_in" mapstructure:"is_dine_in"`
 	CustomData   map[string]string  `json:"custom_data" bson:"custom_data" mapstructure:"custom_data"`
 	Tips         float64            `json:"tips" bson:"tips" mapstructure:"tips"`
+	// InvoiceNumber is the sequential fiscal invoice number, it is set once the order is paid.
+	InvoiceNumber uint64 `json:"invoice_number,omitempty" bson:"invoice_number,omitempty" mapstructure:"invoice_number,omitempty"`
+	// LocationId is the location the order consumes its materials from, any location when empty.
+	LocationId string `json:"location_id,omitempty" bson:"location_id,omitempty" mapstructure:"location_id"`
 }
 
 // MaterialEntry represents an entry of material, detailing purchase and quantity information.
@@ -157,6 +168,14 @@ type MaterialEntry struct {
 	Company          string    `json:"company" mapstructure:"company"`
 	SKU              string    `json:"sku" mapstructure:"sku"`
 	ExpirationDate   time.Time `json:"expiration_date" bson:"expiration_date" mapstructure:"expiration_date"`
+	// LotNumber is the batch number the supplier gave the goods, it identifies them on a recall.
+	LotNumber string `json:"lot_number,omitempty" bson:"lot_number,omitempty" mapstructure:"lot_number"`
+	// Unit is the unit the quantities are given in when the entry is added, they are stored in the material unit.
+	Unit string `json:"unit,omitempty" bson:"unit,omitempty" mapstructure:"unit"`
+	// SupplierId is set on the entries received against a purchase order.
+	SupplierId string `json:"supplier_id,omitempty" bson:"supplier_id,omitempty" mapstructure:"supplier_id"`
+	// LocationId is the location holding the entry, the entries without one are at the default location.
+	LocationId string `json:"location_id,omitempty" bson:"location_id,omitempty" mapstructure:"location_id"`
 }
 
 // Material represents a material with its details, including entries and settings.
@@ -166,7 +185,9 @@ type Material struct {
 	Entries  []MaterialEntry  `json:"entries" bson:"entries" mapstructure:"entries"`
 	Quantity float64          `json:"quantity" mapstructure:"quantity"`
 	Settings MaterialSettings `json:"settings" bson:"settings" mapstructure:"settings"`
-	Unit     string           `json:"unit" bson:"unit" mapstructure:"unit"`
+	// Unit is the unit the stock and the entries of the material are kept in, in a recipe it is the unit of the quantity.
+	Unit      string             `json:"unit" bson:"unit" mapstructure:"unit"`
+	PackSizes []MaterialPackSize `json:"pack_sizes" bson:"pack_sizes" mapstructure:"pack_sizes"`
 }
 
 // ProductEntry represents an entry of a product, detailing purchase and quantity information.
@@ -195,6 +216,8 @@ type Product struct {
 	EnableInventoryConsumption bool           `bson:"enable_inventory_consumption" json:"enable_inventory_consumption" mapstructure:"enable_inventory_consumption"`
 	EnableFixedCost            bool           `bson:"enable_fixed_cost" json:"enable_fixed_cost" mapstructure:"enable_fixed_cost"`
 	FixedCost                  float64        `bson:"fixed_cost" json:"fixed_cost" mapstructure:"fixed_cost"`
+	// ShelfLifeHours is how long ready stock of the product stays usable, it sets the expiry on prep labels.
+	ShelfLifeHours float64 `bson:"shelf_life_hours" json:"shelf_life_hours" mapstructure:"shelf_life_hours"`
 }
 
 // SalesLogs represents logs of sales, capturing sale price, items, and consumption details.
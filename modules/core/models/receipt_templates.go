@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+const (
+	ReceiptTemplateTypeClient  = "client"
+	ReceiptTemplateTypeKitchen = "kitchen"
+)
+
+// ReceiptTemplateVersion is a single saved revision of a receipt template content.
+type ReceiptTemplateVersion struct {
+	Version   int       `json:"version" bson:"version" mapstructure:"version"`
+	Content   string    `json:"content" bson:"content" mapstructure:"content"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at" mapstructure:"created_at"`
+	UserId    string    `json:"user_id" bson:"user_id" mapstructure:"user_id"`
+}
+
+// ReceiptTemplate is a handlebars receipt template stored in the database,
+// the Content field always holds the active version content.
+type ReceiptTemplate struct {
+	Id            string                   `json:"id" bson:"id" mapstructure:"id"`
+	Name          string                   `json:"name" bson:"name" mapstructure:"name"`
+	Type          string                   `json:"type" bson:"type" mapstructure:"type"` // client or kitchen
+	Content       string                   `json:"content" bson:"content" mapstructure:"content"`
+	ActiveVersion int                      `json:"active_version" bson:"active_version" mapstructure:"active_version"`
+	Versions      []ReceiptTemplateVersion `json:"versions" bson:"versions" mapstructure:"versions"`
+	CreatedAt     time.Time                `json:"created_at" bson:"created_at" mapstructure:"created_at"`
+	UpdatedAt     time.Time                `json:"updated_at" bson:"updated_at" mapstructure:"updated_at"`
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ReceiptArchive is a printed client receipt as it was rendered, it keeps the template content and
+// the rendered data so that reprints reproduce the original receipt regardless of later order changes.
+type ReceiptArchive struct {
+	Id              string    `json:"id" bson:"id" mapstructure:"id"`
+	OrderId         string    `json:"order_id" bson:"order_id" mapstructure:"order_id"`
+	DisplayId       string    `json:"display_id" bson:"display_id" mapstructure:"display_id"`
+	PrintedAt       time.Time `json:"printed_at" bson:"printed_at" mapstructure:"printed_at"`
+	UserId          string    `json:"user_id" bson:"user_id" mapstructure:"user_id"`
+	LangCode        string    `json:"lang_code" bson:"lang_code" mapstructure:"lang_code"`
+	PrinterHost     string    `json:"printer_host" bson:"printer_host" mapstructure:"printer_host"`
+	TemplateContent string    `json:"template_content" bson:"template_content" mapstructure:"template_content"`
+	Data            bson.M    `json:"data" bson:"data" mapstructure:"data"`
+	ReprintsCount   int       `json:"reprints_count" bson:"reprints_count" mapstructure:"reprints_count"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// ReorderSuggestion is the quantity of a material to order to restock it up to its par level. The quantities are
+// in the material Unit, except OrderQuantity which is given in OrderUnit, the unit the supplier sells it in.
+type ReorderSuggestion struct {
+	MaterialId       string  `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	MaterialName     string  `json:"material_name" bson:"material_name" mapstructure:"material_name"`
+	Unit             string  `json:"unit" bson:"unit" mapstructure:"unit"`
+	OnHand           float64 `json:"on_hand" bson:"on_hand" mapstructure:"on_hand"`
+	OnOrder          float64 `json:"on_order" bson:"on_order" mapstructure:"on_order"`
+	DailyConsumption float64 `json:"daily_consumption" bson:"daily_consumption" mapstructure:"daily_consumption"`
+	// DaysOfStock is how long the stock on hand lasts at the daily consumption, nil when nothing is consumed.
+	DaysOfStock       *float64 `json:"days_of_stock" bson:"days_of_stock" mapstructure:"days_of_stock"`
+	LeadTimeDays      int      `json:"lead_time_days" bson:"lead_time_days" mapstructure:"lead_time_days"`
+	ReorderPoint      float64  `json:"reorder_point" bson:"reorder_point" mapstructure:"reorder_point"`
+	ParLevel          float64  `json:"par_level" bson:"par_level" mapstructure:"par_level"`
+	SuggestedQuantity float64  `json:"suggested_quantity" bson:"suggested_quantity" mapstructure:"suggested_quantity"`
+	SupplierId        string   `json:"supplier_id" bson:"supplier_id" mapstructure:"supplier_id"`
+	SupplierName      string   `json:"supplier_name" bson:"supplier_name" mapstructure:"supplier_name"`
+	SKU               string   `json:"sku" bson:"sku" mapstructure:"sku"`
+	OrderQuantity     float64  `json:"order_quantity" bson:"order_quantity" mapstructure:"order_quantity"`
+	OrderUnit         string   `json:"order_unit" bson:"order_unit" mapstructure:"order_unit"`
+	UnitPrice         float64  `json:"unit_price" bson:"unit_price" mapstructure:"unit_price"`
+	EstimatedCost     float64  `json:"estimated_cost" bson:"estimated_cost" mapstructure:"estimated_cost"`
+}
+
+// ReorderReport is the reorder suggestions computed from the consumption between From and To.
+type ReorderReport struct {
+	From          time.Time           `json:"from" bson:"from" mapstructure:"from"`
+	To            time.Time           `json:"to" bson:"to" mapstructure:"to"`
+	Days          int                 `json:"days" bson:"days" mapstructure:"days"`
+	EstimatedCost float64             `json:"estimated_cost" bson:"estimated_cost" mapstructure:"estimated_cost"`
+	Suggestions   []ReorderSuggestion `json:"suggestions" bson:"suggestions" mapstructure:"suggestions"`
+}
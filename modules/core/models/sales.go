@@ -1,5 +1,7 @@
 package models
 
+import "time"
+
 // SalesPerDayOrder represents an order and its associated costs for a specific day.
 type SalesPerDayOrder struct {
 	Id    string     `json:"id" bson:"id" mapstructure:"id"`
@@ -46,3 +48,111 @@ type SalesPerDay struct {
 	TotalSales   float64            `json:"total_sales" bson:"total_sales" mapstructure:"total_sales"`
 	RefundsValue float64            `json:"refunds_value" bson:"refunds_value" mapstructure:"refunds_value"`
 }
+
+// SalesAnalyticsGroup holds the aggregated sales figures of one group of a sales analytics report,
+// refunds are attributed to the group of the refunded sale.
+type SalesAnalyticsGroup struct {
+	Key          interface{} `json:"key" bson:"_id"`
+	Name         string      `json:"name" bson:"name"`
+	OrdersCount  int64       `json:"orders_count" bson:"orders_count"`
+	Quantity     float64     `json:"quantity" bson:"quantity"`
+	Revenue      float64     `json:"revenue" bson:"revenue"`
+	Cost         float64     `json:"cost" bson:"cost"`
+	Margin       float64     `json:"margin" bson:"margin"`
+	RefundsCount int64       `json:"refunds_count" bson:"refunds_count"`
+	RefundsValue float64     `json:"refunds_value" bson:"refunds_value"`
+	RefundsCost  float64     `json:"refunds_cost" bson:"refunds_cost"`
+}
+
+// SalesExportLine is a sold order item as a row of the sales exports, the order discount is
+// shared between the items in proportion to their sale price.
+type SalesExportLine struct {
+	Date          time.Time `json:"date"`
+	BusinessDay   string    `json:"business_day"`
+	OrderId       string    `json:"order_id"`
+	DisplayId     string    `json:"display_id"`
+	InvoiceNumber uint64    `json:"invoice_number"`
+	PaymentSource string    `json:"payment_source"`
+	ServiceStyle  string    `json:"service_style"`
+	CustomerName  string    `json:"customer_name"`
+	ItemId        string    `json:"item_id"`
+	ProductId     string    `json:"product_id"`
+	ProductName   string    `json:"product_name"`
+	Quantity      float64   `json:"quantity"`
+	UnitPrice     float64   `json:"unit_price"`
+	SalePrice     float64   `json:"sale_price"`
+	Discount      float64   `json:"discount"`
+	NetSales      float64   `json:"net_sales"`
+	Tax           float64   `json:"tax"`
+	Cost          float64   `json:"cost"`
+	Margin        float64   `json:"margin"`
+}
+
+// ProfitLossCategory is the profit and loss of the products of a category, the sales are before order discounts.
+type ProfitLossCategory struct {
+	CategoryId    string  `json:"category_id"`
+	Name          string  `json:"name"`
+	Sales         float64 `json:"sales"`
+	Refunds       float64 `json:"refunds"`
+	NetSales      float64 `json:"net_sales"`
+	CostOfGoods   float64 `json:"cost_of_goods"`
+	GrossProfit   float64 `json:"gross_profit"`
+	WasteValue    float64 `json:"waste_value"`
+	DisposalValue float64 `json:"disposal_value"`
+	NetProfit     float64 `json:"net_profit"`
+}
+
+// ProfitLossReport is the profit and loss of a date range. The cost of goods excludes refunded items,
+// what happened to them afterwards shows in the waste and disposal values. Tips are reported but
+// don't count towards the profit as they are owed to the staff.
+type ProfitLossReport struct {
+	From          time.Time            `json:"from"`
+	To            time.Time            `json:"to"`
+	OrdersCount   int64                `json:"orders_count"`
+	GrossSales    float64              `json:"gross_sales"`
+	Discounts     float64              `json:"discounts"`
+	Refunds       float64              `json:"refunds"`
+	NetSales      float64              `json:"net_sales"`
+	CostOfGoods   float64              `json:"cost_of_goods"`
+	GrossProfit   float64              `json:"gross_profit"`
+	WasteValue    float64              `json:"waste_value"`
+	DisposalValue float64              `json:"disposal_value"`
+	NetProfit     float64              `json:"net_profit"`
+	Tips          float64              `json:"tips"`
+	Categories    []ProfitLossCategory `json:"categories"`
+}
+
+// SalesDayDiscrepancy compares a stored sales day with the day rebuilt from the logs and orders.
+type SalesDayDiscrepancy struct {
+	Date string `json:"date"`
+	// Documents is the number of stored documents of the day, concurrent first sales of a day can create more than one.
+	Documents            int     `json:"documents"`
+	StoredOrders         int     `json:"stored_orders"`
+	ExpectedOrders       int     `json:"expected_orders"`
+	StoredTotalSales     float64 `json:"stored_total_sales"`
+	ExpectedTotalSales   float64 `json:"expected_total_sales"`
+	StoredCosts          float64 `json:"stored_costs"`
+	ExpectedCosts        float64 `json:"expected_costs"`
+	StoredTips           float64 `json:"stored_tips"`
+	ExpectedTips         float64 `json:"expected_tips"`
+	StoredRefunds        int     `json:"stored_refunds"`
+	ExpectedRefunds      int     `json:"expected_refunds"`
+	StoredRefundsValue   float64 `json:"stored_refunds_value"`
+	ExpectedRefundsValue float64 `json:"expected_refunds_value"`
+	// MissingOrders are the ids of the logged orders the stored day lacks.
+	MissingOrders []string `json:"missing_orders"`
+	// UnloggedOrders are the ids of the stored orders without a log in the range, they are kept as stored.
+	UnloggedOrders  []string `json:"unlogged_orders"`
+	DuplicateOrders []string `json:"duplicate_orders"`
+	// MissingRefunds are the order_id/order_item_id of the logged refunds the stored day lacks.
+	MissingRefunds []string `json:"missing_refunds"`
+}
+
+// SalesReconciliation is the result of reconciling the sales days of a range of business days.
+type SalesReconciliation struct {
+	From          string                `json:"from"`
+	To            string                `json:"to"`
+	Days          int                   `json:"days"`
+	Discrepancies []SalesDayDiscrepancy `json:"discrepancies"`
+	Rewritten     bool                  `json:"rewritten"`
+}
@@ -1,9 +1,19 @@
 package models
 
+const (
+	EntryAllocationFIFO     = "fifo"
+	EntryAllocationFEFO     = "fefo"
+	EntryAllocationExplicit = "explicit"
+)
+
 // OrderQueueSettings represents the configuration settings for an order queue
 type OrderQueueSettings struct {
 	Prefix string `json:"prefix" bson:"prefix" mapstructure:"prefix"`
 	Next   uint32 `json:"next" bson:"next" mapstructure:"next"`
+	// ResetDaily restarts the queue numbering from 1 on every business day.
+	ResetDaily bool `json:"reset_daily" bson:"reset_daily" mapstructure:"reset_daily"`
+	// BusinessDay is the business day the last number of the queue was taken on.
+	BusinessDay string `json:"business_day" bson:"business_day" mapstructure:"business_day"`
 }
 
 // OrderSettings represents the configuration settings for orders
@@ -20,25 +30,92 @@ type LanguageSettings struct {
 // MaterialSettings represents settings associated with a material, such as stock alert threshold.
 type MaterialSettings struct {
 	StockAlertTreshold float64 `json:"stock_alert_treshold" bson:"stock_alert_treshold" mapstructure:"stock_alert_treshold"`
+	// EntryAllocation picks the entries a material is consumed from: fifo takes the oldest received first, fefo the
+	// soonest to expire first and explicit the entry chosen by the client. The setting of a material overrides
+	// the inventory one.
+	EntryAllocation string `json:"entry_allocation" bson:"entry_allocation" mapstructure:"entry_allocation"`
+	// ParLevel is the stock a material should have when a restock arrives, 0 derives it from the consumption over CoverDays.
+	ParLevel float64 `json:"par_level" bson:"par_level" mapstructure:"par_level"`
+	// CoverDays is the number of days of consumption a restock should last once received.
+	CoverDays int `json:"cover_days" bson:"cover_days" mapstructure:"cover_days"`
+	// ExpiryWarningDays is how many days before their expiration the entries are warned about.
+	ExpiryWarningDays int `json:"expiry_warning_days" bson:"expiry_warning_days" mapstructure:"expiry_warning_days"`
+}
+
+// InventorySettings are the material settings applying to the materials not overriding them, with the settings
+// only kept for the whole inventory.
+type InventorySettings struct {
+	MaterialSettings `json:",inline" bson:",inline" mapstructure:",squash"`
+	// CostAlertThresholdPercent is the unit cost increase over the previous purchase of a material that raises an alert, 0 disables it.
+	CostAlertThresholdPercent float64 `json:"cost_alert_threshold_percent" bson:"cost_alert_threshold_percent" mapstructure:"cost_alert_threshold_percent"`
+	// ConsumptionDays is the number of past days the daily consumption is averaged over.
+	ConsumptionDays int `json:"consumption_days" bson:"consumption_days" mapstructure:"consumption_days"`
+	// AutoWasteExpired wastes the stock left in expired entries.
+	AutoWasteExpired bool `json:"auto_waste_expired" bson:"auto_waste_expired" mapstructure:"auto_waste_expired"`
 }
 
 type PrinterSettings struct {
 	Host string `bson:"host" json:"host" mapstructure:"host"`
+	// TemplateId is the id of the receipt template used by the printer, empty uses the bundled template file.
+	TemplateId string `bson:"template_id" json:"template_id" mapstructure:"template_id"`
+}
+
+// KitchenStation is a named kitchen station with its own printer, order items are routed to
+// the first station listing their product or category, unrouted items go to the kitchen receipt printer.
+type KitchenStation struct {
+	Name        string          `bson:"name" json:"name" mapstructure:"name"`
+	Printer     PrinterSettings `bson:"printer" json:"printer" mapstructure:"printer"`
+	CategoryIds []string        `bson:"category_ids" json:"category_ids" mapstructure:"category_ids"`
+	ProductIds  []string        `bson:"product_ids" json:"product_ids" mapstructure:"product_ids"`
+}
+
+// LabelSettings configures the prep and expiry labels printer.
+type LabelSettings struct {
+	// Enabled prints labels automatically when products are added to ready stock and when material entries are received.
+	Enabled bool   `bson:"enabled" json:"enabled" mapstructure:"enabled"`
+	Host    string `bson:"host" json:"host" mapstructure:"host"`
+	// Format is the printer command language, zpl or escpos, used with the bundled templates.
+	Format string `bson:"format" json:"format" mapstructure:"format"`
+	// ProductTemplateId and MaterialTemplateId are label template ids, empty uses the bundled template of the format.
+	ProductTemplateId  string `bson:"product_template_id" json:"product_template_id" mapstructure:"product_template_id"`
+	MaterialTemplateId string `bson:"material_template_id" json:"material_template_id" mapstructure:"material_template_id"`
+}
+
+// FiscalSettings holds the seller fiscal identity printed on simplified tax invoices.
+type FiscalSettings struct {
+	// EInvoiceEnabled adds the fiscal fields and the e-invoice QR code to client receipts.
+	EInvoiceEnabled bool   `bson:"einvoice_enabled" json:"einvoice_enabled" mapstructure:"einvoice_enabled"`
+	SellerName      string `bson:"seller_name" json:"seller_name" mapstructure:"seller_name"`
+	VATNumber       string `bson:"vat_number" json:"vat_number" mapstructure:"vat_number"`
+	// SellerAddress, SellerPhone and SellerEmail are the company details printed on A4 invoices.
+	SellerAddress string `bson:"seller_address" json:"seller_address" mapstructure:"seller_address"`
+	SellerPhone   string `bson:"seller_phone" json:"seller_phone" mapstructure:"seller_phone"`
+	SellerEmail   string `bson:"seller_email" json:"seller_email" mapstructure:"seller_email"`
+	// VATRate is the tax percentage, e.g. 15 for 15%.
+	VATRate float64 `bson:"vat_rate" json:"vat_rate" mapstructure:"vat_rate"`
+	// PricesIncludeVAT determines whether product prices already include the tax.
+	PricesIncludeVAT bool `bson:"prices_include_vat" json:"prices_include_vat" mapstructure:"prices_include_vat"`
 }
 
 // Settings represents the configuration settings structure
 type Settings struct {
-	Id                    string           `bson:"id,omitempty" json:"id" mapstructure:"id"`
-	Inventory             MaterialSettings `bson:"inventory" json:"inventory" mapstructure:"inventory"`
-	Orders                OrderSettings    `bson:"orders" json:"orders" mapstructure:"orders"`
-	Language              LanguageSettings `bson:"language" json:"language" mapstructure:"language"`
-	ClientReceiptPrinter  PrinterSettings  `bson:"client_receipt_printer" json:"client_receipt_printer" mapstructure:"client_receipt_printer"`
-	KitchenReceiptPrinter PrinterSettings  `bson:"kitchen_receipt_printer" json:"kitchen_receipt_printer" mapstructure:"kitchen_receipt_printer"`
-	PaymentSources        []PaymentSource  `bson:"payment_sources" json:"payment_sources" mapstructure:"payment_sources"`
+	Id                    string            `bson:"id,omitempty" json:"id" mapstructure:"id"`
+	Inventory             InventorySettings `bson:"inventory" json:"inventory" mapstructure:"inventory"`
+	Orders                OrderSettings     `bson:"orders" json:"orders" mapstructure:"orders"`
+	Language              LanguageSettings  `bson:"language" json:"language" mapstructure:"language"`
+	ClientReceiptPrinter  PrinterSettings   `bson:"client_receipt_printer" json:"client_receipt_printer" mapstructure:"client_receipt_printer"`
+	KitchenReceiptPrinter PrinterSettings   `bson:"kitchen_receipt_printer" json:"kitchen_receipt_printer" mapstructure:"kitchen_receipt_printer"`
+	KitchenStations       []KitchenStation  `bson:"kitchen_stations" json:"kitchen_stations" mapstructure:"kitchen_stations"`
+	PaymentSources        []PaymentSource   `bson:"payment_sources" json:"payment_sources" mapstructure:"payment_sources"`
+	Fiscal                FiscalSettings    `bson:"fiscal" json:"fiscal" mapstructure:"fiscal"`
+	Labels                LabelSettings     `bson:"labels" json:"labels" mapstructure:"labels"`
 	// ShopMode determines the operational mode: "" (unset/first-run), "kitchen", or "retail"
-	ShopMode string `bson:"shop_mode" json:"shop_mode" mapstructure:"shop_mode"`
+	ShopMode    string              `bson:"shop_mode" json:"shop_mode" mapstructure:"shop_mode"`
+	WasteDigest WasteDigestSettings `bson:"waste_digest" json:"waste_digest" mapstructure:"waste_digest"`
 }
 
 type PaymentSource struct {
 	Name string `bson:"name" json:"name" mapstructure:"name"`
+	// IsCash opens the cash drawer connected to the client receipt printer when an order is paid with this source.
+	IsCash bool `bson:"is_cash" json:"is_cash" mapstructure:"is_cash"`
 }
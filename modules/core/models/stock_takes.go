@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+const (
+	StockTakeStateOpen      = "open"
+	StockTakeStatePosted    = "posted"
+	StockTakeStateCancelled = "cancelled"
+)
+
+// StockTakeLine is a material entry of a stock take. ExpectedQuantity is the entry quantity when the stock take
+// was opened, CountedQuantity stays nil until the entry is counted and uncounted lines aren't adjusted.
+type StockTakeLine struct {
+	MaterialId       string   `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	MaterialName     string   `json:"material_name" bson:"material_name" mapstructure:"material_name"`
+	EntryId          string   `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	SKU              string   `json:"sku" bson:"sku" mapstructure:"sku"`
+	Unit             string   `json:"unit" bson:"unit" mapstructure:"unit"`
+	UnitCost         float64  `json:"unit_cost" bson:"unit_cost" mapstructure:"unit_cost"`
+	ExpectedQuantity float64  `json:"expected_quantity" bson:"expected_quantity" mapstructure:"expected_quantity"`
+	CountedQuantity  *float64 `json:"counted_quantity" bson:"counted_quantity" mapstructure:"counted_quantity"`
+	VarianceQuantity float64  `json:"variance_quantity" bson:"variance_quantity" mapstructure:"variance_quantity"`
+	VarianceValue    float64  `json:"variance_value" bson:"variance_value" mapstructure:"variance_value"`
+	// AdjustedQuantity is the quantity the entry was adjusted by when the stock take was posted, it differs from
+	// the variance when the entry moved between the count and the posting.
+	AdjustedQuantity float64   `json:"adjusted_quantity" bson:"adjusted_quantity" mapstructure:"adjusted_quantity"`
+	CountedAt        time.Time `json:"counted_at" bson:"counted_at" mapstructure:"counted_at"`
+	CountedBy        string    `json:"counted_by" bson:"counted_by" mapstructure:"counted_by"`
+	Comment          string    `json:"comment" bson:"comment" mapstructure:"comment"`
+}
+
+// StockTakeCount is a counted quantity of a material entry given in Unit, the material unit when empty.
+type StockTakeCount struct {
+	MaterialId string  `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	EntryId    string  `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	Quantity   float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	Unit       string  `json:"unit" bson:"unit" mapstructure:"unit"`
+	Comment    string  `json:"comment" bson:"comment" mapstructure:"comment"`
+}
+
+// StockTake is a physical count of the stock, MaterialIds limits it to some materials and LocationId to the
+// entries of a location when set.
+type StockTake struct {
+	Id            string          `json:"id" bson:"id" mapstructure:"id"`
+	Name          string          `json:"name" bson:"name" mapstructure:"name"`
+	State         string          `json:"state" bson:"state" mapstructure:"state"`
+	Notes         string          `json:"notes" bson:"notes" mapstructure:"notes"`
+	MaterialIds   []string        `json:"material_ids" bson:"material_ids" mapstructure:"material_ids"`
+	LocationId    string          `json:"location_id" bson:"location_id" mapstructure:"location_id"`
+	Lines         []StockTakeLine `json:"lines" bson:"lines" mapstructure:"lines"`
+	CountedLines  int             `json:"counted_lines" bson:"counted_lines" mapstructure:"counted_lines"`
+	ExpectedValue float64         `json:"expected_value" bson:"expected_value" mapstructure:"expected_value"`
+	VarianceValue float64         `json:"variance_value" bson:"variance_value" mapstructure:"variance_value"`
+	CreatedAt     time.Time       `json:"created_at" bson:"created_at" mapstructure:"created_at"`
+	PostedAt      time.Time       `json:"posted_at" bson:"posted_at" mapstructure:"posted_at"`
+	UserId        string          `json:"user_id" bson:"user_id" mapstructure:"user_id"`
+	PostedBy      string          `json:"posted_by" bson:"posted_by" mapstructure:"posted_by"`
+}
+
+// StockTakeMaterialVariance is the variance of a material summed over the posted stock takes of a period.
+type StockTakeMaterialVariance struct {
+	MaterialId       string  `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	MaterialName     string  `json:"material_name" bson:"material_name" mapstructure:"material_name"`
+	Unit             string  `json:"unit" bson:"unit" mapstructure:"unit"`
+	StockTakes       int     `json:"stock_takes" bson:"stock_takes" mapstructure:"stock_takes"`
+	ExpectedQuantity float64 `json:"expected_quantity" bson:"expected_quantity" mapstructure:"expected_quantity"`
+	CountedQuantity  float64 `json:"counted_quantity" bson:"counted_quantity" mapstructure:"counted_quantity"`
+	VarianceQuantity float64 `json:"variance_quantity" bson:"variance_quantity" mapstructure:"variance_quantity"`
+	VarianceValue    float64 `json:"variance_value" bson:"variance_value" mapstructure:"variance_value"`
+}
+
+// StockTakeSummary is a posted stock take without its lines.
+type StockTakeSummary struct {
+	Id            string    `json:"id" bson:"id" mapstructure:"id"`
+	Name          string    `json:"name" bson:"name" mapstructure:"name"`
+	PostedAt      time.Time `json:"posted_at" bson:"posted_at" mapstructure:"posted_at"`
+	CountedLines  int       `json:"counted_lines" bson:"counted_lines" mapstructure:"counted_lines"`
+	ExpectedValue float64   `json:"expected_value" bson:"expected_value" mapstructure:"expected_value"`
+	VarianceValue float64   `json:"variance_value" bson:"variance_value" mapstructure:"variance_value"`
+}
+
+// StockTakeReport is the history of the stock takes posted between From and To, the materials with the largest
+// variance value first.
+type StockTakeReport struct {
+	From          time.Time                   `json:"from" bson:"from" mapstructure:"from"`
+	To            time.Time                   `json:"to" bson:"to" mapstructure:"to"`
+	VarianceValue float64                     `json:"variance_value" bson:"variance_value" mapstructure:"variance_value"`
+	StockTakes    []StockTakeSummary          `json:"stock_takes" bson:"stock_takes" mapstructure:"stock_takes"`
+	Materials     []StockTakeMaterialVariance `json:"materials" bson:"materials" mapstructure:"materials"`
+}
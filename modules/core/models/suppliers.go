@@ -0,0 +1,94 @@
+package models
+
+import "time"
+
+const (
+	PurchaseOrderStateDraft             = "draft"
+	PurchaseOrderStateSent              = "sent"
+	PurchaseOrderStatePartiallyReceived = "partially_received"
+	PurchaseOrderStateReceived          = "received"
+	PurchaseOrderStateCancelled         = "cancelled"
+)
+
+type SupplierContact struct {
+	Name  string `json:"name" bson:"name" mapstructure:"name"`
+	Role  string `json:"role" bson:"role" mapstructure:"role"`
+	Phone string `json:"phone" bson:"phone" mapstructure:"phone"`
+	Email string `json:"email" bson:"email" mapstructure:"email"`
+}
+
+// SupplierMaterial is a material a supplier sells along with its agreed price per Unit.
+type SupplierMaterial struct {
+	MaterialId string  `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	SKU        string  `json:"sku" bson:"sku" mapstructure:"sku"`
+	Price      float64 `json:"price" bson:"price" mapstructure:"price"`
+	Unit       string  `json:"unit" bson:"unit" mapstructure:"unit"`
+}
+
+type Supplier struct {
+	Id       string            `json:"id" bson:"id" mapstructure:"id"`
+	Name     string            `json:"name" bson:"name" mapstructure:"name"`
+	Address  string            `json:"address" bson:"address" mapstructure:"address"`
+	Contacts []SupplierContact `json:"contacts" bson:"contacts" mapstructure:"contacts"`
+	// LeadTimeDays is the number of days between sending a purchase order and the goods arriving.
+	LeadTimeDays int                `json:"lead_time_days" bson:"lead_time_days" mapstructure:"lead_time_days"`
+	Materials    []SupplierMaterial `json:"materials" bson:"materials" mapstructure:"materials"`
+	Notes        string             `json:"notes" bson:"notes" mapstructure:"notes"`
+}
+
+// PurchaseOrderLine is a material ordered from the supplier, Quantity and UnitPrice are given in Unit.
+type PurchaseOrderLine struct {
+	Id               string  `json:"id" bson:"id" mapstructure:"id"`
+	MaterialId       string  `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	MaterialName     string  `json:"material_name" bson:"material_name" mapstructure:"material_name"`
+	SKU              string  `json:"sku" bson:"sku" mapstructure:"sku"`
+	Quantity         float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	Unit             string  `json:"unit" bson:"unit" mapstructure:"unit"`
+	UnitPrice        float64 `json:"unit_price" bson:"unit_price" mapstructure:"unit_price"`
+	ReceivedQuantity float64 `json:"received_quantity" bson:"received_quantity" mapstructure:"received_quantity"`
+}
+
+// PurchaseOrderReceiptLine is the quantity of a purchase order line received in its unit, InvoicedUnitPrice
+// is the price on the supplier invoice when it differs from the agreed one.
+type PurchaseOrderReceiptLine struct {
+	LineId            string    `json:"line_id" bson:"line_id" mapstructure:"line_id"`
+	Quantity          float64   `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	InvoicedUnitPrice float64   `json:"invoiced_unit_price" bson:"invoiced_unit_price" mapstructure:"invoiced_unit_price"`
+	ExpirationDate    time.Time `json:"expiration_date" bson:"expiration_date" mapstructure:"expiration_date"`
+	LotNumber         string    `json:"lot_number" bson:"lot_number" mapstructure:"lot_number"`
+	EntryId           string    `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	Comment           string    `json:"comment" bson:"comment" mapstructure:"comment"`
+}
+
+// PurchaseOrderReceipt is a delivery received against a purchase order.
+type PurchaseOrderReceipt struct {
+	Id     string                     `json:"id" bson:"id" mapstructure:"id"`
+	Date   time.Time                  `json:"date" bson:"date" mapstructure:"date"`
+	UserId string                     `json:"user_id" bson:"user_id" mapstructure:"user_id"`
+	Lines  []PurchaseOrderReceiptLine `json:"lines" bson:"lines" mapstructure:"lines"`
+	// CloseOrder tells the receipt closes the purchase order even if lines are received short.
+	CloseOrder bool `json:"close_order" bson:"close_order" mapstructure:"close_order"`
+}
+
+type PurchaseOrder struct {
+	Id           string                 `json:"id" bson:"id" mapstructure:"id"`
+	SupplierId   string                 `json:"supplier_id" bson:"supplier_id" mapstructure:"supplier_id"`
+	SupplierName string                 `json:"supplier_name" bson:"supplier_name" mapstructure:"supplier_name"`
+	State        string                 `json:"state" bson:"state" mapstructure:"state"`
+	Lines        []PurchaseOrderLine    `json:"lines" bson:"lines" mapstructure:"lines"`
+	Total        float64                `json:"total" bson:"total" mapstructure:"total"`
+	Notes        string                 `json:"notes" bson:"notes" mapstructure:"notes"`
+	CreatedAt    time.Time              `json:"created_at" bson:"created_at" mapstructure:"created_at"`
+	SentAt       time.Time              `json:"sent_at" bson:"sent_at" mapstructure:"sent_at"`
+	ExpectedAt   time.Time              `json:"expected_at" bson:"expected_at" mapstructure:"expected_at"`
+	ReceivedAt   time.Time              `json:"received_at" bson:"received_at" mapstructure:"received_at"`
+	Receipts     []PurchaseOrderReceipt `json:"receipts" bson:"receipts" mapstructure:"receipts"`
+	UserId       string                 `json:"user_id" bson:"user_id" mapstructure:"user_id"`
+	// LocationId is the location the goods are delivered to, the default location when empty.
+	LocationId string `json:"location_id,omitempty" bson:"location_id,omitempty" mapstructure:"location_id"`
+	// Version is increased by every receipt so that two receipts can't both start from the same received quantities.
+	Version int `json:"version" bson:"version" mapstructure:"version"`
+	// PendingReceipt is the receipt being added to stock, with the ids of its entries decided up front so that an
+	// interrupted receipt is completed without adding its entries twice.
+	PendingReceipt *PurchaseOrderReceipt `json:"pending_receipt,omitempty" bson:"pending_receipt,omitempty" mapstructure:"pending_receipt"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+const (
+	// TraceViaMaterial is an order that consumed the traced entries for its products.
+	TraceViaMaterial = "material"
+	// TraceViaReadyProduct is an order served from the ready stock of a product holding the traced entries.
+	TraceViaReadyProduct = "ready_product"
+)
+
+// TraceQuery selects the material entries to trace, by entry id, SKU or supplier lot number.
+type TraceQuery struct {
+	EntryId   string `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	SKU       string `json:"sku" bson:"sku" mapstructure:"sku"`
+	LotNumber string `json:"lot_number" bson:"lot_number" mapstructure:"lot_number"`
+}
+
+// TracedEntry is a material entry of the traced lot. SourceEntryId is set on the entries received from another
+// location, it is the entry they were transferred from.
+type TracedEntry struct {
+	MaterialId     string    `json:"material_id" bson:"material_id" mapstructure:"material_id"`
+	MaterialName   string    `json:"material_name" bson:"material_name" mapstructure:"material_name"`
+	Unit           string    `json:"unit" bson:"unit" mapstructure:"unit"`
+	EntryId        string    `json:"entry_id" bson:"entry_id" mapstructure:"entry_id"`
+	SKU            string    `json:"sku" bson:"sku" mapstructure:"sku"`
+	LotNumber      string    `json:"lot_number" bson:"lot_number" mapstructure:"lot_number"`
+	Company        string    `json:"company" bson:"company" mapstructure:"company"`
+	SupplierId     string    `json:"supplier_id" bson:"supplier_id" mapstructure:"supplier_id"`
+	LocationId     string    `json:"location_id" bson:"location_id" mapstructure:"location_id"`
+	ExpirationDate time.Time `json:"expiration_date" bson:"expiration_date" mapstructure:"expiration_date"`
+	// Quantity is what is left in stock of the entry.
+	Quantity      float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	SourceEntryId string  `json:"source_entry_id,omitempty" bson:"source_entry_id,omitempty" mapstructure:"source_entry_id"`
+}
+
+// TracedOrder is an order that used the traced lot, directly or through the ready stock of a product.
+type TracedOrder struct {
+	OrderId     string    `json:"order_id" bson:"order_id" mapstructure:"order_id"`
+	DisplayId   string    `json:"display_id" bson:"display_id" mapstructure:"display_id"`
+	SubmittedAt time.Time `json:"submitted_at" bson:"submitted_at" mapstructure:"submitted_at"`
+	State       string    `json:"state" bson:"state" mapstructure:"state"`
+	Customer    Customer  `json:"customer" bson:"customer" mapstructure:"customer"`
+	LocationId  string    `json:"location_id" bson:"location_id" mapstructure:"location_id"`
+	Via         string    `json:"via" bson:"via" mapstructure:"via"`
+	// ViaProductId is the ready product the order was served from when it didn't consume the lot itself.
+	ViaProductId string   `json:"via_product_id,omitempty" bson:"via_product_id,omitempty" mapstructure:"via_product_id"`
+	Products     []string `json:"products" bson:"products" mapstructure:"products"`
+	EntryIds     []string `json:"entry_ids" bson:"entry_ids" mapstructure:"entry_ids"`
+	// Quantity is the quantity of the lot the order consumed, in the material unit.
+	Quantity float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+}
+
+// TracedProduct is a product or sub-product made with the traced lot.
+type TracedProduct struct {
+	ProductId   string  `json:"product_id" bson:"product_id" mapstructure:"product_id"`
+	ProductName string  `json:"product_name" bson:"product_name" mapstructure:"product_name"`
+	Orders      int     `json:"orders" bson:"orders" mapstructure:"orders"`
+	Quantity    float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	// FromReady tells some orders were served the product from a ready stock holding the lot.
+	FromReady bool `json:"from_ready" bson:"from_ready" mapstructure:"from_ready"`
+}
+
+// TracedCustomer is a customer served an order that used the traced lot.
+type TracedCustomer struct {
+	Customer Customer `json:"customer" bson:"customer" mapstructure:"customer"`
+	OrderIds []string `json:"order_ids" bson:"order_ids" mapstructure:"order_ids"`
+}
+
+// TraceReport lists everything that used the traced lot, to recall it.
+type TraceReport struct {
+	Date      time.Time        `json:"date" bson:"date" mapstructure:"date"`
+	Query     TraceQuery       `json:"query" bson:"query" mapstructure:"query"`
+	Entries   []TracedEntry    `json:"entries" bson:"entries" mapstructure:"entries"`
+	Orders    []TracedOrder    `json:"orders" bson:"orders" mapstructure:"orders"`
+	Products  []TracedProduct  `json:"products" bson:"products" mapstructure:"products"`
+	Customers []TracedCustomer `json:"customers" bson:"customers" mapstructure:"customers"`
+}
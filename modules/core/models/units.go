@@ -0,0 +1,25 @@
+package models
+
+const (
+	UnitDimensionMass   = "mass"
+	UnitDimensionVolume = "volume"
+	UnitDimensionCount  = "count"
+)
+
+// Unit is a unit of measure of the units registry, Factor converts a quantity of the unit to the base unit
+// of its dimension (g, ml or pcs).
+type Unit struct {
+	Symbol    string   `json:"symbol" bson:"symbol" mapstructure:"symbol"`
+	Name      string   `json:"name" bson:"name" mapstructure:"name"`
+	Dimension string   `json:"dimension" bson:"dimension" mapstructure:"dimension"`
+	Factor    float64  `json:"factor" bson:"factor" mapstructure:"factor"`
+	Aliases   []string `json:"aliases" bson:"aliases" mapstructure:"aliases"`
+}
+
+// MaterialPackSize is a purchase or recipe unit specific to a material, one Name holds Quantity of Unit,
+// e.g. a sack of 25 kg.
+type MaterialPackSize struct {
+	Name     string  `json:"name" bson:"name" mapstructure:"name"`
+	Quantity float64 `json:"quantity" bson:"quantity" mapstructure:"quantity"`
+	Unit     string  `json:"unit" bson:"unit" mapstructure:"unit"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+const (
+	WasteSourceMaterialWaste    = "material_waste"
+	WasteSourceProductWaste     = "product_waste"
+	WasteSourceOrderItemWaste   = "order_item_waste"
+	WasteSourceMaterialDisposal = "material_disposal"
+	WasteSourceProductDisposal  = "product_disposal"
+)
+
+// WasteRecord is a waste or disposal log normalized for reporting, Value is the cost of the lost stock.
+type WasteRecord struct {
+	Date       time.Time `json:"date"`
+	Source     string    `json:"source"`
+	IsDisposal bool      `json:"is_disposal"`
+	MaterialId string    `json:"material_id,omitempty"`
+	EntryId    string    `json:"entry_id,omitempty"`
+	ProductId  string    `json:"product_id,omitempty"`
+	Name       string    `json:"name"`
+	OrderId    string    `json:"order_id,omitempty"`
+	Reason     string    `json:"reason"`
+	UserId     string    `json:"user_id"`
+	Quantity   float64   `json:"quantity"`
+	Value      float64   `json:"value"`
+}
+
+// WasteAnalyticsGroup holds the waste of one group of a waste analytics report along with the prior period
+// figures, ValueChangePercent is nil when the group had no waste in the prior period.
+type WasteAnalyticsGroup struct {
+	Key                string   `json:"key"`
+	Name               string   `json:"name"`
+	Count              int      `json:"count"`
+	Quantity           float64  `json:"quantity"`
+	Value              float64  `json:"value"`
+	PreviousCount      int      `json:"previous_count"`
+	PreviousQuantity   float64  `json:"previous_quantity"`
+	PreviousValue      float64  `json:"previous_value"`
+	ValueChangePercent *float64 `json:"value_change_percent"`
+}
+
+// WasteAnalytics is the waste and disposals of a date range compared to the period of the same length before it.
+type WasteAnalytics struct {
+	From               time.Time             `json:"from"`
+	To                 time.Time             `json:"to"`
+	PreviousFrom       time.Time             `json:"previous_from"`
+	Count              int                   `json:"count"`
+	WasteValue         float64               `json:"waste_value"`
+	DisposalValue      float64               `json:"disposal_value"`
+	TotalValue         float64               `json:"total_value"`
+	PreviousTotalValue float64               `json:"previous_total_value"`
+	ValueChangePercent *float64              `json:"value_change_percent"`
+	Groups             []WasteAnalyticsGroup `json:"groups"`
+}
+
+// WasteDigestSettings configures the weekly waste digest pushed to the waste_digest notifications topic.
+type WasteDigestSettings struct {
+	Enabled bool `bson:"enabled" json:"enabled" mapstructure:"enabled"`
+	// Weekday is the day the digest is sent on, 0 for Sunday to 6 for Saturday.
+	Weekday int `bson:"weekday" json:"weekday" mapstructure:"weekday"`
+	// Hour is the hour of the day, in the configured timezone, from which the digest is sent.
+	Hour int `bson:"hour" json:"hour" mapstructure:"hour"`
+	// TopN is the number of materials and products listed in the digest.
+	TopN int `bson:"top_n" json:"top_n" mapstructure:"top_n"`
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ValidateEntryAllocation checks the entry allocation strategy is a known one, empty uses the default.
+func ValidateEntryAllocation(strategy string) error {
+	switch strategy {
+	case "", models.EntryAllocationFIFO, models.EntryAllocationFEFO, models.EntryAllocationExplicit:
+		return nil
+	}
+
+	return fmt.Errorf("unknown entry allocation %q, expected %s, %s or %s", strategy, models.EntryAllocationFIFO, models.EntryAllocationFEFO, models.EntryAllocationExplicit)
+}
+
+// EntryAllocationStrategy returns the strategy the material is consumed with: its own setting, else the inventory
+// one. Without either, the exact cost method consumes the chosen entry and the average one the oldest entries
+// first, as they did before the strategies were configurable.
+func (ms *MaterialService) EntryAllocationStrategy(material models.Material) string {
+	if material.Settings.EntryAllocation != "" {
+		return material.Settings.EntryAllocation
+	}
+
+	if ms.Settings.Inventory.EntryAllocation != "" {
+		return ms.Settings.Inventory.EntryAllocation
+	}
+
+	if ms.Settings.Orders.DefaultCostCalculationMethod == "exact" {
+		return models.EntryAllocationExplicit
+	}
+
+	return models.EntryAllocationFIFO
+}
+
+// entryReceivedAt returns when the entry was received, which the object id it was given on receipt holds.
+// Entries with other ids are taken as the oldest.
+func entryReceivedAt(entry models.MaterialEntry) time.Time {
+	object_id, err := primitive.ObjectIDFromHex(entry.Id)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return object_id.Timestamp()
+}
+
+// orderedEntries returns the entries of the material in the order the strategy consumes them. The explicit
+// strategy only returns the chosen entry, or falls back to fifo when no entry was chosen.
+func orderedEntries(material models.Material, strategy string, entry_id string) []models.MaterialEntry {
+	entries := make([]models.MaterialEntry, 0, len(material.Entries))
+
+	if strategy == models.EntryAllocationExplicit && entry_id != "" {
+		for _, entry := range material.Entries {
+			if entry.Id == entry_id {
+				entries = append(entries, entry)
+			}
+		}
+
+		return entries
+	}
+
+	entries = append(entries, material.Entries...)
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if strategy == models.EntryAllocationFEFO && !entries[i].ExpirationDate.Equal(entries[j].ExpirationDate) {
+			// entries without an expiration date go last
+			if entries[i].ExpirationDate.IsZero() || entries[j].ExpirationDate.IsZero() {
+				return entries[j].ExpirationDate.IsZero()
+			}
+
+			return entries[i].ExpirationDate.Before(entries[j].ExpirationDate)
+		}
+
+		return entryReceivedAt(entries[i]).Before(entryReceivedAt(entries[j]))
+	})
+
+	return entries
+}
+
+// entryUnitCost returns the purchase cost of one unit of the entry.
+func entryUnitCost(entry models.MaterialEntry) float64 {
+	if entry.PurchaseQuantity == 0 {
+		return 0
+	}
+
+	return entry.PurchasePrice / entry.PurchaseQuantity
+}
+
+// AllocateEntries splits quantity over the entries of the material holding stock, in the order of the strategy.
+// remaining is the part of the quantity the stock doesn't cover.
+func AllocateEntries(material models.Material, strategy string, entry_id string, quantity float64) (shares []models.MaterialEntryShare, remaining float64) {
+	shares = make([]models.MaterialEntryShare, 0)
+	remaining = quantity
+
+	for _, entry := range orderedEntries(material, strategy, entry_id) {
+		if remaining <= 0 || nearlyEqual(remaining, 0) {
+			return shares, 0
+		}
+
+		if entry.Quantity <= 0 {
+			continue
+		}
+
+		share := remaining
+		if entry.Quantity < share {
+			share = entry.Quantity
+		}
+
+		shares = append(shares, models.MaterialEntryShare{
+			EntryId:  entry.Id,
+			Quantity: share,
+			UnitCost: entryUnitCost(entry),
+		})
+		remaining -= share
+	}
+
+	if nearlyEqual(remaining, 0) {
+		remaining = 0
+	}
+
+	return shares, remaining
+}
+
+// costShares allocates quantity like the consumption would, the part the stock doesn't cover is costed at the
+// last entry allocated, or at the last entry of the strategy order when nothing is in stock.
+func costShares(material models.Material, strategy string, entry_id string, quantity float64) ([]models.MaterialEntryShare, error) {
+	shares, remaining := AllocateEntries(material, strategy, entry_id, quantity)
+	if remaining <= 0 {
+		return shares, nil
+	}
+
+	if len(shares) > 0 {
+		shares[len(shares)-1].Quantity += remaining
+		return shares, nil
+	}
+
+	entries := orderedEntries(material, strategy, entry_id)
+	if len(entries) == 0 {
+		return shares, fmt.Errorf("no entries found for material %s", material.Id)
+	}
+
+	last := entries[len(entries)-1]
+
+	return append(shares, models.MaterialEntryShare{
+		EntryId:  last.Id,
+		Quantity: remaining,
+		UnitCost: entryUnitCost(last),
+	}), nil
+}
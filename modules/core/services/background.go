@@ -9,6 +9,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/nutrixpos/pos/common"
@@ -16,71 +17,171 @@ import (
 	"github.com/nutrixpos/pos/common/logger"
 	"github.com/nutrixpos/pos/modules/core/models"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// CheckExpirationDates is a background job that checks all materials if they are expired
-// and informs the admin about it. The function is designed to be called periodically
-// by the job scheduler.
+// CheckExpirationDates is a background job that notifies the material entries expiring within the warning
+// days of their material and the expired ones, and wastes the expired stock when the inventory settings ask to.
 func CheckExpirationDates(log logger.ILogger, conf config.Config, notification_svc INotificationService) {
 
 	log.Info("core:background: Checking expiration dates")
 
+	settings_svc := SettingsService{
+		Config: conf,
+	}
+
+	settings, err := settings_svc.GetSettings()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	expiry_svc := ExpiryService{
+		Logger:   log,
+		Config:   conf,
+		Settings: settings,
+	}
+
+	err = expiry_svc.CheckExpirations(notification_svc)
+	if err != nil {
+		log.Error(err.Error())
+	}
+}
+
+// SendWasteDigest is a background job that pushes the waste of the last 7 business days, compared to the
+// 7 days before them, to the waste_digest topic. It is meant to run hourly and only sends the digest once on
+// the configured weekday, from the configured hour on, every sent digest is logged.
+func SendWasteDigest(log logger.ILogger, conf config.Config, notification_svc INotificationService) {
+
+	settings_svc := SettingsService{
+		Config: conf,
+	}
+
+	settings, err := settings_svc.GetSettings()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	digest := settings.WasteDigest
+	if !digest.Enabled {
+		return
+	}
+
+	business_day, err := NewBusinessDay(conf)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	now := time.Now().In(business_day.Location)
+	if int(now.Weekday()) != digest.Weekday || now.Hour() < digest.Hour {
+		return
+	}
+
 	client, err := common.GetDatabaseClient(log, &conf)
 	if err != nil {
 		log.Error(err.Error())
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 1000*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
 	defer cancel()
 
-	err = client.Ping(ctx, nil)
+	collection := client.Database(conf.Databases[0].Database).Collection("logs")
+
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, business_day.Location)
+
+	sent, err := collection.CountDocuments(ctx, bson.M{"type": models.LogTypeWasteDigest, "date": bson.M{"$gte": today}})
 	if err != nil {
 		log.Error(err.Error())
 		return
 	}
 
-	collection := client.Database(conf.Databases[0].Name).Collection("materials")
-	cursor, err := collection.Find(ctx, bson.M{})
+	if sent > 0 {
+		return
+	}
+
+	log.Info("core:background: Sending the waste digest")
+
+	to, err := business_day.Start(business_day.Today())
 	if err != nil {
 		log.Error(err.Error())
 		return
 	}
-	defer cursor.Close(ctx)
+	from := to.AddDate(0, 0, -7)
 
-	// Iterate over the documents and print them as JSON
-	for cursor.Next(ctx) {
-		var component models.Material
-		err := cursor.Decode(&component)
-		if err != nil {
-			log.Error(err.Error())
-			return
-		}
+	waste_svc := WasteService{
+		Logger: log,
+		Config: conf,
+	}
 
-		for _, entry := range component.Entries {
-			t := time.Until(entry.ExpirationDate)
-			if t <= 14*24*time.Hour {
+	top := digest.TopN
+	if top <= 0 {
+		top = 5
+	}
 
-				msg := fmt.Sprintf("Material %s, entry %s will expire within 2 weeks", component.Name, entry.Id)
+	materials, err := waste_svc.GetWasteAnalytics(WasteGroupByMaterial, from, to, top)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
 
-				log.Warning(msg)
+	products, err := waste_svc.GetWasteAnalytics(WasteGroupByProduct, from, to, top)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
 
-				topic_msg := &models.WebsocketTopicServerMessage{
-					Type:      "topic_message",
-					TopicName: "expire_soon",
-					Message:   msg,
-					Severity:  "warn",
-					Date:      time.Now(),
-				}
+	msg := fmt.Sprintf("Waste from %s to %s: %.2f (waste %.2f, disposals %.2f)", from.Format("2006-01-02"), to.AddDate(0, 0, -1).Format("2006-01-02"), materials.TotalValue, materials.WasteValue, materials.DisposalValue)
+	if materials.ValueChangePercent != nil {
+		msg += fmt.Sprintf(", %+.2f%% from the previous week", *materials.ValueChangePercent)
+	}
 
-				jsonstr, err := json.Marshal(topic_msg)
-				if err != nil {
-					log.Error(err.Error())
-					return
-				}
+	for _, list := range []struct {
+		title  string
+		groups []models.WasteAnalyticsGroup
+	}{{"Top materials", materials.Groups}, {"Top products", products.Groups}} {
+		if len(list.groups) == 0 {
+			continue
+		}
 
-				notification_svc.SendToTopic("expire_soon", string(jsonstr))
-			}
+		items := make([]string, 0, len(list.groups))
+		for _, group := range list.groups {
+			items = append(items, fmt.Sprintf("%s %.2f", group.Name, group.Value))
 		}
+
+		msg += fmt.Sprintf(". %s: %s", list.title, strings.Join(items, ", "))
+	}
+
+	topic_msg := &models.WebsocketTopicServerMessage{
+		Type:      "topic_message",
+		TopicName: "waste_digest",
+		Message:   msg,
+		Severity:  "info",
+		Date:      now,
+	}
+
+	jsonstr, err := json.Marshal(topic_msg)
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	notification_svc.SendToTopic("waste_digest", string(jsonstr))
+
+	_, err = collection.InsertOne(ctx, models.LogWasteDigest{
+		Log: models.Log{
+			Type:   models.LogTypeWasteDigest,
+			Date:   now,
+			Id:     primitive.NewObjectID().Hex(),
+			UserId: "0",
+		},
+		From:    from,
+		To:      to,
+		Message: msg,
+	})
+	if err != nil {
+		log.Error(err.Error())
 	}
 }
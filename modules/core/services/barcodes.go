@@ -0,0 +1,348 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrInvalidBarcode = errors.New("invalid barcode")
+
+// BarcodeService resolves scanned barcodes and SKUs to products and materials.
+type BarcodeService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// isDigits tells whether code is made of digits only.
+func isDigits(code string) bool {
+	for _, char := range code {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+
+	return code != ""
+}
+
+// gtinCheckDigit returns the check digit of the EAN/UPC digits, the check digit excluded. The digits are weighted
+// 3 and 1 alternately from the right.
+func gtinCheckDigit(digits string) int {
+	sum := 0
+	for index := len(digits) - 1; index >= 0; index-- {
+		digit := int(digits[index] - '0')
+		if (len(digits)-1-index)%2 == 0 {
+			digit *= 3
+		}
+		sum += digit
+	}
+
+	return (10 - sum%10) % 10
+}
+
+// Code128CheckValue returns the symbol check value of data encoded in the code set B, which the scanners verify
+// and strip. Only printable ascii characters can be encoded.
+func Code128CheckValue(data string) (int, error) {
+	sum := 104 // start B
+	for index, char := range data {
+		if char < 32 || char > 126 {
+			return 0, fmt.Errorf("%w: %q can't be encoded in code 128", ErrInvalidBarcode, char)
+		}
+
+		sum += (index + 1) * int(char-32)
+	}
+
+	return sum % 103, nil
+}
+
+// ValidateBarcode checks the code against the format and returns it trimmed. An empty format recognizes the
+// format: 13, 12 and 8 digits are EAN-13, UPC-A and EAN-8 with their check digit verified, anything else printable
+// is code 128.
+func ValidateBarcode(code string, format string) (models.Barcode, error) {
+	barcode := models.Barcode{
+		Code:   strings.TrimSpace(code),
+		Format: format,
+	}
+
+	if barcode.Code == "" {
+		return barcode, fmt.Errorf("%w: the code is empty", ErrInvalidBarcode)
+	}
+
+	if barcode.Format == "" {
+		barcode.Format = models.BarcodeFormatCode128
+
+		if isDigits(barcode.Code) {
+			switch len(barcode.Code) {
+			case 13:
+				barcode.Format = models.BarcodeFormatEAN13
+			case 12:
+				barcode.Format = models.BarcodeFormatUPCA
+			case 8:
+				barcode.Format = models.BarcodeFormatEAN8
+			}
+		}
+	}
+
+	lengths := map[string]int{
+		models.BarcodeFormatEAN13: 13,
+		models.BarcodeFormatUPCA:  12,
+		models.BarcodeFormatEAN8:  8,
+	}
+
+	switch barcode.Format {
+	case models.BarcodeFormatEAN13, models.BarcodeFormatUPCA, models.BarcodeFormatEAN8:
+		length := lengths[barcode.Format]
+		if len(barcode.Code) != length || !isDigits(barcode.Code) {
+			return barcode, fmt.Errorf("%w: %s codes are %d digits", ErrInvalidBarcode, barcode.Format, length)
+		}
+
+		if check := gtinCheckDigit(barcode.Code[:length-1]); int(barcode.Code[length-1]-'0') != check {
+			return barcode, fmt.Errorf("%w: wrong check digit, expected %d", ErrInvalidBarcode, check)
+		}
+	case models.BarcodeFormatCode128:
+		if _, err := Code128CheckValue(barcode.Code); err != nil {
+			return barcode, err
+		}
+	default:
+		return barcode, fmt.Errorf("%w: unknown format %q, expected %s, %s, %s or %s", ErrInvalidBarcode, barcode.Format, models.BarcodeFormatEAN13, models.BarcodeFormatUPCA, models.BarcodeFormatEAN8, models.BarcodeFormatCode128)
+	}
+
+	return barcode, nil
+}
+
+// barcodeCandidates returns the codes the barcode may be stored as, a UPC-A code is the EAN-13 code with a leading 0.
+func barcodeCandidates(barcode models.Barcode) []string {
+	candidates := []string{barcode.Code}
+
+	switch barcode.Format {
+	case models.BarcodeFormatUPCA:
+		candidates = append(candidates, "0"+barcode.Code)
+	case models.BarcodeFormatEAN13:
+		if strings.HasPrefix(barcode.Code, "0") {
+			candidates = append(candidates, barcode.Code[1:])
+		}
+	}
+
+	return candidates
+}
+
+// EnsureIndexes indexes the SKUs the lookups search by, it is run when the core module starts.
+func (bs BarcodeService) EnsureIndexes(ctx context.Context) error {
+	client, err := common.GetDatabaseClient(bs.Logger, &bs.Config)
+	if err != nil {
+		return err
+	}
+
+	db := client.Database(bs.Config.Databases[0].Database)
+
+	indexes := []struct {
+		Collection string
+		Key        string
+	}{
+		{Collection: "recipes", Key: "entries.sku"},
+		{Collection: "materials", Key: "entries.sku"},
+		{Collection: "suppliers", Key: "materials.sku"},
+	}
+
+	for _, index := range indexes {
+		_, err = db.Collection(index.Collection).Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys: bson.M{index.Key: 1},
+		})
+		if err != nil {
+			return fmt.Errorf("indexing %s of %s: %w", index.Key, index.Collection, err)
+		}
+	}
+
+	return nil
+}
+
+// database returns the database the lookups search.
+func (bs BarcodeService) database() (*mongo.Database, error) {
+	client, err := common.GetDatabaseClient(bs.Logger, &bs.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.Database(bs.Config.Databases[0].Database), nil
+}
+
+// LookupProduct returns the product having an entry with the barcode and that entry.
+func (bs BarcodeService) LookupProduct(barcode models.Barcode) (product models.Product, entry models.ProductEntry, err error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	db, err := bs.database()
+	if err != nil {
+		return product, entry, err
+	}
+
+	candidates := barcodeCandidates(barcode)
+
+	err = db.Collection("recipes").FindOne(ctx, bson.M{"entries.sku": bson.M{"$in": candidates}}).Decode(&product)
+	if err != nil {
+		return product, entry, err
+	}
+
+	for _, product_entry := range product.Entries {
+		for _, candidate := range candidates {
+			if product_entry.SKU == candidate {
+				return product, product_entry, nil
+			}
+		}
+	}
+
+	return product, entry, nil
+}
+
+// LookupMaterial returns the material whose entries carry the barcode, with only those entries, the latest received
+// first. Materials no entry carries the barcode of yet are found by the SKU their suppliers sell them under, the
+// supplier is returned then.
+func (bs BarcodeService) LookupMaterial(barcode models.Barcode) (material models.Material, supplier models.Supplier, supplier_material models.SupplierMaterial, err error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	db, err := bs.database()
+	if err != nil {
+		return material, supplier, supplier_material, err
+	}
+
+	candidates := barcodeCandidates(barcode)
+	matches := func(sku string) bool {
+		for _, candidate := range candidates {
+			if sku == candidate {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	err = db.Collection("materials").FindOne(ctx, bson.M{"entries.sku": bson.M{"$in": candidates}}).Decode(&material)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return material, supplier, supplier_material, err
+	}
+
+	material_id := material.Id
+
+	err = db.Collection("suppliers").FindOne(ctx, bson.M{"materials.sku": bson.M{"$in": candidates}}).Decode(&supplier)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return material, supplier, supplier_material, err
+	}
+
+	for _, candidate_material := range supplier.Materials {
+		if matches(candidate_material.SKU) && (material_id == "" || candidate_material.MaterialId == material_id) {
+			supplier_material = candidate_material
+			break
+		}
+	}
+
+	if supplier_material.MaterialId == "" {
+		supplier = models.Supplier{}
+	}
+
+	if material_id == "" {
+		if supplier_material.MaterialId == "" {
+			return material, supplier, supplier_material, mongo.ErrNoDocuments
+		}
+
+		err = db.Collection("materials").FindOne(ctx, bson.M{"id": supplier_material.MaterialId}).Decode(&material)
+		if err != nil {
+			return material, supplier, supplier_material, err
+		}
+	}
+
+	entries := make([]models.MaterialEntry, 0)
+	for _, entry := range material.Entries {
+		if matches(entry.SKU) {
+			entries = append(entries, entry)
+		}
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entryReceivedAt(entries[i]).After(entryReceivedAt(entries[j]))
+	})
+
+	material.Entries = entries
+
+	return material, supplier, supplier_material, nil
+}
+
+// Scan validates a scanned code and resolves it for the mode, the shop mode decides when empty: retail shops
+// add the products to orders and the others receive materials. When nothing is found for the mode, the other
+// kind is tried, the result tells which one was found.
+func (bs BarcodeService) Scan(code string, format string, mode string) (result models.ScanResult, err error) {
+
+	result.Barcode, err = ValidateBarcode(code, format)
+	if err != nil {
+		return result, err
+	}
+
+	result.Mode = mode
+	if result.Mode == "" {
+		result.Mode = models.ScanModeReceive
+		if bs.Settings.ShopMode == "retail" {
+			result.Mode = models.ScanModeOrder
+		}
+	}
+
+	if result.Mode != models.ScanModeOrder && result.Mode != models.ScanModeReceive {
+		return result, fmt.Errorf("%w: unknown scan mode %q, expected %s or %s", ErrInvalidBarcode, result.Mode, models.ScanModeOrder, models.ScanModeReceive)
+	}
+
+	lookups := []func() (bool, error){
+		func() (bool, error) {
+			product, entry, err := bs.LookupProduct(result.Barcode)
+			if err == mongo.ErrNoDocuments {
+				return false, nil
+			} else if err != nil {
+				return false, err
+			}
+
+			result.Product = &product
+			result.ProductEntry = &entry
+			return true, nil
+		},
+		func() (bool, error) {
+			material, supplier, supplier_material, err := bs.LookupMaterial(result.Barcode)
+			if err == mongo.ErrNoDocuments {
+				return false, nil
+			} else if err != nil {
+				return false, err
+			}
+
+			result.Material = &material
+			if supplier_material.MaterialId != "" {
+				result.SupplierId = supplier.Id
+				result.SupplierName = supplier.Name
+				result.SupplierMaterial = &supplier_material
+			}
+			return true, nil
+		},
+	}
+
+	if result.Mode == models.ScanModeReceive {
+		lookups[0], lookups[1] = lookups[1], lookups[0]
+	}
+
+	for _, lookup := range lookups {
+		result.Found, err = lookup()
+		if err != nil || result.Found {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
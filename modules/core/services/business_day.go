@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nutrixpos/pos/common/config"
+)
+
+// BusinessDay computes the business days sales are recorded on, days are taken in the configured
+// timezone and roll over at the configured cutoff hour instead of midnight.
+type BusinessDay struct {
+	Location   *time.Location
+	CutoffHour int
+}
+
+// NewBusinessDay returns the business day of the config, the server timezone is used when none is configured.
+func NewBusinessDay(config config.Config) (BusinessDay, error) {
+	bd := BusinessDay{
+		Location:   time.Local,
+		CutoffHour: config.BusinessDayCutoffHour,
+	}
+
+	if bd.CutoffHour < 0 || bd.CutoffHour > 23 {
+		return bd, fmt.Errorf("business_day_cutoff_hour must be between 0 and 23, got %d", bd.CutoffHour)
+	}
+
+	if config.TimeZone != "" {
+		location, err := time.LoadLocation(config.TimeZone)
+		if err != nil {
+			return bd, err
+		}
+
+		bd.Location = location
+	}
+
+	return bd, nil
+}
+
+// Of returns the business day (2006-01-02) the moment t belongs to.
+func (bd BusinessDay) Of(t time.Time) string {
+	local := t.In(bd.Location)
+	if local.Hour() < bd.CutoffHour {
+		local = local.AddDate(0, 0, -1)
+	}
+
+	return local.Format("2006-01-02")
+}
+
+// Today returns the current business day.
+func (bd BusinessDay) Today() string {
+	return bd.Of(time.Now())
+}
+
+// Start returns the moment the business day (2006-01-02) begins.
+func (bd BusinessDay) Start(day string) (time.Time, error) {
+	date, err := time.ParseInLocation("2006-01-02", day, bd.Location)
+	if err != nil {
+		return date, err
+	}
+
+	return time.Date(date.Year(), date.Month(), date.Day(), bd.CutoffHour, 0, 0, 0, bd.Location), nil
+}
+
+// Range returns the moments the business days from and to (inclusive) begin and end.
+func (bd BusinessDay) Range(from string, to string) (start time.Time, end time.Time, err error) {
+	start, err = bd.Start(from)
+	if err != nil {
+		return
+	}
+
+	end, err = bd.Start(to)
+	if err != nil {
+		return
+	}
+
+	return start, end.AddDate(0, 0, 1), nil
+}
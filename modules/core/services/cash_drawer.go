@@ -0,0 +1,142 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	CashDrawerReasonOrderPayment = "order_payment"
+	CashDrawerReasonNoSale       = "no_sale"
+)
+
+// drawerPulse is the ESC/POS "generate pulse" command, ESC p m t1 t2, on drawer pin 2 with a 50ms on time and a 500ms off time.
+var drawerPulse = []byte{0x1B, 0x70, 0x00, 0x19, 0xFA}
+
+// CashDrawerService opens the cash drawer connected to the client receipt printer and audits every opening.
+type CashDrawerService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// Kick sends the drawer pulse to the printer at printer_host.
+func (ds *CashDrawerService) Kick(printer_host string) error {
+	if printer_host == "" {
+		return errors.New("no receipt printer configured for the cash drawer")
+	}
+
+	socket, err := net.DialTimeout("tcp", fmt.Sprintf("%s:9100", printer_host), 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+
+	_, err = socket.Write(drawerPulse)
+	return err
+}
+
+// IsCashPayment reports whether the payment source with the given name is flagged as cash.
+func (ds *CashDrawerService) IsCashPayment(source_name string) bool {
+	for _, source := range ds.Settings.PaymentSources {
+		if source.Name == source_name {
+			return source.IsCash
+		}
+	}
+
+	return false
+}
+
+// KickForOrder opens the drawer when the order was paid with a cash payment source, it does nothing otherwise.
+func (ds *CashDrawerService) KickForOrder(order models.Order, user_id string) error {
+	if !order.IsPaid || !ds.IsCashPayment(order.PaymentSource) {
+		return nil
+	}
+
+	return ds.open(CashDrawerReasonOrderPayment, order.Id, user_id)
+}
+
+// OpenDrawer opens the drawer without a sale, the opening is logged against user_id.
+func (ds *CashDrawerService) OpenDrawer(user_id string) error {
+	return ds.open(CashDrawerReasonNoSale, "", user_id)
+}
+
+func (ds *CashDrawerService) open(reason string, order_id string, user_id string) error {
+	printer_host := ds.Settings.ClientReceiptPrinter.Host
+
+	err := ds.Kick(printer_host)
+	if err != nil {
+		return err
+	}
+
+	client, err := common.GetDatabaseClient(ds.Logger, &ds.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	log := models.LogCashDrawerOpen{
+		Log: models.Log{
+			Id:     primitive.NewObjectID().Hex(),
+			Type:   models.LogTypeCashDrawerOpen,
+			Date:   time.Now(),
+			UserId: user_id,
+		},
+		Reason:      reason,
+		OrderId:     order_id,
+		PrinterHost: printer_host,
+	}
+
+	_, err = client.Database(ds.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, log)
+	return err
+}
+
+// GetOpenings returns the audited drawer openings, newest first.
+func (ds *CashDrawerService) GetOpenings(page_number int, page_size int) (openings []models.LogCashDrawerOpen, total_records int64, err error) {
+	openings = make([]models.LogCashDrawerOpen, 0)
+
+	client, err := common.GetDatabaseClient(ds.Logger, &ds.Config)
+	if err != nil {
+		return openings, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := client.Database(ds.Config.Databases[0].Database).Collection("logs")
+	filter := bson.M{"type": models.LogTypeCashDrawerOpen}
+
+	skip := int64((page_number - 1) * page_size)
+	limit := int64(page_size)
+
+	cursor, err := collection.Find(ctx, filter, &options.FindOptions{
+		Skip:  &skip,
+		Limit: &limit,
+		Sort:  bson.M{"date": -1},
+	})
+	if err != nil {
+		return openings, 0, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &openings)
+	if err != nil {
+		return openings, 0, err
+	}
+
+	total_records, err = collection.CountDocuments(ctx, filter)
+	return openings, total_records, err
+}
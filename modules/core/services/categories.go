@@ -133,3 +133,37 @@ func (cs *CategoryService) GetCategories(page_number int, page_size int) (catego
 
 	return categories, nil
 }
+
+// GetProductCategories maps every product id to the first category listing it, the categories are returned without their products.
+func (cs *CategoryService) GetProductCategories() (map[string]models.Category, error) {
+	product_categories := make(map[string]models.Category)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := common.GetDatabaseClient(cs.Logger, &cs.Config)
+	if err != nil {
+		return product_categories, err
+	}
+
+	cur, err := client.Database(cs.Config.Databases[0].Database).Collection("categories").Find(ctx, bson.D{})
+	if err != nil {
+		return product_categories, err
+	}
+	defer cur.Close(ctx)
+
+	for cur.Next(ctx) {
+		var category models.Category
+		if err := cur.Decode(&category); err != nil {
+			return product_categories, err
+		}
+
+		for _, product := range category.Products {
+			if _, ok := product_categories[product.Id]; !ok {
+				product_categories[product.Id] = models.Category{Id: category.Id, Name: category.Name}
+			}
+		}
+	}
+
+	return product_categories, cur.Err()
+}
@@ -0,0 +1,188 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+)
+
+var (
+	ErrDigitalReceiptsDisabled = errors.New("digital receipts are disabled")
+	ErrInvalidReceiptToken     = errors.New("invalid digital receipt token")
+	ErrExpiredReceiptToken     = errors.New("digital receipt link expired")
+)
+
+// DigitalReceiptService signs and verifies the public receipt links and renders the linked receipts.
+type DigitalReceiptService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+func (ds *DigitalReceiptService) secret() ([]byte, error) {
+	secret := ds.Config.DigitalReceipts.Secret
+	if secret == "" {
+		secret = ds.Config.Auth.JWTSecret
+	}
+
+	if secret == "" {
+		return nil, errors.New("digital receipts secret is not configured")
+	}
+
+	return []byte(secret), nil
+}
+
+func (ds *DigitalReceiptService) sign(payload string) ([]byte, error) {
+	secret, err := ds.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil), nil
+}
+
+// SignToken returns an unguessable token of the order that is valid until expires_at.
+func (ds *DigitalReceiptService) SignToken(order_id string, expires_at time.Time) (string, error) {
+	payload := fmt.Sprintf("%s|%d", order_id, expires_at.Unix())
+
+	signature, err := ds.sign(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload)) + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyToken checks the token signature and expiry and returns the order id it was signed for.
+func (ds *DigitalReceiptService) VerifyToken(token string) (order_id string, err error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", ErrInvalidReceiptToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", ErrInvalidReceiptToken
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ErrInvalidReceiptToken
+	}
+
+	expected, err := ds.sign(string(payload))
+	if err != nil {
+		return "", err
+	}
+
+	if !hmac.Equal(signature, expected) {
+		return "", ErrInvalidReceiptToken
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 2 {
+		return "", ErrInvalidReceiptToken
+	}
+
+	expires_at, err := strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", ErrInvalidReceiptToken
+	}
+
+	if time.Now().Unix() > expires_at {
+		return "", ErrExpiredReceiptToken
+	}
+
+	return fields[0], nil
+}
+
+// ReceiptURL returns the signed public url of the order receipt and its expiry time.
+func (ds *DigitalReceiptService) ReceiptURL(order_id string) (url string, expires_at time.Time, err error) {
+	if !ds.Config.DigitalReceipts.Enabled {
+		return "", expires_at, ErrDigitalReceiptsDisabled
+	}
+
+	expire_hrs := ds.Config.DigitalReceipts.ExpireHrs
+	if expire_hrs <= 0 {
+		expire_hrs = 30 * 24
+	}
+
+	expires_at = time.Now().Add(time.Duration(expire_hrs) * time.Hour)
+
+	token, err := ds.SignToken(order_id, expires_at)
+	if err != nil {
+		return "", expires_at, err
+	}
+
+	url = fmt.Sprintf("%s/api/public/receipts/%s", strings.TrimRight(ds.Config.DigitalReceipts.BaseURL, "/"), token)
+	return url, expires_at, nil
+}
+
+// RenderHTML renders the receipt of the order behind the token, the original printed receipt
+// is served when it was archived, otherwise the receipt is rendered from the current order.
+func (ds *DigitalReceiptService) RenderHTML(token string, lang_code string) (string, error) {
+	if !ds.Config.DigitalReceipts.Enabled {
+		return "", ErrDigitalReceiptsDisabled
+	}
+
+	order_id, err := ds.VerifyToken(token)
+	if err != nil {
+		return "", err
+	}
+
+	archive_svc := ReceiptArchiveService{
+		Logger:   ds.Logger,
+		Config:   ds.Config,
+		Settings: ds.Settings,
+	}
+
+	receipts, err := archive_svc.GetOrderReceipts(order_id)
+	if err != nil {
+		return "", err
+	}
+
+	if len(receipts) > 0 {
+		return archive_svc.RenderArchivedHTML(receipts[0], false)
+	}
+
+	order_svc := OrderService{
+		Logger:   ds.Logger,
+		Config:   ds.Config,
+		Settings: ds.Settings,
+	}
+
+	order, err := order_svc.GetOrder(order_id)
+	if err != nil {
+		return "", err
+	}
+
+	template_svc := ReceiptTemplateService{
+		Logger:   ds.Logger,
+		Config:   ds.Config,
+		Settings: ds.Settings,
+	}
+
+	template, err := template_svc.GetPrinterTemplate(ds.Settings.ClientReceiptPrinter, DefaultClientReceiptTemplateFile)
+	if err != nil {
+		return "", err
+	}
+
+	receipt_svc := ReceiptService{
+		Config:   ds.Config,
+		Logger:   ds.Logger,
+		Settings: ds.Settings,
+	}
+
+	return receipt_svc.RenderHTML(order, order.Discount, 0, order.SubmittedAt, lang_code, template, ds.Settings.ShopMode)
+}
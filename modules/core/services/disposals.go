@@ -219,6 +219,17 @@ func (ds *DisposalService) AddMaterialDisposal(disposal models.MaterialDisposal,
 		Disposal: disposal,
 	}
 
+	material_svc := MaterialService{
+		Logger: ds.Logger,
+		Config: ds.Config,
+	}
+
+	unit_cost, err := material_svc.EntryUnitCost(disposal.MaterialId, disposal.EntryId)
+	if err != nil {
+		ds.Logger.Error(err.Error())
+	}
+	disposal_add_log.Value = disposal.Quantity * unit_cost
+
 	_, err = client.Database(ds.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, disposal_add_log)
 	if err != nil {
 		ds.Logger.Error(err.Error())
@@ -0,0 +1,125 @@
+package services
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image/png"
+	"math"
+	"time"
+
+	"github.com/aymerick/raymond"
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/code128"
+	"github.com/boombuler/barcode/qr"
+	"github.com/nutrixpos/pos/modules/core/models"
+)
+
+// EInvoice holds the fiscal values of a simplified tax invoice.
+type EInvoice struct {
+	SellerName string
+	VATNumber  string
+	Timestamp  time.Time
+	// Total is the invoice total including the tax.
+	Total float64
+	// VATTotal is the tax amount included in Total.
+	VATTotal float64
+}
+
+// NewEInvoice calculates the tax of the order total according to the fiscal settings.
+// total is the order total after discount as priced in the shop.
+func NewEInvoice(fiscal models.FiscalSettings, total float64, timestamp time.Time) EInvoice {
+	invoice := EInvoice{
+		SellerName: fiscal.SellerName,
+		VATNumber:  fiscal.VATNumber,
+		Timestamp:  timestamp,
+	}
+
+	rate := fiscal.VATRate / 100
+
+	if fiscal.PricesIncludeVAT {
+		invoice.Total = total
+		invoice.VATTotal = total - total/(1+rate)
+	} else {
+		invoice.VATTotal = total * rate
+		invoice.Total = total + invoice.VATTotal
+	}
+
+	invoice.Total = math.Round(invoice.Total*100) / 100
+	invoice.VATTotal = math.Round(invoice.VATTotal*100) / 100
+
+	return invoice
+}
+
+// EncodeTLV encodes the invoice as the base64 TLV payload used by e-invoice QR codes,
+// the tags are 1 seller name, 2 vat number, 3 timestamp, 4 total with vat and 5 vat total.
+func (e EInvoice) EncodeTLV() (string, error) {
+	fields := []string{
+		e.SellerName,
+		e.VATNumber,
+		e.Timestamp.UTC().Format(time.RFC3339),
+		fmt.Sprintf("%.2f", e.Total),
+		fmt.Sprintf("%.2f", e.VATTotal),
+	}
+
+	var buf bytes.Buffer
+	for index, value := range fields {
+		if len(value) > 255 {
+			return "", fmt.Errorf("e-invoice tag %d value exceeds 255 bytes", index+1)
+		}
+
+		buf.WriteByte(byte(index + 1))
+		buf.WriteByte(byte(len(value)))
+		buf.WriteString(value)
+	}
+
+	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// barcodeImageTag scales the code and returns it as an html img tag with an embedded png.
+func barcodeImageTag(code barcode.Barcode, width int, height int) (string, error) {
+	scaled, err := barcode.Scale(code, width, height)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	err = png.Encode(&buf, scaled)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`<img src="data:image/png;base64,%s" width="%d" height="%d" />`, base64.StdEncoding.EncodeToString(buf.Bytes()), width, height), nil
+}
+
+// RegisterReceiptCodeHelpers registers the "qrcode" and "barcode" handlebars helpers,
+// they render the given value as an inline image: {{qrcode einvoice_qr 250}} or {{barcode order_id 400 80}}.
+func RegisterReceiptCodeHelpers(template *raymond.Template) {
+	template.RegisterHelper("qrcode", func(value string, size int) raymond.SafeString {
+		code, err := qr.Encode(value, qr.M, qr.Auto)
+		if err != nil {
+			return ""
+		}
+
+		tag, err := barcodeImageTag(code, size, size)
+		if err != nil {
+			return ""
+		}
+
+		return raymond.SafeString(tag)
+	})
+
+	template.RegisterHelper("barcode", func(value string, width int, height int) raymond.SafeString {
+		code, err := code128.Encode(value)
+		if err != nil {
+			return ""
+		}
+
+		tag, err := barcodeImageTag(code, width, height)
+		if err != nil {
+			return ""
+		}
+
+		return raymond.SafeString(tag)
+	})
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ExpiryWasteReason is the reason the expired entries are wasted with.
+const ExpiryWasteReason = "expired"
+
+// ExpiryService watches the expiration dates of the material entries.
+type ExpiryService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// expiryWarningDays returns how many days before their expiration the entries of the material are warned about.
+func (es ExpiryService) expiryWarningDays(material models.Material) int {
+	if material.Settings.ExpiryWarningDays > 0 {
+		return material.Settings.ExpiryWarningDays
+	}
+
+	if es.Settings.Inventory.ExpiryWarningDays > 0 {
+		return es.Settings.Inventory.ExpiryWarningDays
+	}
+
+	return 14
+}
+
+// expiringEntries returns the entries of the material with stock left that expired or expire within the warning
+// days, days overrides the warning days of the material when positive. Entries without an expiration date never expire.
+func (es ExpiryService) expiringEntries(material models.Material, days int, now time.Time) []models.ExpiryReportEntry {
+	entries := make([]models.ExpiryReportEntry, 0)
+
+	if days <= 0 {
+		days = es.expiryWarningDays(material)
+	}
+
+	horizon := now.AddDate(0, 0, days)
+
+	for _, entry := range material.Entries {
+		if entry.Quantity <= 0 || entry.ExpirationDate.IsZero() || entry.ExpirationDate.After(horizon) {
+			continue
+		}
+
+		status := models.ExpiryStatusExpiring
+		if !entry.ExpirationDate.After(now) {
+			status = models.ExpiryStatusExpired
+		}
+
+		entries = append(entries, models.ExpiryReportEntry{
+			MaterialId:     material.Id,
+			MaterialName:   material.Name,
+			Unit:           material.Unit,
+			EntryId:        entry.Id,
+			SKU:            entry.SKU,
+			Company:        entry.Company,
+			Quantity:       entry.Quantity,
+			ExpirationDate: entry.ExpirationDate,
+			DaysLeft:       math.Round(entry.ExpirationDate.Sub(now).Hours()/24*100) / 100,
+			Status:         status,
+			Value:          entry.Quantity * entryUnitCost(entry),
+		})
+	}
+
+	return entries
+}
+
+// materials returns all the materials.
+func (es ExpiryService) materials(ctx context.Context) ([]models.Material, error) {
+	materials := make([]models.Material, 0)
+
+	client, err := common.GetDatabaseClient(es.Logger, &es.Config)
+	if err != nil {
+		return materials, err
+	}
+
+	cursor, err := client.Database(es.Config.Databases[0].Database).Collection("materials").Find(ctx, bson.M{})
+	if err != nil {
+		return materials, err
+	}
+
+	err = cursor.All(ctx, &materials)
+	return materials, err
+}
+
+// GetExpiryReport returns the entries with stock that expired or expire within the warning days of their material,
+// or within days when positive.
+func (es ExpiryService) GetExpiryReport(days int) (report models.ExpiryReport, err error) {
+
+	report = models.ExpiryReport{
+		Date:    time.Now(),
+		Entries: make([]models.ExpiryReportEntry, 0),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	materials, err := es.materials(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	for _, material := range materials {
+		for _, entry := range es.expiringEntries(material, days, report.Date) {
+			if entry.Status == models.ExpiryStatusExpired {
+				report.ExpiredValue += entry.Value
+			} else {
+				report.ExpiringValue += entry.Value
+			}
+
+			report.Entries = append(report.Entries, entry)
+		}
+	}
+
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		return report.Entries[i].ExpirationDate.Before(report.Entries[j].ExpirationDate)
+	})
+
+	return report, nil
+}
+
+// CheckExpirations notifies the entries expiring soon on the expire_soon topic and the expired ones on the
+// expired topic, each only once per expiration date. When the inventory auto wastes expired stock, the expired
+// entries are wasted with the expired reason instead.
+func (es ExpiryService) CheckExpirations(notification_svc INotificationService) error {
+
+	client, err := common.GetDatabaseClient(es.Logger, &es.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	materials, err := es.materials(ctx)
+	if err != nil {
+		return err
+	}
+
+	logs_collection := client.Database(es.Config.Databases[0].Database).Collection("logs")
+
+	material_svc := MaterialService{
+		Logger:   es.Logger,
+		Config:   es.Config,
+		Settings: es.Settings,
+	}
+
+	now := time.Now()
+
+	for _, material := range materials {
+		for _, entry := range es.expiringEntries(material, 0, now) {
+
+			wasted := false
+			if entry.Status == models.ExpiryStatusExpired && es.Settings.Inventory.AutoWasteExpired {
+				err = material_svc.Waste(entry.EntryId, entry.MaterialId, entry.Quantity, "", ExpiryWasteReason, false, "0")
+				if err != nil {
+					es.Logger.Error(err.Error())
+					continue
+				}
+				wasted = true
+			} else {
+				sent, err := logs_collection.CountDocuments(ctx, bson.M{
+					"type":            models.LogTypeExpiryAlert,
+					"entry_id":        entry.EntryId,
+					"status":          entry.Status,
+					"expiration_date": entry.ExpirationDate,
+				})
+				if err != nil {
+					return err
+				}
+
+				if sent > 0 {
+					continue
+				}
+			}
+
+			topic_msg := models.WebsocketTopicServerMessage{
+				Type:      "topic_message",
+				TopicName: "expire_soon",
+				Message:   fmt.Sprintf("Material %s, entry %s will expire on %s", material.Name, entry.EntryId, entry.ExpirationDate.Format("2006-01-02")),
+				Severity:  "warn",
+				Date:      now,
+				Key:       fmt.Sprintf("expire_soon@%s", entry.EntryId),
+			}
+
+			if entry.Status == models.ExpiryStatusExpired {
+				topic_msg.TopicName = "expired"
+				topic_msg.Message = fmt.Sprintf("Material %s, entry %s expired on %s with %.2f %s left", material.Name, entry.EntryId, entry.ExpirationDate.Format("2006-01-02"), entry.Quantity, material.Unit)
+				topic_msg.Severity = "error"
+				topic_msg.Key = fmt.Sprintf("expired@%s", entry.EntryId)
+
+				if wasted {
+					topic_msg.Message += ", it was wasted"
+				}
+			}
+
+			es.Logger.Warning(topic_msg.Message)
+
+			json_msg, err := json.Marshal(topic_msg)
+			if err != nil {
+				return err
+			}
+
+			err = notification_svc.SendToTopic(topic_msg.TopicName, string(json_msg))
+			if err != nil {
+				es.Logger.Error(err.Error())
+			}
+
+			log := models.LogExpiryAlert{
+				Log: models.Log{
+					Type:   models.LogTypeExpiryAlert,
+					Id:     primitive.NewObjectID().Hex(),
+					Date:   now,
+					UserId: "0",
+				},
+				MaterialId:     entry.MaterialId,
+				EntryId:        entry.EntryId,
+				Status:         entry.Status,
+				ExpirationDate: entry.ExpirationDate,
+				Quantity:       entry.Quantity,
+				Wasted:         wasted,
+			}
+
+			_, err = logs_collection.InsertOne(ctx, log)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
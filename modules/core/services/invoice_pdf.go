@@ -0,0 +1,100 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/nutrixpos/pos/modules/core/models"
+)
+
+const DefaultInvoiceTemplateFile = "invoice_a4_0.handlebars"
+
+// RenderInvoiceData extends the receipt data of the order with the company details,
+// the customer details and the tax breakdown printed on invoices.
+func (rs *ReceiptService) RenderInvoiceData(order models.Order, lang_code string) (map[string]interface{}, error) {
+	data, err := rs.RenderData(order, order.Discount, 0, order.SubmittedAt, lang_code, rs.Settings.ShopMode)
+	if err != nil {
+		return nil, err
+	}
+
+	lang_svc := LanguageService{
+		Config:   rs.Config,
+		Settings: rs.Settings,
+		Logger:   rs.Logger,
+	}
+
+	lang, err := lang_svc.GetLanguage(lang_code)
+	if err != nil {
+		return nil, err
+	}
+
+	fiscal := rs.Settings.Fiscal
+
+	data["t_invoice"] = lang.Pack["invoice"]
+	data["t_invoice_number"] = lang.Pack["invoice_number"]
+	data["t_seller"] = lang.Pack["seller"]
+	data["t_customer"] = lang.Pack["customer"]
+	data["t_address"] = lang.Pack["address"]
+	data["t_phone"] = lang.Pack["phone"]
+	data["t_email"] = lang.Pack["email"]
+	data["t_vat_number"] = lang.Pack["vat_number"]
+	data["t_vat"] = lang.Pack["vat"]
+	data["t_vat_rate"] = lang.Pack["vat_rate"]
+	data["t_total_excluding_vat"] = lang.Pack["total_excluding_vat"]
+
+	data["seller_name"] = fiscal.SellerName
+	data["seller_address"] = fiscal.SellerAddress
+	data["seller_phone"] = fiscal.SellerPhone
+	data["seller_email"] = fiscal.SellerEmail
+	data["vat_number"] = fiscal.VATNumber
+
+	data["has_customer"] = order.Customer.Name != "" || order.Customer.Phone != "" || order.Customer.Address != ""
+	data["customer_name"] = order.Customer.Name
+	data["customer_phone"] = order.Customer.Phone
+	data["customer_address"] = order.Customer.Address
+
+	// same total as the receipt so that both documents agree
+	total, ok := data["total"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("the receipt data of order %s has no total", order.Id)
+	}
+	tax := NewEInvoice(fiscal, total, order.SubmittedAt)
+
+	data["vat_rate"] = fmt.Sprintf("%.2f%%", fiscal.VATRate)
+	data["vat_total"] = fmt.Sprintf("%.2f", tax.VATTotal)
+	data["total_with_vat"] = fmt.Sprintf("%.2f", tax.Total)
+	data["total_excluding_vat"] = fmt.Sprintf("%.2f", tax.Total-tax.VATTotal)
+
+	return data, nil
+}
+
+// RenderInvoicePDF renders the order invoice as a pdf, A4 paper uses the invoice template
+// while roll paper uses the client receipt template.
+func (rs *ReceiptService) RenderInvoicePDF(order models.Order, lang_code string, paper PDFPaperSize) ([]byte, error) {
+	data, err := rs.RenderInvoiceData(order, lang_code)
+	if err != nil {
+		return nil, err
+	}
+
+	template_svc := ReceiptTemplateService{
+		Logger:   rs.Logger,
+		Config:   rs.Config,
+		Settings: rs.Settings,
+	}
+
+	var template string
+	if paper == PDFPaperA4 {
+		template, err = template_svc.GetPrinterTemplate(models.PrinterSettings{}, DefaultInvoiceTemplateFile)
+	} else {
+		template, err = template_svc.GetPrinterTemplate(rs.Settings.ClientReceiptPrinter, DefaultClientReceiptTemplateFile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := rs.RenderTemplate(template, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return rs.RenderPDF(output, paper)
+}
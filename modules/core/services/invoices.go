@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	InvoiceChainIssueGap                  = "gap"
+	InvoiceChainIssuePreviousHashMismatch = "previous_hash_mismatch"
+	InvoiceChainIssueHashMismatch         = "hash_mismatch"
+)
+
+// invoice_lock serializes invoice issuing so that numbers and hashes are taken from the latest invoice.
+var invoice_lock sync.Mutex
+
+// InvoiceService issues the sequential fiscal invoices and verifies their hash chain.
+type InvoiceService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// ComputeInvoiceHash returns the sha256 hex digest of the invoice content chained with its previous hash.
+func ComputeInvoiceHash(invoice models.Invoice) (string, error) {
+	items := make([]interface{}, 0, len(invoice.Items))
+	for _, item := range invoice.Items {
+		items = append(items, []interface{}{item.ItemId, item.ProductId, item.Name, fmt.Sprintf("%.4f", item.Quantity), fmt.Sprintf("%.2f", item.SalePrice)})
+	}
+
+	payload, err := json.Marshal([]interface{}{
+		invoice.Number,
+		invoice.OrderId,
+		invoice.DisplayId,
+		invoice.IssuedAt.UTC().Format(time.RFC3339Nano),
+		items,
+		fmt.Sprintf("%.2f", invoice.Discount),
+		fmt.Sprintf("%.2f", invoice.Total),
+		invoice.PaymentSource,
+		invoice.PreviousHash,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(payload)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// EnsureIndexes makes the invoice numbers unique, it is run when the core module starts.
+func (is *InvoiceService) EnsureIndexes(ctx context.Context) error {
+	client, err := common.GetDatabaseClient(is.Logger, &is.Config)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Database(is.Config.Databases[0].Database).Collection("invoices").Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.M{"number": 1},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("indexing number of invoices: %w", err)
+	}
+
+	return nil
+}
+
+// GetOrderInvoice returns the invoice issued for the order.
+func (is *InvoiceService) GetOrderInvoice(order_id string) (invoice models.Invoice, err error) {
+	client, err := common.GetDatabaseClient(is.Logger, &is.Config)
+	if err != nil {
+		return invoice, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = client.Database(is.Config.Databases[0].Database).Collection("invoices").FindOne(ctx, bson.M{"order_id": order_id}).Decode(&invoice)
+	return invoice, err
+}
+
+// IssueInvoice issues the next sequential invoice for a paid order and stores its number in the order,
+// if the order is already invoiced the existing invoice is returned.
+func (is *InvoiceService) IssueInvoice(order models.Order) (invoice models.Invoice, err error) {
+	invoice_lock.Lock()
+	defer invoice_lock.Unlock()
+
+	client, err := common.GetDatabaseClient(is.Logger, &is.Config)
+	if err != nil {
+		return invoice, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := client.Database(is.Config.Databases[0].Database).Collection("invoices")
+
+	invoice, err = is.GetOrderInvoice(order.Id)
+	if err == nil {
+		return invoice, nil
+	} else if err != mongo.ErrNoDocuments {
+		return invoice, err
+	}
+
+	var last models.Invoice
+	err = collection.FindOne(ctx, bson.M{}, options.FindOne().SetSort(bson.M{"number": -1})).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return invoice, err
+	}
+
+	// the total is taken from the items as on the receipt, the order sale price is net of the discount when
+	// submitted but not once finished
+	total := 0.0
+
+	items := make([]models.InvoiceItem, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, models.InvoiceItem{
+			ItemId:    item.Id,
+			ProductId: item.Product.Id,
+			Name:      item.Product.Name,
+			Quantity:  item.Quantity,
+			SalePrice: item.SalePrice,
+		})
+		total += item.SalePrice
+	}
+
+	invoice = models.Invoice{
+		Id:        primitive.NewObjectID().Hex(),
+		Number:    last.Number + 1,
+		OrderId:   order.Id,
+		DisplayId: order.DisplayId,
+		// the database keeps milliseconds only, truncate so the hash survives the round trip
+		IssuedAt:      time.Now().UTC().Truncate(time.Millisecond),
+		Items:         items,
+		Discount:      order.Discount,
+		Total:         total - order.Discount,
+		PaymentSource: order.PaymentSource,
+		PreviousHash:  last.Hash,
+	}
+
+	invoice.Hash, err = ComputeInvoiceHash(invoice)
+	if err != nil {
+		return invoice, err
+	}
+
+	_, err = collection.InsertOne(ctx, invoice)
+	if err != nil {
+		return invoice, err
+	}
+
+	_, err = client.Database(is.Config.Databases[0].Database).Collection("orders").UpdateOne(ctx, bson.M{"id": order.Id}, bson.M{"$set": bson.M{"invoice_number": invoice.Number}})
+	if err != nil {
+		return invoice, err
+	}
+
+	return invoice, nil
+}
+
+// VerifyChain walks all invoices in number order and reports gaps in the sequence,
+// broken links to the previous invoice and records whose content doesn't match their hash.
+func (is *InvoiceService) VerifyChain() (checked int64, issues []models.InvoiceChainIssue, err error) {
+	issues = make([]models.InvoiceChainIssue, 0)
+
+	client, err := common.GetDatabaseClient(is.Logger, &is.Config)
+	if err != nil {
+		return 0, issues, err
+	}
+
+	ctx := context.Background()
+
+	cursor, err := client.Database(is.Config.Databases[0].Database).Collection("invoices").Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"number": 1}))
+	if err != nil {
+		return 0, issues, err
+	}
+	defer cursor.Close(ctx)
+
+	var previous models.Invoice
+
+	for cursor.Next(ctx) {
+		var invoice models.Invoice
+		if err := cursor.Decode(&invoice); err != nil {
+			return checked, issues, err
+		}
+
+		if invoice.Number != previous.Number+1 {
+			issues = append(issues, models.InvoiceChainIssue{
+				Number:  invoice.Number,
+				Type:    InvoiceChainIssueGap,
+				Message: fmt.Sprintf("invoice %d follows invoice %d", invoice.Number, previous.Number),
+			})
+		}
+
+		if invoice.PreviousHash != previous.Hash {
+			issues = append(issues, models.InvoiceChainIssue{
+				Number:  invoice.Number,
+				Type:    InvoiceChainIssuePreviousHashMismatch,
+				Message: fmt.Sprintf("invoice %d previous hash doesn't match the hash of invoice %d", invoice.Number, previous.Number),
+			})
+		}
+
+		hash, err := ComputeInvoiceHash(invoice)
+		if err != nil {
+			return checked, issues, err
+		}
+
+		if hash != invoice.Hash {
+			issues = append(issues, models.InvoiceChainIssue{
+				Number:  invoice.Number,
+				Type:    InvoiceChainIssueHashMismatch,
+				Message: fmt.Sprintf("invoice %d content was altered, stored hash %s but computed %s", invoice.Number, invoice.Hash, hash),
+			})
+		}
+
+		previous = invoice
+		checked++
+	}
+
+	return checked, issues, cursor.Err()
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// KitchenTicket is the part of an order printed at a single kitchen station.
+type KitchenTicket struct {
+	Station string
+	Printer models.PrinterSettings
+	Items   []models.OrderItem
+}
+
+// KitchenTicketService splits orders into per station kitchen tickets and prints them.
+type KitchenTicketService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// productCategories maps every product id to the ids of the categories it belongs to.
+func (ks *KitchenTicketService) productCategories() (map[string][]string, error) {
+	product_categories := make(map[string][]string)
+
+	client, err := common.GetDatabaseClient(ks.Logger, &ks.Config)
+	if err != nil {
+		return product_categories, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := client.Database(ks.Config.Databases[0].Database).Collection("categories").Find(ctx, bson.M{})
+	if err != nil {
+		return product_categories, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var category models.Category
+		if err := cursor.Decode(&category); err != nil {
+			return product_categories, err
+		}
+
+		for _, product := range category.Products {
+			product_categories[product.Id] = append(product_categories[product.Id], category.Id)
+		}
+	}
+
+	return product_categories, cursor.Err()
+}
+
+// SplitTickets routes the order items to the configured kitchen stations, an item goes to the
+// first station listing its product, otherwise to the first station listing one of its categories,
+// items not routed to any station are printed on the kitchen receipt printer.
+func (ks *KitchenTicketService) SplitTickets(order models.Order) ([]KitchenTicket, error) {
+	tickets := make([]KitchenTicket, 0, len(ks.Settings.KitchenStations)+1)
+
+	default_ticket := KitchenTicket{
+		Printer: ks.Settings.KitchenReceiptPrinter,
+		Items:   make([]models.OrderItem, 0),
+	}
+
+	if len(ks.Settings.KitchenStations) == 0 {
+		default_ticket.Items = append(default_ticket.Items, order.Items...)
+		return append(tickets, default_ticket), nil
+	}
+
+	product_categories, err := ks.productCategories()
+	if err != nil {
+		return tickets, err
+	}
+
+	station_tickets := make([]KitchenTicket, len(ks.Settings.KitchenStations))
+	for index, station := range ks.Settings.KitchenStations {
+		station_tickets[index] = KitchenTicket{
+			Station: station.Name,
+			Printer: station.Printer,
+			Items:   make([]models.OrderItem, 0),
+		}
+	}
+
+	for _, item := range order.Items {
+		station_index := -1
+
+		for index, station := range ks.Settings.KitchenStations {
+			if slices.Contains(station.ProductIds, item.Product.Id) {
+				station_index = index
+				break
+			}
+		}
+
+		if station_index == -1 {
+		stations:
+			for index, station := range ks.Settings.KitchenStations {
+				for _, category_id := range product_categories[item.Product.Id] {
+					if slices.Contains(station.CategoryIds, category_id) {
+						station_index = index
+						break stations
+					}
+				}
+			}
+		}
+
+		if station_index == -1 {
+			default_ticket.Items = append(default_ticket.Items, item)
+		} else {
+			station_tickets[station_index].Items = append(station_tickets[station_index].Items, item)
+		}
+	}
+
+	for _, ticket := range station_tickets {
+		if len(ticket.Items) > 0 {
+			tickets = append(tickets, ticket)
+		}
+	}
+
+	if len(default_ticket.Items) > 0 {
+		tickets = append(tickets, default_ticket)
+	}
+
+	return tickets, nil
+}
+
+// RenderTicketHTML renders a single kitchen ticket of the order, index is zero based and count is
+// the number of tickets of the order, they are printed on the ticket as "1/3".
+func (ks *KitchenTicketService) RenderTicketHTML(order models.Order, ticket KitchenTicket, index int, count int, lang_code string, template_content string) (string, error) {
+	receipt_svc := ReceiptService{
+		Config:   ks.Config,
+		Logger:   ks.Logger,
+		Settings: ks.Settings,
+	}
+
+	ticket_order := order
+	ticket_order.Items = ticket.Items
+
+	data, err := receipt_svc.RenderData(ticket_order, order.Discount, 0, order.SubmittedAt, lang_code, ks.Settings.ShopMode)
+	if err != nil {
+		return "", err
+	}
+
+	lang_svc := LanguageService{
+		Config:   ks.Config,
+		Settings: ks.Settings,
+		Logger:   ks.Logger,
+	}
+
+	lang, err := lang_svc.GetLanguage(lang_code)
+	if err != nil {
+		return "", err
+	}
+
+	data["station"] = ticket.Station
+	data["ticket_counter"] = fmt.Sprintf("%d/%d", index+1, count)
+	data["t_comment"] = lang.Pack["comment"]
+	data["comment"] = order.Comment
+	data["has_comment"] = order.Comment != ""
+
+	return receipt_svc.RenderTemplate(template_content, data)
+}
+
+// PrintTickets splits the order into station tickets and prints each one on its station printer,
+// it keeps printing the remaining tickets when one fails and returns the last error.
+func (ks *KitchenTicketService) PrintTickets(order models.Order, lang_code string) (err error) {
+	tickets, err := ks.SplitTickets(order)
+	if err != nil {
+		return err
+	}
+
+	template_svc := ReceiptTemplateService{
+		Config:   ks.Config,
+		Logger:   ks.Logger,
+		Settings: ks.Settings,
+	}
+
+	receipt_svc := ReceiptService{
+		Config:   ks.Config,
+		Logger:   ks.Logger,
+		Settings: ks.Settings,
+	}
+
+	for index, ticket := range tickets {
+		print_err := func() error {
+			template, err := template_svc.GetPrinterTemplate(ticket.Printer, DefaultKitchenReceiptTemplateFile)
+			if err != nil {
+				return err
+			}
+
+			output, err := ks.RenderTicketHTML(order, ticket, index, len(tickets), lang_code, template)
+			if err != nil {
+				return err
+			}
+
+			return receipt_svc.PrintHTML(output, ticket.Printer.Host)
+		}()
+
+		if print_err != nil {
+			ks.Logger.Error(fmt.Sprintf("failed printing kitchen ticket %d/%d of order %s: %s", index+1, len(tickets), order.Id, print_err.Error()))
+			err = print_err
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aymerick/raymond"
+	"github.com/elmawardy/escpos"
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DefaultZPLLabelTemplateFile is the bundled label template used with zpl label printers.
+	DefaultZPLLabelTemplateFile = "label_zpl_0.handlebars"
+	// DefaultESCPOSLabelTemplateFile is the bundled label template used with esc/pos printers.
+	DefaultESCPOSLabelTemplateFile = "label_escpos_0.handlebars"
+)
+
+// LabelService manages the label templates and prints prep and expiry labels.
+type LabelService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// ValidateTemplate checks the label format and that the content is a valid handlebars template.
+func (ls *LabelService) ValidateTemplate(template models.LabelTemplate) error {
+	if template.Format != models.LabelFormatZPL && template.Format != models.LabelFormatESCPOS {
+		return fmt.Errorf("invalid label format %q", template.Format)
+	}
+
+	if template.Content == "" {
+		return fmt.Errorf("template content is empty")
+	}
+
+	_, err := raymond.Parse(template.Content)
+	if err != nil {
+		return fmt.Errorf("invalid handlebars template: %w", err)
+	}
+
+	return nil
+}
+
+// GetTemplates retrieves a page of label templates.
+func (ls *LabelService) GetTemplates(page_number int, page_size int) (templates []models.LabelTemplate, totalRecords int64, err error) {
+	templates = make([]models.LabelTemplate, 0)
+
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return templates, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := client.Database(ls.Config.Databases[0].Database).Collection("label_templates")
+
+	totalRecords, err = collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return templates, 0, err
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.M{"name": 1})
+	findOptions.SetSkip(int64((page_number - 1) * page_size))
+	findOptions.SetLimit(int64(page_size))
+
+	cursor, err := collection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return templates, totalRecords, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &templates)
+	return templates, totalRecords, err
+}
+
+// GetTemplate retrieves a label template by id.
+func (ls *LabelService) GetTemplate(template_id string) (template models.LabelTemplate, err error) {
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return template, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = client.Database(ls.Config.Databases[0].Database).Collection("label_templates").FindOne(ctx, bson.M{"id": template_id}).Decode(&template)
+	return template, err
+}
+
+// InsertTemplate validates and inserts a new label template.
+func (ls *LabelService) InsertTemplate(template models.LabelTemplate) (models.LabelTemplate, error) {
+	err := ls.ValidateTemplate(template)
+	if err != nil {
+		return template, err
+	}
+
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return template, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	template.Id = primitive.NewObjectID().Hex()
+	template.CreatedAt = time.Now()
+	template.UpdatedAt = template.CreatedAt
+
+	_, err = client.Database(ls.Config.Databases[0].Database).Collection("label_templates").InsertOne(ctx, template)
+	return template, err
+}
+
+// UpdateTemplate validates and replaces the name, format and content of a label template.
+func (ls *LabelService) UpdateTemplate(template_id string, template models.LabelTemplate) (models.LabelTemplate, error) {
+	err := ls.ValidateTemplate(template)
+	if err != nil {
+		return template, err
+	}
+
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return template, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	result, err := client.Database(ls.Config.Databases[0].Database).Collection("label_templates").UpdateOne(ctx, bson.M{"id": template_id}, bson.M{
+		"$set": bson.M{
+			"name":       template.Name,
+			"format":     template.Format,
+			"content":    template.Content,
+			"updated_at": time.Now(),
+		},
+	})
+	if err != nil {
+		return template, err
+	}
+
+	if result.MatchedCount == 0 {
+		return template, fmt.Errorf("label template %s not found", template_id)
+	}
+
+	return ls.GetTemplate(template_id)
+}
+
+// DeleteTemplate deletes a label template, it fails if the template is assigned in the label settings.
+func (ls *LabelService) DeleteTemplate(template_id string) (err error) {
+	if ls.Settings.Labels.ProductTemplateId == template_id || ls.Settings.Labels.MaterialTemplateId == template_id {
+		return fmt.Errorf("label template %s is assigned in the label settings", template_id)
+	}
+
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = client.Database(ls.Config.Databases[0].Database).Collection("label_templates").DeleteOne(ctx, bson.M{"id": template_id})
+	return err
+}
+
+// GetLabelTemplate returns the template assigned to the label type, falling back to the bundled template of the configured format.
+func (ls *LabelService) GetLabelTemplate(label_type string) (template models.LabelTemplate, err error) {
+	template_id := ls.Settings.Labels.MaterialTemplateId
+	if label_type == models.LabelTypeProduct {
+		template_id = ls.Settings.Labels.ProductTemplateId
+	}
+
+	if template_id != "" {
+		template, err = ls.GetTemplate(template_id)
+		if err == nil {
+			return template, nil
+		}
+
+		ls.Logger.Error(fmt.Sprintf("failed to load label template %s, using the bundled template: %s", template_id, err.Error()))
+	}
+
+	template.Format = ls.Settings.Labels.Format
+	if template.Format == "" {
+		template.Format = models.LabelFormatZPL
+	}
+
+	file := DefaultZPLLabelTemplateFile
+	if template.Format == models.LabelFormatESCPOS {
+		file = DefaultESCPOSLabelTemplateFile
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return template, err
+	}
+
+	content, err := os.ReadFile(filepath.Join(pwd, "assets", "core", "templates", file))
+	if err != nil {
+		return template, err
+	}
+
+	template.Content = string(content)
+	return template, nil
+}
+
+// labelValue removes the characters that would break the printer commands of the format.
+func labelValue(format string, value string) string {
+	if format == models.LabelFormatZPL {
+		// ^ and ~ start zpl commands
+		return strings.NewReplacer("^", " ", "~", " ").Replace(value)
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 {
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// escposBarcode returns the esc/pos commands printing value as a CODE128 barcode with its text below.
+func escposBarcode(value string) string {
+	data := append([]byte("{B"), []byte(value)...)
+	if len(data) > 255 {
+		data = data[:255]
+	}
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0x1D, 'h', 80}) // barcode height
+	buf.Write([]byte{0x1D, 'w', 2})  // module width
+	buf.Write([]byte{0x1D, 'H', 2})  // text below the barcode
+	buf.Write([]byte{0x1D, 'k', 73, byte(len(data))})
+	buf.Write(data)
+	buf.WriteByte('\n')
+
+	return buf.String()
+}
+
+// RenderLabel renders the label into the printer commands of the template format.
+func (ls *LabelService) RenderLabel(template models.LabelTemplate, label models.Label) ([]byte, error) {
+	lang_svc := LanguageService{
+		Config:   ls.Config,
+		Settings: ls.Settings,
+		Logger:   ls.Logger,
+	}
+
+	lang, err := lang_svc.GetLanguage(ls.Settings.Language.Code)
+	if err != nil {
+		return nil, err
+	}
+
+	if label.Barcode == "" {
+		label.Barcode = label.BatchId
+	}
+
+	expiration_date := ""
+	if !label.ExpirationDate.IsZero() {
+		expiration_date = label.ExpirationDate.Format("2006-01-02 15:04")
+	}
+
+	data := map[string]interface{}{
+		"t_prepared":      labelValue(template.Format, fmt.Sprint(lang.Pack["prepared"])),
+		"t_expires":       labelValue(template.Format, fmt.Sprint(lang.Pack["expires"])),
+		"t_batch":         labelValue(template.Format, fmt.Sprint(lang.Pack["batch"])),
+		"type":            label.Type,
+		"name":            labelValue(template.Format, label.Name),
+		"batch_id":        labelValue(template.Format, label.BatchId),
+		"barcode":         labelValue(template.Format, label.Barcode),
+		"quantity":        label.Quantity,
+		"unit":            labelValue(template.Format, label.Unit),
+		"prepared_at":     label.PreparedAt.Format("2006-01-02 15:04"),
+		"has_expiration":  expiration_date != "",
+		"expiration_date": expiration_date,
+	}
+
+	tpl, err := raymond.Parse(template.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl.RegisterHelper("escpos_barcode", func(value string) raymond.SafeString {
+		return raymond.SafeString(escposBarcode(value))
+	})
+
+	output, err := tpl.Exec(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(output), nil
+}
+
+// PrintLabel renders the label with the template assigned to its type and sends it to the label printer.
+func (ls *LabelService) PrintLabel(label models.Label, copies int) error {
+	if ls.Settings.Labels.Host == "" {
+		return fmt.Errorf("label printer host is not configured")
+	}
+
+	template, err := ls.GetLabelTemplate(label.Type)
+	if err != nil {
+		return err
+	}
+
+	output, err := ls.RenderLabel(template, label)
+	if err != nil {
+		return err
+	}
+
+	socket, err := net.Dial("tcp", fmt.Sprintf("%s:9100", ls.Settings.Labels.Host))
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+
+	if copies < 1 {
+		copies = 1
+	}
+
+	for i := 0; i < copies; i++ {
+		if template.Format == models.LabelFormatESCPOS {
+			p := escpos.New(socket)
+			p.Initialize()
+			p.WriteRaw(output)
+			p.LineFeed()
+
+			err = p.PrintAndCut()
+		} else {
+			_, err = socket.Write(output)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MaterialEntryLabel builds the expiry label of a material entry, the entry id is the batch id.
+func (ls *LabelService) MaterialEntryLabel(material_id string, entry_id string) (label models.Label, err error) {
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return label, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var material models.Material
+	err = client.Database(ls.Config.Databases[0].Database).Collection("materials").FindOne(ctx, bson.M{"id": material_id}).Decode(&material)
+	if err != nil {
+		return label, err
+	}
+
+	for _, entry := range material.Entries {
+		if entry.Id != entry_id {
+			continue
+		}
+
+		label = models.Label{
+			Type:           models.LabelTypeMaterial,
+			ItemId:         material.Id,
+			Name:           material.Name,
+			BatchId:        entry.Id,
+			Quantity:       entry.Quantity,
+			Unit:           material.Unit,
+			PreparedAt:     time.Now(),
+			ExpirationDate: entry.ExpirationDate,
+			Barcode:        entry.SKU,
+		}
+
+		return label, nil
+	}
+
+	return label, fmt.Errorf("entry %s not found in material %s", entry_id, material_id)
+}
+
+// ProductLabel builds the prep label of ready stock of the product, the expiry is derived from the product shelf life.
+func (ls *LabelService) ProductLabel(product models.Product, quantity float64, batch_id string) models.Label {
+	now := time.Now()
+
+	label := models.Label{
+		Type:       models.LabelTypeProduct,
+		ItemId:     product.Id,
+		Name:       product.Name,
+		BatchId:    batch_id,
+		Quantity:   quantity,
+		Unit:       product.Unit,
+		PreparedAt: now,
+	}
+
+	if product.ShelfLifeHours > 0 {
+		label.ExpirationDate = now.Add(time.Duration(product.ShelfLifeHours * float64(time.Hour)))
+	}
+
+	return label
+}
+
+// PrintLabelAsync prints the label in the background when automatic labels are enabled in the settings,
+// printing failures are logged only so that stock operations never fail because of the label printer.
+func PrintLabelAsync(log logger.ILogger, conf config.Config, build func(ls *LabelService) (models.Label, error)) {
+	settings_svc := SettingsService{
+		Config: conf,
+	}
+
+	settings, err := settings_svc.GetSettings()
+	if err != nil {
+		log.Error(err.Error())
+		return
+	}
+
+	if !settings.Labels.Enabled {
+		return
+	}
+
+	label_svc := LabelService{
+		Logger:   log,
+		Config:   conf,
+		Settings: settings,
+	}
+
+	go func() {
+		label, err := build(&label_svc)
+		if err == nil {
+			err = label_svc.PrintLabel(label, 1)
+		}
+
+		if err != nil {
+			log.Error(fmt.Sprintf("failed to print label: %s", err.Error()))
+		}
+	}()
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidLocation = errors.New("invalid location")
+
+// LocationService manages the locations holding stock.
+type LocationService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// GetLocations returns all the locations, the default one first.
+func (ls LocationService) GetLocations() (locations []models.Location, err error) {
+	locations = make([]models.Location, 0)
+
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return locations, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := client.Database(ls.Config.Databases[0].Database).Collection("locations").Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "is_default", Value: -1}, {Key: "name", Value: 1}}))
+	if err != nil {
+		return locations, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &locations)
+	return locations, err
+}
+
+func (ls LocationService) GetLocation(location_id string) (location models.Location, err error) {
+
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return location, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = client.Database(ls.Config.Databases[0].Database).Collection("locations").FindOne(ctx, bson.M{"id": location_id}).Decode(&location)
+	return location, err
+}
+
+// GetDefaultLocationId returns the id of the default location, empty when there is none.
+func (ls LocationService) GetDefaultLocationId() (string, error) {
+
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var location models.Location
+	err = client.Database(ls.Config.Databases[0].Database).Collection("locations").FindOne(ctx, bson.M{"is_default": true}).Decode(&location)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+
+	return location.Id, err
+}
+
+// save replaces the location, unsetting the other defaults when it is the default one.
+func (ls LocationService) save(ctx context.Context, location models.Location, upsert bool) error {
+
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return err
+	}
+
+	collection := client.Database(ls.Config.Databases[0].Database).Collection("locations")
+
+	if location.IsDefault {
+		_, err = collection.UpdateMany(ctx, bson.M{"id": bson.M{"$ne": location.Id}}, bson.M{"$set": bson.M{"is_default": false}})
+		if err != nil {
+			return err
+		}
+	}
+
+	result, err := collection.ReplaceOne(ctx, bson.M{"id": location.Id}, location, options.Replace().SetUpsert(upsert))
+	if err != nil {
+		return err
+	}
+
+	if !upsert && result.MatchedCount == 0 {
+		return mongo.ErrNoDocuments
+	}
+
+	return nil
+}
+
+// CreateLocation adds a location, the first one is the default location holding the entries without a location.
+func (ls LocationService) CreateLocation(location models.Location) (models.Location, error) {
+
+	if location.Name == "" {
+		return location, fmt.Errorf("%w: name is required", ErrInvalidLocation)
+	}
+
+	default_location_id, err := ls.GetDefaultLocationId()
+	if err != nil {
+		return location, err
+	}
+
+	if default_location_id == "" {
+		location.IsDefault = true
+	}
+
+	location.Id = primitive.NewObjectID().Hex()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return location, ls.save(ctx, location, true)
+}
+
+// UpdateLocation replaces the name, address and default flag of a location. The default location can only be
+// changed by making another location the default.
+func (ls LocationService) UpdateLocation(location_id string, update models.Location) (location models.Location, err error) {
+
+	location, err = ls.GetLocation(location_id)
+	if err != nil {
+		return location, err
+	}
+
+	if update.Name == "" {
+		return location, fmt.Errorf("%w: name is required", ErrInvalidLocation)
+	}
+
+	if location.IsDefault && !update.IsDefault {
+		return location, fmt.Errorf("%w: make another location the default instead", ErrInvalidLocation)
+	}
+
+	location.Name = update.Name
+	location.Address = update.Address
+	location.IsDefault = update.IsDefault
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return location, ls.save(ctx, location, false)
+}
+
+// DeleteLocation deletes a location holding no stock and not part of a transfer in transit. The default location
+// can't be deleted while there are other locations.
+func (ls LocationService) DeleteLocation(location_id string) error {
+
+	location, err := ls.GetLocation(location_id)
+	if err != nil {
+		return err
+	}
+
+	client, err := common.GetDatabaseClient(ls.Logger, &ls.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	db := client.Database(ls.Config.Databases[0].Database)
+
+	if location.IsDefault {
+		others, err := db.Collection("locations").CountDocuments(ctx, bson.M{"id": bson.M{"$ne": location_id}})
+		if err != nil {
+			return err
+		}
+
+		if others > 0 {
+			return fmt.Errorf("%w: make another location the default before deleting this one", ErrInvalidLocation)
+		}
+	}
+
+	stocked_filter := bson.M{"entries": bson.M{"$elemMatch": bson.M{"location_id": location_id, "quantity": bson.M{"$gt": 0}}}}
+	if location.IsDefault {
+		stocked_filter = bson.M{"entries": bson.M{"$elemMatch": bson.M{"quantity": bson.M{"$gt": 0}}}}
+	}
+
+	stocked, err := db.Collection("materials").CountDocuments(ctx, stocked_filter)
+	if err != nil {
+		return err
+	}
+
+	if stocked > 0 {
+		return fmt.Errorf("%w: location %s still holds stock", ErrInvalidLocation, location.Name)
+	}
+
+	in_transit, err := db.Collection("stock_transfers").CountDocuments(ctx, bson.M{
+		"state": bson.M{"$in": bson.A{models.StockTransferStateDispatched, models.StockTransferStateReceiving, models.StockTransferStateCancelling}},
+		"$or":   bson.A{bson.M{"from_location_id": location_id}, bson.M{"to_location_id": location_id}},
+	})
+	if err != nil {
+		return err
+	}
+
+	if in_transit > 0 {
+		return fmt.Errorf("%w: location %s has stock transfers in transit", ErrInvalidLocation, location.Name)
+	}
+
+	_, err = db.Collection("locations").DeleteOne(ctx, bson.M{"id": location_id})
+	return err
+}
+
+// entryAt tells whether the entry is held at the location, the entries without a location are at the default
+// one. Every entry is at the empty location.
+func entryAt(entry models.MaterialEntry, location_id string, default_location_id string) bool {
+	if location_id == "" || entry.LocationId == location_id {
+		return true
+	}
+
+	return entry.LocationId == "" && location_id == default_location_id
+}
+
+// atLocation returns the material with only the entries held at the location, all of them when location_id is empty.
+func (ms *MaterialService) atLocation(material models.Material, location_id string) (models.Material, error) {
+	if location_id == "" {
+		return material, nil
+	}
+
+	location_svc := LocationService{
+		Logger: ms.Logger,
+		Config: ms.Config,
+	}
+
+	default_location_id, err := location_svc.GetDefaultLocationId()
+	if err != nil {
+		return material, err
+	}
+
+	entries := make([]models.MaterialEntry, 0, len(material.Entries))
+	for _, entry := range material.Entries {
+		if entryAt(entry, location_id, default_location_id) {
+			entries = append(entries, entry)
+		}
+	}
+
+	material.Entries = entries
+	return material, nil
+}
+
+// GetLocationAvailability returns the quantity of the material in stock at the location, at all locations when
+// location_id is empty.
+func (ms *MaterialService) GetLocationAvailability(material_id string, location_id string) (amount float64, err error) {
+	material, err := ms.GetMaterial(material_id)
+	if err != nil {
+		return 0.0, err
+	}
+
+	material, err = ms.atLocation(material, location_id)
+	if err != nil {
+		return 0.0, err
+	}
+
+	for _, entry := range material.Entries {
+		if entry.Quantity > 0 {
+			amount += entry.Quantity
+		}
+	}
+
+	return amount, nil
+}
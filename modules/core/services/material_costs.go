@@ -0,0 +1,323 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// costPoints returns the purchases logged between from and to keyed by material, oldest first.
+// Only the purchases of material_id are returned when it is set.
+func (ms *MaterialService) costPoints(ctx context.Context, material_id string, from time.Time, to time.Time) (map[string][]models.MaterialCostPoint, error) {
+	points := make(map[string][]models.MaterialCostPoint)
+
+	client, err := common.GetDatabaseClient(ms.Logger, &ms.Config)
+	if err != nil {
+		return points, err
+	}
+
+	filter := bson.M{
+		"type": models.LogTypeMaterialAdd,
+		"date": bson.M{"$gte": from, "$lt": to},
+	}
+	if material_id != "" {
+		filter["material_id"] = material_id
+	}
+
+	cursor, err := client.Database(ms.Config.Databases[0].Database).Collection("logs").Find(ctx, filter, options.Find().SetSort(bson.M{"date": 1}))
+	if err != nil {
+		return points, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var log struct {
+			Date             time.Time `bson:"date"`
+			MaterialId       string    `bson:"material_id"`
+			EntryId          string    `bson:"entry_id"`
+			Company          string    `bson:"company"`
+			SupplierId       string    `bson:"supplier_id"`
+			Quantity         float64   `bson:"quantity"`
+			PurchaseQuantity float64   `bson:"purchase_quantity"`
+			Price            float64   `bson:"price"`
+		}
+
+		if err := cursor.Decode(&log); err != nil {
+			return points, err
+		}
+
+		// older logs only have the quantity, which is the purchased one for the pushed entries
+		quantity := log.PurchaseQuantity
+		if quantity == 0 {
+			quantity = log.Quantity
+		}
+
+		if quantity <= 0 {
+			continue
+		}
+
+		points[log.MaterialId] = append(points[log.MaterialId], models.MaterialCostPoint{
+			Date:       log.Date,
+			EntryId:    log.EntryId,
+			SupplierId: log.SupplierId,
+			Supplier:   log.Company,
+			Quantity:   quantity,
+			Price:      log.Price,
+			UnitCost:   log.Price / quantity,
+		})
+	}
+
+	return points, cursor.Err()
+}
+
+// supplierKey tells the suppliers apart by id, or by company name when the purchase wasn't made through a purchase order.
+func supplierKey(point models.MaterialCostPoint) string {
+	if point.SupplierId != "" {
+		return point.SupplierId
+	}
+
+	return strings.ToLower(strings.TrimSpace(point.Supplier))
+}
+
+// costTrend summarizes the purchases of the material between from and to, the points before from are the prior period.
+func costTrend(material models.Material, points []models.MaterialCostPoint, from time.Time, to time.Time) models.MaterialCostTrend {
+	trend := models.MaterialCostTrend{
+		MaterialId:   material.Id,
+		MaterialName: material.Name,
+		Unit:         material.Unit,
+		From:         from,
+		To:           to,
+		Suppliers:    make([]models.MaterialSupplierCost, 0),
+	}
+
+	var quantity, price, previous_quantity, previous_price float64
+
+	indexes := make(map[string]int)
+	supplier_prices := make(map[string]float64)
+
+	for _, point := range points {
+		if point.Date.Before(from) {
+			previous_quantity += point.Quantity
+			previous_price += point.Price
+			continue
+		}
+
+		if trend.Purchases == 0 {
+			trend.FirstUnitCost = point.UnitCost
+		}
+		trend.Purchases++
+		trend.LastUnitCost = point.UnitCost
+		quantity += point.Quantity
+		price += point.Price
+
+		key := supplierKey(point)
+		index, ok := indexes[key]
+		if !ok {
+			index = len(trend.Suppliers)
+			indexes[key] = index
+			trend.Suppliers = append(trend.Suppliers, models.MaterialSupplierCost{
+				SupplierId:  point.SupplierId,
+				Supplier:    point.Supplier,
+				MinUnitCost: point.UnitCost,
+				MaxUnitCost: point.UnitCost,
+			})
+		}
+
+		supplier := &trend.Suppliers[index]
+		supplier.Purchases++
+		supplier.Quantity += point.Quantity
+		supplier.LastUnitCost = point.UnitCost
+		supplier.LastDate = point.Date
+		if point.UnitCost < supplier.MinUnitCost {
+			supplier.MinUnitCost = point.UnitCost
+		}
+		if point.UnitCost > supplier.MaxUnitCost {
+			supplier.MaxUnitCost = point.UnitCost
+		}
+		supplier_prices[key] += point.Price
+	}
+
+	if quantity > 0 {
+		trend.AverageUnitCost = price / quantity
+	}
+
+	if previous_quantity > 0 {
+		trend.PreviousAverageUnitCost = previous_price / previous_quantity
+	}
+
+	trend.ChangePercent = changePercent(trend.PreviousAverageUnitCost, trend.AverageUnitCost)
+
+	for key, index := range indexes {
+		trend.Suppliers[index].AverageUnitCost = supplier_prices[key] / trend.Suppliers[index].Quantity
+	}
+
+	sort.SliceStable(trend.Suppliers, func(i, j int) bool {
+		return trend.Suppliers[i].LastUnitCost < trend.Suppliers[j].LastUnitCost
+	})
+
+	if len(trend.Suppliers) > 0 {
+		cheapest := trend.Suppliers[0]
+		trend.CheapestSupplier = &cheapest
+	}
+
+	return trend
+}
+
+// GetMaterialCostHistory returns the purchases of a material between from and to with their unit cost, the
+// summary per supplier and the change from the period of the same length before from. When supplier is set,
+// a supplier id or company name, only its purchases are considered.
+func (ms *MaterialService) GetMaterialCostHistory(material_id string, from time.Time, to time.Time, supplier string) (trend models.MaterialCostTrend, err error) {
+	material, err := ms.GetMaterial(material_id)
+	if err != nil {
+		return trend, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	points, err := ms.costPoints(ctx, material_id, from.Add(-to.Sub(from)), to)
+	if err != nil {
+		return trend, err
+	}
+
+	material_points := make([]models.MaterialCostPoint, 0)
+	for _, point := range points[material_id] {
+		if supplier == "" || point.SupplierId == supplier || strings.EqualFold(strings.TrimSpace(point.Supplier), strings.TrimSpace(supplier)) {
+			material_points = append(material_points, point)
+		}
+	}
+
+	trend = costTrend(material, material_points, from, to)
+
+	trend.History = make([]models.MaterialCostPoint, 0)
+	for _, point := range material_points {
+		if !point.Date.Before(from) {
+			trend.History = append(trend.History, point)
+		}
+	}
+
+	return trend, nil
+}
+
+// GetMaterialCostTrends returns the unit cost trend of every material purchased between from and to or in the
+// period of the same length before, the largest increases first.
+func (ms *MaterialService) GetMaterialCostTrends(from time.Time, to time.Time) ([]models.MaterialCostTrend, error) {
+	trends := make([]models.MaterialCostTrend, 0)
+
+	client, err := common.GetDatabaseClient(ms.Logger, &ms.Config)
+	if err != nil {
+		return trends, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	points, err := ms.costPoints(ctx, "", from.Add(-to.Sub(from)), to)
+	if err != nil {
+		return trends, err
+	}
+
+	cursor, err := client.Database(ms.Config.Databases[0].Database).Collection("materials").Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"entries": 0}))
+	if err != nil {
+		return trends, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var material models.Material
+		if err := cursor.Decode(&material); err != nil {
+			return trends, err
+		}
+
+		if len(points[material.Id]) == 0 {
+			continue
+		}
+
+		trends = append(trends, costTrend(material, points[material.Id], from, to))
+	}
+
+	if err := cursor.Err(); err != nil {
+		return trends, err
+	}
+
+	sort.SliceStable(trends, func(i, j int) bool {
+		if trends[i].ChangePercent == nil || trends[j].ChangePercent == nil {
+			return trends[j].ChangePercent == nil && trends[i].ChangePercent != nil
+		}
+
+		return *trends[i].ChangePercent > *trends[j].ChangePercent
+	})
+
+	return trends, nil
+}
+
+// alertCostIncrease notifies the material_cost_increase topic when unit_cost exceeds the unit cost of the last
+// purchase of the material by more than the configured threshold. Failures are only logged as the purchase
+// itself succeeded.
+func (ms *MaterialService) alertCostIncrease(material models.Material, unit_cost float64) {
+	settings_svc := SettingsService{
+		Config: ms.Config,
+	}
+
+	settings, err := settings_svc.GetSettings()
+	if err != nil {
+		ms.Logger.Error(err.Error())
+		return
+	}
+
+	threshold := settings.Inventory.CostAlertThresholdPercent
+	if threshold <= 0 {
+		return
+	}
+
+	previous_unit_cost := 0.0
+	for index := len(material.Entries) - 1; index >= 0; index-- {
+		if material.Entries[index].PurchaseQuantity > 0 {
+			previous_unit_cost = material.Entries[index].PurchasePrice / material.Entries[index].PurchaseQuantity
+			break
+		}
+	}
+
+	if previous_unit_cost <= 0 {
+		return
+	}
+
+	increase := (unit_cost - previous_unit_cost) / previous_unit_cost * 100
+	if increase < threshold {
+		return
+	}
+
+	topic_msg := models.WebsocketTopicServerMessage{
+		Type:      "topic_message",
+		TopicName: "material_cost_increase",
+		Message:   fmt.Sprintf("Unit cost of %s rose %.1f%% from %.4f to %.4f per %s", material.Name, increase, previous_unit_cost, unit_cost, material.Unit),
+		Severity:  "warn",
+		Date:      time.Now(),
+		Key:       fmt.Sprintf("material_cost_increase@%s", material.Id),
+	}
+
+	json_msg, err := json.Marshal(topic_msg)
+	if err != nil {
+		ms.Logger.Error(err.Error())
+		return
+	}
+
+	notification_svc, err := SpawnNotificationSingletonSvc("melody", ms.Logger, ms.Config)
+	if err != nil {
+		ms.Logger.Error(err.Error())
+		return
+	}
+
+	err = notification_svc.SendToTopic("material_cost_increase", string(json_msg))
+	if err != nil {
+		ms.Logger.Error(err.Error())
+	}
+}
@@ -125,6 +125,26 @@ func (ms *MaterialService) GetMaterial(material_id string) (material models.Mate
 	return material, err
 }
 
+// EntryUnitCost returns the purchase cost of one unit of the material entry.
+func (ms *MaterialService) EntryUnitCost(material_id string, entry_id string) (float64, error) {
+	material, err := ms.GetMaterial(material_id)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range material.Entries {
+		if entry.Id == entry_id {
+			if entry.PurchaseQuantity == 0 {
+				return 0, nil
+			}
+
+			return entry.PurchasePrice / entry.PurchaseQuantity, nil
+		}
+	}
+
+	return 0, fmt.Errorf("entry %s not found in material %s", entry_id, material_id)
+}
+
 func (ms *MaterialService) Waste(entry_id, material_id string, quantity float64, order_id string, reason string, is_consume bool, user_id string) (err error) {
 	client, err := common.GetDatabaseClient(ms.Logger, &ms.Config)
 	if err != nil {
@@ -146,6 +166,19 @@ func (ms *MaterialService) Waste(entry_id, material_id string, quantity float64,
 		ms.ConsumeFromInventory(material, material.Entries[0].Id, quantity, reason, order_id, user_id)
 	}
 
+	// the entry may be deleted later on, so its cost and location are kept in the log
+	unit_cost, location_id := 0.0, ""
+	material, err := ms.GetMaterial(material_id)
+	if err != nil {
+		ms.Logger.Error(err.Error())
+	}
+	for _, entry := range material.Entries {
+		if entry.Id == entry_id {
+			unit_cost = entryUnitCost(entry)
+			location_id = entry.LocationId
+		}
+	}
+
 	filter := bson.M{"id": material_id, "entries.id": entry_id}
 	// Define the update operation
 	update := bson.M{
@@ -164,7 +197,7 @@ func (ms *MaterialService) Waste(entry_id, material_id string, quantity float64,
 			Type:   models.LogTypeMaterialWaste,
 			Date:   time.Now(),
 			Id:     primitive.NewObjectID().Hex(),
-			UserId: entry_id,
+			UserId: user_id,
 		},
 		MaterialId: material_id,
 		EntryId:    entry_id,
@@ -172,6 +205,8 @@ func (ms *MaterialService) Waste(entry_id, material_id string, quantity float64,
 		OrderId:    order_id,
 		Quantity:   quantity,
 		Reason:     reason,
+		Value:      quantity * unit_cost,
+		LocationId: location_id,
 	}
 
 	logs_collection := client.Database(ms.Config.Databases[0].Database).Collection("logs")
@@ -336,7 +371,9 @@ func (cs *MaterialService) ConsumeFromInventory(material models.Material, entry_
 	return notifications, err
 }
 
-func (cs *MaterialService) CalculateMaterialAverageCost(material_id string, quantity float64) (cost float64, err error) {
+// CalculateMaterialAverageCost calculates the cost of a quantity of a material, given in unit, at the average
+// unit cost of its entries.
+func (cs *MaterialService) CalculateMaterialAverageCost(material_id string, quantity float64, unit string) (cost float64, err error) {
 	client, err := common.GetDatabaseClient(cs.Logger, &cs.Config)
 	if err != nil {
 		return 0, err
@@ -350,6 +387,11 @@ func (cs *MaterialService) CalculateMaterialAverageCost(material_id string, quan
 		return 0, err
 	}
 
+	quantity, err = ToMaterialUnit(material, quantity, unit)
+	if err != nil {
+		return 0, err
+	}
+
 	total_entries_purchase_price_per_unit := 0.0
 
 	for _, entry := range material.Entries {
@@ -361,8 +403,8 @@ func (cs *MaterialService) CalculateMaterialAverageCost(material_id string, quan
 
 // CalculateMaterialExactCost calculates the cost of a material entry based on its ID, material ID, and quantity.
 // It connects to the MongoDB database, retrieves the specific material entry, and calculates the cost
-// using the purchase price and purchase quantity.
-func (cs *MaterialService) CalculateMaterialExactCost(entry_id, material_id string, quantity float64) (cost float64, err error) {
+// using the purchase price and purchase quantity. The quantity is given in unit and converted to the material unit.
+func (cs *MaterialService) CalculateMaterialExactCost(entry_id, material_id string, quantity float64, unit string) (cost float64, err error) {
 	client, err := common.GetDatabaseClient(cs.Logger, &cs.Config)
 	if err != nil {
 		return 0, err
@@ -375,7 +417,7 @@ func (cs *MaterialService) CalculateMaterialExactCost(entry_id, material_id stri
 		"id":         material_id,
 		"entries.id": entry_id,
 	},
-		options.FindOne().SetProjection(bson.M{"entries.$": 1})).Decode(&material)
+		options.FindOne().SetProjection(bson.M{"entries.$": 1, "unit": 1, "pack_sizes": 1})).Decode(&material)
 	if err != nil {
 		return 0, err
 	}
@@ -384,6 +426,11 @@ func (cs *MaterialService) CalculateMaterialExactCost(entry_id, material_id stri
 		return 0.0, fmt.Errorf("entry %s not found in material %s", entry_id, material_id)
 	}
 
+	quantity, err = ToMaterialUnit(material, quantity, unit)
+	if err != nil {
+		return 0, err
+	}
+
 	cost = (material.Entries[0].PurchasePrice / float64(material.Entries[0].PurchaseQuantity)) * quantity
 
 	return cost, nil
@@ -441,6 +488,25 @@ func (ms *MaterialService) ConsumeItemComponentsForOrder(item models.OrderItem,
 
 	if item.IsConsumeFromReady {
 		err = productService.ConsumeFromReady(item.Product.Id, item.Quantity)
+		if err != nil {
+			return notifications, err
+		}
+
+		// the ready stock may hold the materials of a refunded order, the log lets them be traced to this one
+		log_product_consume := models.LogProductConsume{
+			Log: models.Log{
+				Type:   models.LogTypeProductConsume,
+				Date:   time.Now(),
+				Id:     primitive.NewObjectID().Hex(),
+				UserId: user_id,
+			},
+			ProductId:      item.Product.Id,
+			OrderId:        order.Id,
+			OrderItemIndex: order_item_index,
+			Quantity:       item.Quantity,
+		}
+
+		_, err = client.Database(ms.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, log_product_consume)
 		return notifications, err
 	}
 
@@ -449,28 +515,50 @@ func (ms *MaterialService) ConsumeItemComponentsForOrder(item models.OrderItem,
 			return notifications, err
 		}
 
-		if ms.Settings.Orders.DefaultCostCalculationMethod == "exact" {
-			material_available_amount, err := ms.GetMaterialEntryAvailability(component.Material.Id, component.Entry.Id)
-			if err != nil {
-				return notifications, err
-			}
+		// the recipe quantity may be given in another unit than the one the material is kept in
+		component.Quantity, err = ms.MaterialQuantity(component.Material.Id, component.Quantity, component.Material.Unit)
+		if err != nil {
+			return notifications, err
+		}
+
+		material, err := ms.GetMaterial(component.Material.Id)
+		if err != nil {
+			return notifications, err
+		}
+
+		// the order only consumes the stock of its location
+		material, err = ms.atLocation(material, order.LocationId)
+		if err != nil {
+			return notifications, err
+		}
 
-			if float64(material_available_amount) < (component.Quantity*item.Quantity) || material_available_amount < 0 {
-				notifications = append(notifications, models.WebsocketTopicServerMessage{
-					TopicName: "inventory_insufficient",
-					Type:      "topic_message",
-					Severity:  "error",
-					Message:   fmt.Sprintf("Inventory for %s is insufficient, quantity requested by order_id: %s (display_id: %s) is %f, but entry %s only has %f", component.Material.Name, order.Id, order.DisplayId, (component.Quantity * item.Quantity), component.Entry.Id, float64(material_available_amount)),
-					Key:       fmt.Sprintf("inventory_insufficient@%s", component.Material.Id),
-				})
-				return notifications, fmt.Errorf("entry %s is insufficient", component.Material.Id)
+		strategy := ms.EntryAllocationStrategy(material)
+		demanded_quantity := component.Quantity * item.Quantity
+
+		// the quantity may be split over several entries, each share is consumed and logged on its own
+		shares, remaining := AllocateEntries(material, strategy, component.Entry.Id, demanded_quantity)
+		if remaining > 0 {
+			message := fmt.Sprintf("Inventory for %s is insufficient, quantity requested by order_id: %s (display_id: %s) is %f, but only %f is available", component.Material.Name, order.Id, order.DisplayId, demanded_quantity, demanded_quantity-remaining)
+			if strategy == models.EntryAllocationExplicit && component.Entry.Id != "" {
+				message = fmt.Sprintf("Inventory for %s is insufficient, quantity requested by order_id: %s (display_id: %s) is %f, but entry %s only has %f", component.Material.Name, order.Id, order.DisplayId, demanded_quantity, component.Entry.Id, demanded_quantity-remaining)
 			}
 
-			filter := bson.M{"id": component.Material.Id, "entries.id": component.Entry.Id}
+			notifications = append(notifications, models.WebsocketTopicServerMessage{
+				TopicName: "inventory_insufficient",
+				Type:      "topic_message",
+				Severity:  "error",
+				Message:   message,
+				Key:       fmt.Sprintf("inventory_insufficient@%s", component.Material.Id),
+			})
+			return notifications, fmt.Errorf("entry %s is insufficient", component.Material.Id)
+		}
+
+		for _, share := range shares {
+			filter := bson.M{"id": component.Material.Id, "entries.id": share.EntryId}
 			// Define the update operation
 			update := bson.M{
 				"$inc": bson.M{
-					"entries.$.quantity": -component.Quantity * item.Quantity,
+					"entries.$.quantity": -share.Quantity,
 				},
 			}
 
@@ -484,8 +572,11 @@ func (ms *MaterialService) ConsumeItemComponentsForOrder(item models.OrderItem,
 				"date":             time.Now(),
 				"id":               primitive.NewObjectID().Hex(),
 				"component_id":     component.Material.Id,
-				"quantity":         component.Quantity * item.Quantity,
-				"entry_id":         component.Entry.Id,
+				"quantity":         share.Quantity,
+				"entry_id":         share.EntryId,
+				"unit_cost":        share.UnitCost,
+				"allocation":       strategy,
+				"location_id":      order.LocationId,
 				"order_id":         order.Id,
 				"recipe_id":        item.Product.Id,
 				"order_item_index": order_item_index,
@@ -495,115 +586,9 @@ func (ms *MaterialService) ConsumeItemComponentsForOrder(item models.OrderItem,
 			if err != nil {
 				return notifications, err
 			}
-		} else if ms.Settings.Orders.DefaultCostCalculationMethod == "average" {
-
-			filter := bson.M{"id": component.Material.Id}
-			sort := bson.M{"entries.expiration_date": 1}
-			cursor, err := client.Database(ms.Config.Databases[0].Database).Collection("materials").Find(context.Background(), filter, &options.FindOptions{Sort: sort})
-			if err != nil {
-				return notifications, err
-			}
-			defer cursor.Close(context.Background())
-
-			var material models.Material
-			for cursor.Next(context.Background()) {
-				err := cursor.Decode(&material)
-				if err != nil {
-					return notifications, err
-				}
-				break
-			}
-
-			if len(material.Entries) == 0 {
-				return notifications, fmt.Errorf("no entries found for material %s", component.Material.Id)
-			}
-
-			available_quantity, err := ms.GetComponentAvailability(component.Material.Id)
-			if err != nil {
-				return notifications, err
-			}
-
-			if available_quantity < component.Quantity*item.Quantity {
-				return notifications, fmt.Errorf("not enough quantity for material %s", component.Material.Id)
-			}
-
-			demanded_quantity := component.Quantity * item.Quantity
-
-			for _, entry := range material.Entries {
-
-				if entry.Quantity < demanded_quantity && entry.Quantity > 0 && demanded_quantity > 0 {
-					demanded_quantity = demanded_quantity - entry.Quantity
-
-					filter := bson.M{"id": component.Material.Id, "entries.id": entry.Id}
-					// Define the update operation
-					update := bson.M{
-						"$inc": bson.M{
-							"entries.$.quantity": -entry.Quantity,
-						},
-					}
-
-					_, err = client.Database(ms.Config.Databases[0].Database).Collection("materials").UpdateOne(context.Background(), filter, update)
-					if err != nil {
-						return notifications, err
-					}
-
-					logs_data := bson.M{
-						"type":             "component_consume",
-						"date":             time.Now(),
-						"id":               primitive.NewObjectID().Hex(),
-						"component_id":     component.Material.Id,
-						"quantity":         entry.Quantity,
-						"entry_id":         component.Entry.Id,
-						"order_id":         order.Id,
-						"recipe_id":        item.Product.Id,
-						"order_item_index": order_item_index,
-						"user_id":          user_id,
-					}
-					_, err = client.Database(ms.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, logs_data)
-					if err != nil {
-						return notifications, err
-					}
-
-				} else if (entry.Quantity >= demanded_quantity) && demanded_quantity > 0 {
-
-					filter := bson.M{"id": component.Material.Id, "entries.id": entry.Id}
-					// Define the update operation
-					update := bson.M{
-						"$inc": bson.M{
-							"entries.$.quantity": -demanded_quantity,
-						},
-					}
-
-					_, err = client.Database(ms.Config.Databases[0].Database).Collection("materials").UpdateOne(context.Background(), filter, update)
-					if err != nil {
-						return notifications, err
-					}
-
-					logs_data := bson.M{
-						"type":             "component_consume",
-						"date":             time.Now(),
-						"id":               primitive.NewObjectID().Hex(),
-						"component_id":     component.Material.Id,
-						"quantity":         demanded_quantity,
-						"entry_id":         component.Entry.Id,
-						"order_id":         order.Id,
-						"recipe_id":        item.Product.Id,
-						"order_item_index": order_item_index,
-						"user_id":          user_id,
-					}
-					_, err = client.Database(ms.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, logs_data)
-					if err != nil {
-						return notifications, err
-					}
-
-					break
-				}
-
-			}
-
 		}
 
-		quantity, err := ms.GetComponentAvailability(component.Material.Id)
+		quantity, err := ms.GetLocationAvailability(component.Material.Id, order.LocationId)
 		if err != nil {
 			return notifications, err
 		}
@@ -641,35 +626,9 @@ func (ms *MaterialService) ConsumeItemComponentsForOrder(item models.OrderItem,
 //
 // The function is used to check the availability of a specific component before
 // consuming it. The component quantity is calculated by summing up the quantity
-// of all entries of the component.
+// of all entries of the component, whatever their location.
 func (cs *MaterialService) GetComponentAvailability(componentid string) (amount float64, err error) {
-
-	amount = 0.0
-
-	client, err := common.GetDatabaseClient(cs.Logger, &cs.Config)
-	if err != nil {
-		return 0.0, err
-	}
-
-	ctx := context.Background()
-
-	collection := client.Database(cs.Config.Databases[0].Database).Collection("materials")
-
-	filter := bson.M{"id": componentid}
-	var component models.Material
-	err = collection.FindOne(ctx, filter).Decode(&component)
-	if err != nil {
-		return 0.0, err
-	}
-
-	for _, entry := range component.Entries {
-		if entry.Quantity > 0 {
-			amount += entry.Quantity
-		}
-	}
-
-	return amount, nil
-
+	return cs.GetLocationAvailability(componentid, "")
 }
 
 // GetMaterials retrieves all materials from the database.
@@ -765,10 +724,46 @@ func (cs *MaterialService) EditMaterial(material_id string, material_to_edit mod
 		return err
 	}
 
+	err = ValidateEntryAllocation(material_to_edit.Settings.EntryAllocation)
+	if err != nil {
+		return err
+	}
+
 	existingMaterial.Settings.StockAlertTreshold = material_to_edit.Settings.StockAlertTreshold
+	existingMaterial.Settings.EntryAllocation = material_to_edit.Settings.EntryAllocation
+	existingMaterial.Settings.ParLevel = material_to_edit.Settings.ParLevel
+	existingMaterial.Settings.CoverDays = material_to_edit.Settings.CoverDays
+	existingMaterial.Settings.ExpiryWarningDays = material_to_edit.Settings.ExpiryWarningDays
 	existingMaterial.Name = material_to_edit.Name
+	existingMaterial.PackSizes = material_to_edit.PackSizes
+
+	// the entries follow the material to its new registered unit, a unit missing from the registry is only
+	// renamed, e.g. from "bottle" to "bottles", and keeps its quantities
+	_, found_from := FindUnit(existingMaterial.Unit)
+	_, found_to := FindUnit(material_to_edit.Unit)
+
+	for index, entry := range existingMaterial.Entries {
+		if !found_from || !found_to {
+			break
+		}
+
+		existingMaterial.Entries[index].Quantity, err = ConvertQuantity(entry.Quantity, existingMaterial.Unit, material_to_edit.Unit)
+		if err != nil {
+			return err
+		}
+
+		existingMaterial.Entries[index].PurchaseQuantity, err = ConvertQuantity(entry.PurchaseQuantity, existingMaterial.Unit, material_to_edit.Unit)
+		if err != nil {
+			return err
+		}
+	}
 	existingMaterial.Unit = material_to_edit.Unit
 
+	err = ValidateMaterialUnits(existingMaterial)
+	if err != nil {
+		return err
+	}
+
 	// Update the material
 	_, err = client.Database(cs.Config.Databases[0].Database).Collection("materials").UpdateOne(context.Background(), bson.M{"id": material_id}, bson.M{"$set": existingMaterial})
 	if err != nil {
@@ -794,8 +789,29 @@ func (cs *MaterialService) AddComponent(material models.Material, user_id string
 
 	material.Id = primitive.NewObjectID().Hex()
 
-	for index, _ := range material.Entries {
+	err = ValidateMaterialUnits(material)
+	if err != nil {
+		return err
+	}
+
+	err = ValidateEntryAllocation(material.Settings.EntryAllocation)
+	if err != nil {
+		return err
+	}
+
+	for index, entry := range material.Entries {
 		material.Entries[index].Id = primitive.NewObjectID().Hex()
+
+		material.Entries[index].Quantity, err = ToMaterialUnit(material, entry.Quantity, entry.Unit)
+		if err != nil {
+			return err
+		}
+
+		material.Entries[index].PurchaseQuantity, err = ToMaterialUnit(material, entry.PurchaseQuantity, entry.Unit)
+		if err != nil {
+			return err
+		}
+		material.Entries[index].Unit = ""
 	}
 
 	// Insert the DBComponent struct into the "materials" collection
@@ -809,15 +825,18 @@ func (cs *MaterialService) AddComponent(material models.Material, user_id string
 	for _, entry := range material.Entries {
 
 		logs_data := bson.M{
-			"id":          primitive.NewObjectID().Hex(),
-			"type":        "component_add",
-			"date":        time.Now(),
-			"material_id": material.Id,
-			"entry_id":    entry.Id,
-			"company":     entry.Company,
-			"quantity":    entry.Quantity,
-			"price":       entry.PurchasePrice,
-			"user_id":     user_id,
+			"id":                primitive.NewObjectID().Hex(),
+			"type":              "component_add",
+			"date":              time.Now(),
+			"material_id":       material.Id,
+			"entry_id":          entry.Id,
+			"company":           entry.Company,
+			"supplier_id":       entry.SupplierId,
+			"quantity":          entry.Quantity,
+			"purchase_quantity": entry.PurchaseQuantity,
+			"price":             entry.PurchasePrice,
+			"location_id":       entry.LocationId,
+			"user_id":           user_id,
 		}
 		_, err = client.Database(cs.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, logs_data)
 		if err != nil {
@@ -834,21 +853,34 @@ func (cs *MaterialService) AddComponent(material models.Material, user_id string
 // The function takes a component ID and a slice of MaterialEntry structs as parameters.
 // It then finds the material with the given ID and appends the new entries to the material's
 // entries array. If the material is not found, the function will return an error.
-func (cs *MaterialService) PushMaterialEntry(componentId string, entries []models.MaterialEntry, user_id string) error {
+// It returns the ids of the added entries. Entries given an id are only added once, pushing them again is a no-op.
+func (cs *MaterialService) PushMaterialEntry(componentId string, entries []models.MaterialEntry, user_id string) (entry_ids []string, err error) {
 
 	client, err := common.GetDatabaseClient(cs.Logger, &cs.Config)
 	if err != nil {
-		return err
+		return entry_ids, err
 	}
 
 	ctx := context.Background()
 
-	filter := bson.M{"id": componentId}
+	material, err := cs.GetMaterial(componentId)
+	if err != nil {
+		return entry_ids, err
+	}
 
 	for _, entry := range entries {
 
+		// entries are kept in the material unit whatever unit they were purchased in
+		entry.Quantity, err = ToMaterialUnit(material, entry.Quantity, entry.Unit)
+		if err != nil {
+			return entry_ids, err
+		}
+
 		entry.PurchaseQuantity = entry.Quantity
-		entry_id := primitive.NewObjectID().Hex()
+		entry_id := entry.Id
+		if entry_id == "" {
+			entry_id = primitive.NewObjectID().Hex()
+		}
 
 		entry_data := bson.M{
 			"id":                entry_id,
@@ -857,36 +889,58 @@ func (cs *MaterialService) PushMaterialEntry(componentId string, entries []model
 			"quantity":          entry.Quantity,
 			"company":           entry.Company,
 			"sku":               entry.SKU,
+			"lot_number":        entry.LotNumber,
 			"expiration_date":   entry.ExpirationDate,
+			"supplier_id":       entry.SupplierId,
+			"location_id":       entry.LocationId,
 		}
 
 		update := bson.M{"$push": bson.M{"entries": entry_data}}
 		opts := options.Update().SetUpsert(false)
 
-		_, err = client.Database(cs.Config.Databases[0].Database).Collection("materials").UpdateOne(ctx, filter, update, opts)
+		result, err := client.Database(cs.Config.Databases[0].Database).Collection("materials").UpdateOne(ctx, bson.M{"id": componentId, "entries.id": bson.M{"$ne": entry_id}}, update, opts)
 		if err != nil {
-			return err
+			return entry_ids, err
+		}
+
+		// the entry was already added
+		if result.MatchedCount == 0 {
+			entry_ids = append(entry_ids, entry_id)
+			continue
 		}
 
 		logs_data := bson.M{
-			"type":        "component_add",
-			"id":          primitive.NewObjectID().Hex(),
-			"date":        time.Now(),
-			"material_id": componentId,
-			"entry_id":    entry_id,
-			"company":     entry.Company,
-			"quantity":    entry.Quantity,
-			"price":       entry.PurchasePrice,
-			"user_id":     user_id,
+			"type":              "component_add",
+			"id":                primitive.NewObjectID().Hex(),
+			"date":              time.Now(),
+			"material_id":       componentId,
+			"entry_id":          entry_id,
+			"company":           entry.Company,
+			"supplier_id":       entry.SupplierId,
+			"quantity":          entry.Quantity,
+			"purchase_quantity": entry.PurchaseQuantity,
+			"price":             entry.PurchasePrice,
+			"location_id":       entry.LocationId,
+			"user_id":           user_id,
 		}
 		_, err = client.Database(cs.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, logs_data)
 		if err != nil {
 			cs.Logger.Error(err.Error())
-			return err
+			return entry_ids, err
 		}
+
+		entry_ids = append(entry_ids, entry_id)
+
+		if entry.PurchaseQuantity > 0 {
+			cs.alertCostIncrease(material, entry.PurchasePrice/entry.PurchaseQuantity)
+		}
+
+		PrintLabelAsync(cs.Logger, cs.Config, func(ls *LabelService) (models.Label, error) {
+			return ls.MaterialEntryLabel(componentId, entry_id)
+		})
 	}
 
-	return nil
+	return entry_ids, nil
 }
 
 // DeleteEntry deletes an entry from a material in the database.
@@ -21,6 +21,7 @@ import (
 	"github.com/nutrixpos/pos/modules/core/models"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -149,11 +150,12 @@ func (os *OrderService) WasteOrderItem(OrderItem models.OrderItem, order_id stri
 
 	log_waste_order_item := models.LogWasteOrderItem{
 		Log: models.Log{
-			Type:   "waste_orderitem",
+			Type:   models.LogTypeOrderItemWaste,
 			Date:   time.Now(),
 			Id:     primitive.NewObjectID().Hex(),
 			UserId: user_id,
 		},
+		Item:     OrderItem,
 		Quantity: quantity,
 		Reason:   reason,
 		OrderId:  order_id,
@@ -313,6 +315,7 @@ func (os *OrderService) RefundItem(request dto.OrderItemRefundRequest, user_id s
 	return nil
 }
 
+// PrintReceipt prints the order using the given handlebars template content.
 func (os *OrderService) PrintReceipt(order models.Order, template string, lang_code string, printer_host string) (err error) {
 	receipt_svc := ReceiptService{
 		Config:   os.Config,
@@ -342,8 +345,9 @@ func (os *OrderService) DeleteOrder(order_id string) (err error) {
 	return
 }
 
-// PayUnpaidOrder sets the is_paid field of the order with the given order_id to true.
-func (os *OrderService) PayUnpaidOrder(order_id string) (err error) {
+// PayUnpaidOrder sets the is_paid field of the order with the given order_id to true and issues its invoice.
+// paid tells whether this call paid the order, it is false when the order was already paid.
+func (os *OrderService) PayUnpaidOrder(order_id string) (paid bool, err error) {
 	client, err := common.GetDatabaseClient(os.Logger, &os.Config)
 	if err != nil {
 		return
@@ -353,15 +357,29 @@ func (os *OrderService) PayUnpaidOrder(order_id string) (err error) {
 
 	collection := client.Database(os.Config.Databases[0].Database).Collection("orders")
 
-	filter := bson.M{"id": order_id}
+	filter := bson.M{"id": order_id, "is_paid": false}
 	update := bson.M{"$set": bson.M{"is_paid": true}}
 
-	_, err = collection.UpdateOne(ctx, filter, update)
+	result, err := collection.UpdateOne(ctx, filter, update)
 	if err != nil {
-		return err
+		return paid, err
 	}
+	paid = result.ModifiedCount > 0
 
-	return
+	order, err := os.GetOrder(order_id)
+	if err != nil {
+		return paid, err
+	}
+
+	invoice_svc := InvoiceService{
+		Logger:   os.Logger,
+		Config:   os.Config,
+		Settings: os.Settings,
+	}
+
+	// the invoice is issued again when already paid in case issuing it failed then, an issued invoice is kept
+	_, err = invoice_svc.IssueInvoice(order)
+	return paid, err
 }
 
 // GetUnpaidOrders returns all orders that are not paid and their state is not cancelled.
@@ -416,15 +434,33 @@ func (os *OrderService) CancelOrder(order_id string) (err error) {
 	return err
 }
 
-// CalculateCost calculates the cost of each item in the provided list of order items.
-func (os *OrderService) CalculateCost(items []models.OrderItem) (cost []models.ItemCost, err error) {
+// CalculateCost calculates the cost of each item in the provided list of order items, from the entries at the
+// location the order is served from. An empty location_id costs from every entry.
+func (os *OrderService) CalculateCost(items []models.OrderItem, location_id string) (cost []models.ItemCost, err error) {
 	client, err := common.GetDatabaseClient(os.Logger, &os.Config)
 	if err != nil {
 		return cost, err
 	}
 
+	material_svc := MaterialService{
+		Logger:   os.Logger,
+		Config:   os.Config,
+		Settings: os.Settings,
+	}
+
 	for itemIndex, item := range items {
 
+		// the recipe quantities are costed in the unit their material is kept in, on a copy so the order keeps its units
+		materials := make([]models.OrderItemMaterial, len(item.Materials))
+		for index, component := range item.Materials {
+			materials[index] = component
+			materials[index].Quantity, err = material_svc.MaterialQuantity(component.Material.Id, component.Quantity, component.Material.Unit)
+			if err != nil {
+				return cost, err
+			}
+		}
+		item.Materials = materials
+
 		itemCost := models.ItemCost{
 			ItemName:   items[itemIndex].Product.Name,
 			Cost:       0.0,
@@ -490,6 +526,11 @@ func (os *OrderService) CalculateCost(items []models.OrderItem) (cost []models.I
 						return cost, err
 					}
 
+					material, err = material_svc.atLocation(material, location_id)
+					if err != nil {
+						return cost, err
+					}
+
 					total_cost_per_unit := 0.0
 					count_entries_over_0 := 0
 
@@ -510,11 +551,24 @@ func (os *OrderService) CalculateCost(items []models.OrderItem) (cost []models.I
 					}
 
 				} else {
-					err = client.Database(os.Config.Databases[0].Database).Collection("materials").FindOne(
-						context.Background(), bson.M{"id": component.Material.Id, "entries.id": component.Entry.Id}, options.FindOne().SetProjection(bson.M{"entries.$": 1})).Decode(&component_with_specific_entry)
+					component_with_specific_entry, err = material_svc.GetMaterial(component.Material.Id)
+					if err != nil {
+						return cost, err
+					}
+
+					component_with_specific_entry, err = material_svc.atLocation(component_with_specific_entry, location_id)
+					if err != nil {
+						return cost, err
+					}
+
+					// the quantity is costed at the entries the consumption will take it from, a component per entry
+					shares, err := costShares(component_with_specific_entry, material_svc.EntryAllocationStrategy(component_with_specific_entry), component.Entry.Id, itemComponent.Quantity)
+					if err != nil {
+						return cost, err
+					}
 
-					if err == nil {
-						quantity_cost := (component_with_specific_entry.Entries[0].PurchasePrice / float64(component_with_specific_entry.Entries[0].PurchaseQuantity)) * float64(component.Quantity) * items[itemIndex].Quantity
+					for _, share := range shares {
+						quantity_cost := share.UnitCost * share.Quantity
 
 						// check if cost is positive or negative infinity (semantic bug in calculation that causes problems later on)
 						if math.IsInf(quantity_cost, 0) || math.IsInf(quantity_cost, -1) {
@@ -522,11 +576,15 @@ func (os *OrderService) CalculateCost(items []models.OrderItem) (cost []models.I
 						}
 
 						itemCost.Cost += quantity_cost
-						itemComponent.Cost = quantity_cost
 
-					} else {
-						return cost, err
+						share_component := itemComponent
+						share_component.EntryId = share.EntryId
+						share_component.Quantity = share.Quantity
+						share_component.Cost = quantity_cost
+						itemCost.Components = append(itemCost.Components, share_component)
 					}
+
+					continue
 				}
 
 				itemCost.Components = append(itemCost.Components, itemComponent)
@@ -534,7 +592,7 @@ func (os *OrderService) CalculateCost(items []models.OrderItem) (cost []models.I
 		}
 
 		for _, subrecipe := range item.SubItems {
-			total_cost, err := os.CalculateCost([]models.OrderItem{subrecipe})
+			total_cost, err := os.CalculateCost([]models.OrderItem{subrecipe}, location_id)
 			if err != nil {
 				return cost, err
 			}
@@ -580,7 +638,7 @@ func (os *OrderService) FinishOrder(order_id string, user_id string) (err error)
 	totalCost := 0.0
 	totalSalePrice := 0.0
 
-	items_cost, err := os.CalculateCost(order.Items)
+	items_cost, err := os.CalculateCost(order.Items, order.LocationId)
 	if err != nil {
 		return err
 	}
@@ -687,22 +745,61 @@ func (os *OrderService) GetOrderDisplayId() (order_display_id string, err error)
 	}
 	random_queue := settings.Orders.Queues[random_queue_index]
 
-	order_display_id = fmt.Sprintf("%s-%v", random_queue.Prefix, random_queue.Next)
+	business_day, err := NewBusinessDay(os.Config)
+	if err != nil {
+		return order_display_id, err
+	}
+
+	today := business_day.Today()
+
+	collection := client.Database(os.Config.Databases[0].Database).Collection("settings")
+
+	// the first number taken on a new business day restarts the queue, the day is part of the filter so that
+	// only one order restarts it
+	if random_queue.ResetDaily {
+		err = collection.FindOneAndUpdate(
+			ctx,
+			bson.M{"id": settings.Id, "orders.queues": bson.M{"$elemMatch": bson.M{"prefix": random_queue.Prefix, "business_day": bson.M{"$ne": today}}}},
+			bson.M{"$set": bson.M{
+				"orders.queues.$.next":         2,
+				"orders.queues.$.business_day": today,
+			}},
+		).Err()
+
+		if err == nil {
+			return fmt.Sprintf("%s-%v", random_queue.Prefix, 1), nil
+		} else if err != mongo.ErrNoDocuments {
+			return order_display_id, err
+		}
+	}
 
-	_, err = client.Database(os.Config.Databases[0].Database).Collection("settings").UpdateOne(
+	var previous models.Settings
+	err = collection.FindOneAndUpdate(
 		ctx,
 		bson.M{"id": settings.Id, "orders.queues.prefix": random_queue.Prefix},
 		bson.M{
 			"$inc": bson.M{
 				"orders.queues.$.next": 1,
 			},
+			"$set": bson.M{
+				"orders.queues.$.business_day": today,
+			},
 		},
-	)
+		options.FindOneAndUpdate().SetReturnDocument(options.Before),
+	).Decode(&previous)
 
 	if err != nil {
 		return order_display_id, err
 	}
 
+	// the number taken is the one the queue was at before the increment
+	for _, queue := range previous.Orders.Queues {
+		if queue.Prefix == random_queue.Prefix {
+			order_display_id = fmt.Sprintf("%s-%v", queue.Prefix, queue.Next)
+			break
+		}
+	}
+
 	return order_display_id, err
 
 }
@@ -724,7 +821,7 @@ func (os *OrderService) SubmitOrder(order models.Order) (models.Order, error) {
 	totalCost := 0.0
 	totalSalePrice := 0.0
 
-	items_cost, err := os.CalculateCost(order.Items)
+	items_cost, err := os.CalculateCost(order.Items, order.LocationId)
 	if err != nil {
 		return order, err
 	}
@@ -757,6 +854,22 @@ func (os *OrderService) SubmitOrder(order models.Order) (models.Order, error) {
 		return order, err
 	}
 
+	// paid orders get their fiscal invoice number right away, pay later orders get it when paid
+	if order.IsPaid && order.State != "stashed" {
+		invoice_svc := InvoiceService{
+			Logger:   os.Logger,
+			Config:   os.Config,
+			Settings: os.Settings,
+		}
+
+		invoice, err := invoice_svc.IssueInvoice(order)
+		if err != nil {
+			return order, err
+		}
+
+		order.InvoiceNumber = invoice.Number
+	}
+
 	return order, err
 }
 
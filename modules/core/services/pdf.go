@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"math"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// PDFPaperSize describes the pdf paper, sizes are in inches.
+type PDFPaperSize struct {
+	Width float64
+	// Height of the page, 0 fits the page height to the content, used for roll paper.
+	Height float64
+	// ContentWidth is the css pixels width the template is designed for, it is scaled to fit the paper width, 0 disables scaling.
+	ContentWidth int
+}
+
+var (
+	// PDFPaper80mm is a thermal roll receipt, the receipt templates are designed for a 570px wide content.
+	PDFPaper80mm = PDFPaperSize{Width: 3.15, ContentWidth: 570}
+	// PDFPaperA4 is an A4 page.
+	PDFPaperA4 = PDFPaperSize{Width: 8.27, Height: 11.69}
+)
+
+// RenderPDF prints the html into a pdf document of the given paper size.
+func (rs *ReceiptService) RenderPDF(output string, paper PDFPaperSize) ([]byte, error) {
+
+	ctx, cancel := chromedp.NewContext(context.Background())
+	defer cancel()
+
+	uri := "data:text/html;base64," + base64.StdEncoding.EncodeToString([]byte(output))
+
+	// chrome lays out pages at 96 css pixels per inch
+	scale := 1.0
+	viewport_width := int64(paper.Width * 96)
+	if paper.ContentWidth > 0 {
+		scale = paper.Width * 96 / float64(paper.ContentWidth)
+		viewport_width = int64(paper.ContentWidth)
+	}
+
+	var buf []byte
+	err := chromedp.Run(ctx,
+		chromedp.EmulateViewport(viewport_width, 0, chromedp.EmulateScale(1.0)),
+		chromedp.Navigate(uri),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			height := paper.Height
+
+			if height == 0 {
+				var content_height float64
+				err := chromedp.Evaluate(`document.documentElement.scrollHeight`, &content_height).Do(ctx)
+				if err != nil {
+					return err
+				}
+
+				// round up to avoid the last line spilling into a second page
+				height = math.Ceil(content_height*scale/96*100)/100 + 0.1
+			}
+
+			var err error
+			buf, _, err = page.PrintToPDF().
+				WithPrintBackground(true).
+				WithPaperWidth(paper.Width).
+				WithPaperHeight(height).
+				WithMarginTop(0).
+				WithMarginBottom(0).
+				WithMarginLeft(0).
+				WithMarginRight(0).
+				WithScale(scale).
+				Do(ctx)
+			return err
+		}),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf, nil
+}
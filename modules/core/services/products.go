@@ -55,7 +55,7 @@ func (rs *RecipeService) Waste(product_id string, quantity float64, order_id str
 
 	log_product_waste := models.LogProductWaste{
 		Log: models.Log{
-			Type:   "product_waste",
+			Type:   models.LogTypeProductWaste,
 			Date:   time.Now(),
 			Id:     primitive.NewObjectID().Hex(),
 			UserId: user_id,
@@ -106,9 +106,10 @@ func (rs *RecipeService) Increase(product_id string, quantity float64, source st
 			Id:     primitive.NewObjectID().Hex(),
 			UserId: user_id,
 		},
-		Quantity: quantity,
-		Source:   source,
-		OrderId:  order_id,
+		ProductId: product_id,
+		Quantity:  quantity,
+		Source:    source,
+		OrderId:   order_id,
 	}
 
 	logs_collection := client.Database(rs.Config.Databases[0].Database).Collection("logs")
@@ -118,6 +119,16 @@ func (rs *RecipeService) Increase(product_id string, quantity float64, source st
 		return err
 	}
 
+	// the increase log id identifies the prepared batch on its label
+	PrintLabelAsync(rs.Logger, rs.Config, func(ls *LabelService) (models.Label, error) {
+		product, err := rs.GetProduct(product_id)
+		if err != nil {
+			return models.Label{}, err
+		}
+
+		return ls.ProductLabel(product, quantity, log_product_increase.Id), nil
+	})
+
 	return nil
 }
 
@@ -168,6 +179,7 @@ func (rs *RecipeService) UpdateProduct(product_id string, product models.Product
 				"enable_inventory_consumption": product.EnableInventoryConsumption,
 				"enable_fixed_cost":           product.EnableFixedCost,
 				"fixed_cost":                 product.FixedCost,
+				"shelf_life_hours":             product.ShelfLifeHours,
 			},
 		},
 	)
@@ -449,8 +461,9 @@ func (rs *RecipeService) GetReadyNumber(recipe_id string) (ready float64, err er
 	return ready, nil
 }
 
-// CheckRecipesAvailability checks the availability of a list of recipes.
-func (rs *RecipeService) CheckRecipesAvailability(recipe_ids []string) (availabilities []dto.RecipeAvailability, err error) {
+// CheckRecipesAvailability checks the availability of a list of recipes from the stock at the location, at all
+// locations when location_id is empty.
+func (rs *RecipeService) CheckRecipesAvailability(recipe_ids []string, location_id string) (availabilities []dto.RecipeAvailability, err error) {
 	client, err := common.GetDatabaseClient(rs.Logger, &rs.Config)
 	if err != nil {
 		log.Fatal(err)
@@ -503,7 +516,7 @@ func (rs *RecipeService) CheckRecipesAvailability(recipe_ids []string) (availabi
 					Config: rs.Config,
 				}
 
-				component_amount, err := materialService.GetComponentAvailability(material.Id)
+				component_amount, err := materialService.GetLocationAvailability(material.Id, location_id)
 				if err != nil {
 					errorChan <- err
 					return
@@ -515,7 +528,7 @@ func (rs *RecipeService) CheckRecipesAvailability(recipe_ids []string) (availabi
 			for _, product := range recipe.SubProducts {
 
 				self_component_requirements[product.Id] = float64(product.Quantity)
-				subrecipe_available, err := rs.CheckRecipesAvailability([]string{product.Id})
+				subrecipe_available, err := rs.CheckRecipesAvailability([]string{product.Id}, location_id)
 
 				if err != nil {
 					errorChan <- err
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// salesTotals are the sums of the orders sold and the refunds recorded in a date range.
+type salesTotals struct {
+	OrdersCount  int64   `bson:"orders_count"`
+	GrossSales   float64 `bson:"gross_sales"`
+	Discounts    float64 `bson:"discounts"`
+	Cost         float64 `bson:"cost"`
+	Tips         float64 `bson:"tips"`
+	RefundsValue float64 `bson:"refunds_value"`
+	RefundsCost  float64 `bson:"refunds_cost"`
+}
+
+func (ss *SalesService) getSalesTotals(ctx context.Context, from time.Time, to time.Time) (totals salesTotals, err error) {
+	business_day, err := NewBusinessDay(ss.Config)
+	if err != nil {
+		return totals, err
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return totals, err
+	}
+
+	collection := client.Database(ss.Config.Databases[0].Database).Collection(ss.Config.Databases[0].Tables["sales"])
+
+	cursor, err := collection.Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"date": bson.M{"$gte": from.AddDate(0, 0, -1).Format("2006-01-02"), "$lte": to.AddDate(0, 0, 1).Format("2006-01-02")}}},
+		{"$unwind": "$orders"},
+		{"$match": bson.M{"orders.submitted_at": bson.M{"$gte": from, "$lt": to}}},
+		{"$group": bson.M{
+			"_id":          nil,
+			"orders_count": bson.M{"$sum": 1},
+			"gross_sales":  bson.M{"$sum": "$orders.sale_price"},
+			"discounts":    bson.M{"$sum": "$orders.discount"},
+			"cost":         bson.M{"$sum": "$orders.cost"},
+			"tips":         bson.M{"$sum": "$orders.tips"},
+		}},
+	})
+	if err != nil {
+		return totals, err
+	}
+
+	if cursor.Next(ctx) {
+		err = cursor.Decode(&totals)
+	}
+	cursor.Close(ctx)
+	if err != nil {
+		return totals, err
+	}
+
+	cursor, err = collection.Aggregate(ctx, []bson.M{
+		{"$match": bson.M{"date": bson.M{"$gte": business_day.Of(from), "$lte": business_day.Of(to.Add(-time.Nanosecond))}}},
+		{"$unwind": "$refunds"},
+		{"$group": bson.M{
+			"_id":           nil,
+			"refunds_value": bson.M{"$sum": "$refunds.amount"},
+			"refunds_cost":  bson.M{"$sum": "$refunds.item_cost"},
+		}},
+	})
+	if err != nil {
+		return totals, err
+	}
+	defer cursor.Close(ctx)
+
+	if cursor.Next(ctx) {
+		err = cursor.Decode(&totals)
+	}
+
+	return totals, err
+}
+
+// GetProfitLoss returns the profit and loss between from and to along with its breakdown per category,
+// waste and disposals of materials are not tied to a category and are reported under an empty one.
+func (ss *SalesService) GetProfitLoss(from time.Time, to time.Time) (report models.ProfitLossReport, err error) {
+	report = models.ProfitLossReport{
+		From:       from,
+		To:         to,
+		Categories: make([]models.ProfitLossCategory, 0),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	totals, err := ss.getSalesTotals(ctx, from, to)
+	if err != nil {
+		return report, err
+	}
+
+	report.OrdersCount = totals.OrdersCount
+	report.GrossSales = totals.GrossSales
+	report.Discounts = totals.Discounts
+	report.Refunds = totals.RefundsValue
+	report.NetSales = totals.GrossSales - totals.Discounts - totals.RefundsValue
+	report.CostOfGoods = totals.Cost - totals.RefundsCost
+	report.GrossProfit = report.NetSales - report.CostOfGoods
+	report.Tips = totals.Tips
+
+	category_sales, err := ss.GetSalesAnalytics(SalesGroupByCategory, from, to)
+	if err != nil {
+		return report, err
+	}
+
+	indexes := make(map[string]int)
+
+	category := func(id string, name string) *models.ProfitLossCategory {
+		index, ok := indexes[id]
+		if !ok {
+			index = len(report.Categories)
+			indexes[id] = index
+			report.Categories = append(report.Categories, models.ProfitLossCategory{CategoryId: id, Name: name})
+		}
+
+		return &report.Categories[index]
+	}
+
+	for _, group := range category_sales {
+		id, _ := group.Key.(string)
+
+		pl := category(id, group.Name)
+		pl.Sales = group.Revenue
+		pl.Refunds = group.RefundsValue
+		pl.CostOfGoods = group.Cost - group.RefundsCost
+	}
+
+	waste_svc := WasteService{
+		Logger: ss.Logger,
+		Config: ss.Config,
+	}
+
+	records, err := waste_svc.GetWasteRecords(from, to)
+	if err != nil {
+		return report, err
+	}
+
+	category_svc := CategoryService{
+		Logger: ss.Logger,
+		Config: ss.Config,
+	}
+
+	product_categories, err := category_svc.GetProductCategories()
+	if err != nil {
+		return report, err
+	}
+
+	for _, record := range records {
+		product_category := product_categories[record.ProductId]
+		pl := category(product_category.Id, product_category.Name)
+
+		if record.IsDisposal {
+			report.DisposalValue += record.Value
+			pl.DisposalValue += record.Value
+		} else {
+			report.WasteValue += record.Value
+			pl.WasteValue += record.Value
+		}
+	}
+
+	report.NetProfit = report.GrossProfit - report.WasteValue - report.DisposalValue
+
+	for index := range report.Categories {
+		pl := &report.Categories[index]
+		pl.NetSales = pl.Sales - pl.Refunds
+		pl.GrossProfit = pl.NetSales - pl.CostOfGoods
+		pl.NetProfit = pl.GrossProfit - pl.WasteValue - pl.DisposalValue
+	}
+
+	sort.SliceStable(report.Categories, func(i, j int) bool {
+		return report.Categories[i].NetProfit > report.Categories[j].NetProfit
+	})
+
+	return report, nil
+}
@@ -0,0 +1,564 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	PurchaseOrderMismatchOverReceived  = "over_received"
+	PurchaseOrderMismatchShortReceived = "short_received"
+	PurchaseOrderMismatchPrice         = "price"
+)
+
+var (
+	ErrInvalidPurchaseOrder      = errors.New("invalid purchase order")
+	ErrInvalidPurchaseOrderState = errors.New("the purchase order state doesn't allow this operation")
+)
+
+// PurchaseOrderService manages the purchase orders sent to the suppliers and the goods received against them.
+type PurchaseOrderService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+type GetPurchaseOrdersParams struct {
+	PageNumber int
+	PageSize   int
+	State      string
+	SupplierId string
+}
+
+func (ps PurchaseOrderService) GetPurchaseOrders(params GetPurchaseOrdersParams) (purchase_orders []models.PurchaseOrder, total_records int, err error) {
+
+	purchase_orders = make([]models.PurchaseOrder, 0)
+
+	client, err := common.GetDatabaseClient(ps.Logger, &ps.Config)
+	if err != nil {
+		return purchase_orders, total_records, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := client.Database(ps.Config.Databases[0].Database).Collection("purchase_orders")
+
+	filter := bson.M{}
+	if params.State != "" {
+		filter["state"] = params.State
+	}
+	if params.SupplierId != "" {
+		filter["supplier_id"] = params.SupplierId
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((params.PageNumber - 1) * params.PageSize))
+	findOptions.SetLimit(int64(params.PageSize))
+	findOptions.SetSort(bson.M{"created_at": -1})
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return purchase_orders, total_records, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &purchase_orders)
+	if err != nil {
+		return purchase_orders, total_records, err
+	}
+
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return purchase_orders, total_records, err
+	}
+	total_records = int(count)
+
+	return purchase_orders, total_records, err
+}
+
+func (ps PurchaseOrderService) GetPurchaseOrder(purchase_order_id string) (purchase_order models.PurchaseOrder, err error) {
+
+	client, err := common.GetDatabaseClient(ps.Logger, &ps.Config)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = client.Database(ps.Config.Databases[0].Database).Collection("purchase_orders").FindOne(ctx, bson.M{"id": purchase_order_id}).Decode(&purchase_order)
+	return purchase_order, err
+}
+
+// save replaces the stored purchase order with the given one.
+func (ps PurchaseOrderService) save(purchase_order models.PurchaseOrder) error {
+
+	client, err := common.GetDatabaseClient(ps.Logger, &ps.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.Database(ps.Config.Databases[0].Database).Collection("purchase_orders").ReplaceOne(ctx, bson.M{"id": purchase_order.Id}, purchase_order)
+	return err
+}
+
+// prepareLines fills the supplier and material names of the purchase order and its total. Lines without a unit
+// are ordered in the material unit and lines without a price get the price agreed with the supplier.
+func (ps PurchaseOrderService) prepareLines(purchase_order *models.PurchaseOrder) error {
+
+	supplier_svc := SupplierService{
+		Logger: ps.Logger,
+		Config: ps.Config,
+	}
+
+	supplier, err := supplier_svc.GetSupplier(purchase_order.SupplierId)
+	if err != nil {
+		return fmt.Errorf("%w: supplier %s: %s", ErrInvalidPurchaseOrder, purchase_order.SupplierId, err.Error())
+	}
+
+	purchase_order.SupplierName = supplier.Name
+
+	if len(purchase_order.Lines) == 0 {
+		return fmt.Errorf("%w: at least one line is required", ErrInvalidPurchaseOrder)
+	}
+
+	material_svc := MaterialService{
+		Logger: ps.Logger,
+		Config: ps.Config,
+	}
+
+	purchase_order.Total = 0
+
+	for index := range purchase_order.Lines {
+		line := &purchase_order.Lines[index]
+
+		if line.Id == "" {
+			line.Id = primitive.NewObjectID().Hex()
+		}
+
+		if line.Quantity <= 0 {
+			return fmt.Errorf("%w: line %d quantity must be positive", ErrInvalidPurchaseOrder, index+1)
+		}
+
+		material, err := material_svc.GetMaterial(line.MaterialId)
+		if err != nil {
+			return fmt.Errorf("%w: material %s: %s", ErrInvalidPurchaseOrder, line.MaterialId, err.Error())
+		}
+
+		line.MaterialName = material.Name
+
+		if line.Unit == "" {
+			line.Unit = material.Unit
+		}
+
+		line_quantity, err := ToMaterialUnit(material, 1, line.Unit)
+		if err != nil {
+			return fmt.Errorf("%w: material %s: %s", ErrInvalidPurchaseOrder, material.Name, err.Error())
+		}
+
+		for _, supplier_material := range supplier.Materials {
+			if supplier_material.MaterialId != line.MaterialId {
+				continue
+			}
+
+			if line.SKU == "" {
+				line.SKU = supplier_material.SKU
+			}
+
+			if line.UnitPrice == 0 {
+				supplier_quantity, err := ToMaterialUnit(material, 1, supplier_material.Unit)
+				if err == nil && supplier_quantity != 0 {
+					line.UnitPrice = supplier_material.Price * line_quantity / supplier_quantity
+				}
+			}
+			break
+		}
+
+		purchase_order.Total += line.Quantity * line.UnitPrice
+	}
+
+	return nil
+}
+
+// CreatePurchaseOrder adds a draft purchase order.
+func (ps PurchaseOrderService) CreatePurchaseOrder(purchase_order models.PurchaseOrder, user_id string) (models.PurchaseOrder, error) {
+
+	purchase_order.Id = primitive.NewObjectID().Hex()
+	purchase_order.State = models.PurchaseOrderStateDraft
+	purchase_order.CreatedAt = time.Now()
+	purchase_order.SentAt = time.Time{}
+	purchase_order.ReceivedAt = time.Time{}
+	purchase_order.Receipts = make([]models.PurchaseOrderReceipt, 0)
+	purchase_order.UserId = user_id
+
+	for index := range purchase_order.Lines {
+		purchase_order.Lines[index].Id = ""
+		purchase_order.Lines[index].ReceivedQuantity = 0
+	}
+
+	err := ps.prepareLines(&purchase_order)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	client, err := common.GetDatabaseClient(ps.Logger, &ps.Config)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.Database(ps.Config.Databases[0].Database).Collection("purchase_orders").InsertOne(ctx, purchase_order)
+	return purchase_order, err
+}
+
+// UpdatePurchaseOrder replaces the supplier, lines, notes, expected date and location of a draft purchase order.
+func (ps PurchaseOrderService) UpdatePurchaseOrder(purchase_order_id string, update models.PurchaseOrder) (purchase_order models.PurchaseOrder, err error) {
+
+	purchase_order, err = ps.GetPurchaseOrder(purchase_order_id)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	if purchase_order.State != models.PurchaseOrderStateDraft {
+		return purchase_order, fmt.Errorf("%w: only draft purchase orders can be edited", ErrInvalidPurchaseOrderState)
+	}
+
+	purchase_order.SupplierId = update.SupplierId
+	purchase_order.Lines = update.Lines
+	purchase_order.Notes = update.Notes
+	purchase_order.ExpectedAt = update.ExpectedAt
+	purchase_order.LocationId = update.LocationId
+
+	for index := range purchase_order.Lines {
+		purchase_order.Lines[index].ReceivedQuantity = 0
+	}
+
+	err = ps.prepareLines(&purchase_order)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	return purchase_order, ps.save(purchase_order)
+}
+
+// DeletePurchaseOrder deletes a draft or cancelled purchase order.
+func (ps PurchaseOrderService) DeletePurchaseOrder(purchase_order_id string) error {
+
+	purchase_order, err := ps.GetPurchaseOrder(purchase_order_id)
+	if err != nil {
+		return err
+	}
+
+	if purchase_order.State != models.PurchaseOrderStateDraft && purchase_order.State != models.PurchaseOrderStateCancelled {
+		return fmt.Errorf("%w: only draft or cancelled purchase orders can be deleted", ErrInvalidPurchaseOrderState)
+	}
+
+	client, err := common.GetDatabaseClient(ps.Logger, &ps.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.Database(ps.Config.Databases[0].Database).Collection("purchase_orders").DeleteOne(ctx, bson.M{"id": purchase_order_id})
+	return err
+}
+
+// SendPurchaseOrder marks a draft purchase order as sent to its supplier, the expected date defaults to
+// the supplier lead time.
+func (ps PurchaseOrderService) SendPurchaseOrder(purchase_order_id string) (purchase_order models.PurchaseOrder, err error) {
+
+	purchase_order, err = ps.GetPurchaseOrder(purchase_order_id)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	if purchase_order.State != models.PurchaseOrderStateDraft {
+		return purchase_order, fmt.Errorf("%w: only draft purchase orders can be sent", ErrInvalidPurchaseOrderState)
+	}
+
+	purchase_order.State = models.PurchaseOrderStateSent
+	purchase_order.SentAt = time.Now()
+
+	if purchase_order.ExpectedAt.IsZero() {
+		supplier_svc := SupplierService{
+			Logger: ps.Logger,
+			Config: ps.Config,
+		}
+
+		supplier, err := supplier_svc.GetSupplier(purchase_order.SupplierId)
+		if err != nil {
+			return purchase_order, err
+		}
+
+		purchase_order.ExpectedAt = purchase_order.SentAt.AddDate(0, 0, supplier.LeadTimeDays)
+	}
+
+	return purchase_order, ps.save(purchase_order)
+}
+
+// CancelPurchaseOrder cancels a purchase order nothing was received against yet.
+func (ps PurchaseOrderService) CancelPurchaseOrder(purchase_order_id string) (purchase_order models.PurchaseOrder, err error) {
+
+	purchase_order, err = ps.GetPurchaseOrder(purchase_order_id)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	if purchase_order.State != models.PurchaseOrderStateDraft && purchase_order.State != models.PurchaseOrderStateSent {
+		return purchase_order, fmt.Errorf("%w: only draft or sent purchase orders can be cancelled", ErrInvalidPurchaseOrderState)
+	}
+
+	if purchase_order.PendingReceipt != nil {
+		return purchase_order, fmt.Errorf("%w: the purchase order is being received", ErrInvalidPurchaseOrderState)
+	}
+
+	purchase_order.State = models.PurchaseOrderStateCancelled
+
+	return purchase_order, ps.save(purchase_order)
+}
+
+// logMismatch records a quantity or price of a purchase order line differing from what was ordered.
+func (ps PurchaseOrderService) logMismatch(ctx context.Context, purchase_order models.PurchaseOrder, line models.PurchaseOrderLine, kind string, expected float64, actual float64, comment string, user_id string) error {
+
+	client, err := common.GetDatabaseClient(ps.Logger, &ps.Config)
+	if err != nil {
+		return err
+	}
+
+	log := models.LogPurchaseOrderMismatch{
+		Log: models.Log{
+			Type:   models.LogTypePurchaseOrderMismatch,
+			Id:     primitive.NewObjectID().Hex(),
+			Date:   time.Now(),
+			UserId: user_id,
+		},
+		PurchaseOrderId: purchase_order.Id,
+		SupplierId:      purchase_order.SupplierId,
+		LineId:          line.Id,
+		MaterialId:      line.MaterialId,
+		Kind:            kind,
+		Expected:        expected,
+		Actual:          actual,
+		Comment:         comment,
+	}
+
+	_, err = client.Database(ps.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, log)
+	return err
+}
+
+// ReceivePurchaseOrder adds a material entry at the agreed price for each received line of a sent purchase order.
+// Receiving more than ordered and invoiced prices differing from the agreed ones are logged as mismatches. The
+// purchase order is received once every line is, or when close_order is set, in which case the lines received short
+// are logged as well. The receipt is recorded on the purchase order before any stock is added, a receipt that
+// was interrupted is completed by the next call instead of receiving the given lines.
+func (ps PurchaseOrderService) ReceivePurchaseOrder(purchase_order_id string, lines []models.PurchaseOrderReceiptLine, close_order bool, user_id string) (purchase_order models.PurchaseOrder, err error) {
+
+	purchase_order, err = ps.GetPurchaseOrder(purchase_order_id)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	if purchase_order.PendingReceipt != nil {
+		return ps.completeReceipt(purchase_order, user_id)
+	}
+
+	if purchase_order.State != models.PurchaseOrderStateSent && purchase_order.State != models.PurchaseOrderStatePartiallyReceived {
+		return purchase_order, fmt.Errorf("%w: only sent or partially received purchase orders can be received", ErrInvalidPurchaseOrderState)
+	}
+
+	if len(lines) == 0 && !close_order {
+		return purchase_order, fmt.Errorf("%w: at least one received line is required", ErrInvalidPurchaseOrder)
+	}
+
+	indexes := make(map[string]int)
+	for index, line := range purchase_order.Lines {
+		indexes[line.Id] = index
+	}
+
+	receipt := models.PurchaseOrderReceipt{
+		Id:         primitive.NewObjectID().Hex(),
+		Date:       time.Now(),
+		UserId:     user_id,
+		Lines:      make([]models.PurchaseOrderReceiptLine, 0, len(lines)),
+		CloseOrder: close_order,
+	}
+
+	for _, received := range lines {
+		if _, ok := indexes[received.LineId]; !ok {
+			return purchase_order, fmt.Errorf("%w: line %s not found", ErrInvalidPurchaseOrder, received.LineId)
+		}
+
+		if received.Quantity <= 0 {
+			return purchase_order, fmt.Errorf("%w: received quantity of line %s must be positive", ErrInvalidPurchaseOrder, received.LineId)
+		}
+
+		received.EntryId = primitive.NewObjectID().Hex()
+		receipt.Lines = append(receipt.Lines, received)
+	}
+
+	client, err := common.GetDatabaseClient(ps.Logger, &ps.Config)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// the purchase orders saved before versioning have no version
+	version := bson.M{"$in": bson.A{purchase_order.Version, nil}}
+	if purchase_order.Version > 0 {
+		version = bson.M{"$eq": purchase_order.Version}
+	}
+
+	result, err := client.Database(ps.Config.Databases[0].Database).Collection("purchase_orders").UpdateOne(ctx, bson.M{
+		"id":              purchase_order.Id,
+		"state":           purchase_order.State,
+		"version":         version,
+		"pending_receipt": bson.M{"$exists": false},
+	}, bson.M{
+		"$set": bson.M{"version": purchase_order.Version + 1, "pending_receipt": receipt},
+	})
+	if err != nil {
+		return purchase_order, err
+	}
+
+	if result.MatchedCount == 0 {
+		return purchase_order, fmt.Errorf("%w: the purchase order was changed by another receipt, reload it", ErrInvalidPurchaseOrderState)
+	}
+
+	purchase_order.Version++
+	purchase_order.PendingReceipt = &receipt
+
+	return ps.completeReceipt(purchase_order, user_id)
+}
+
+// completeReceipt adds the pending receipt of the purchase order to stock and to its received quantities. The
+// entries of the receipt have their ids already so completing it again doesn't add them twice.
+func (ps PurchaseOrderService) completeReceipt(purchase_order models.PurchaseOrder, user_id string) (models.PurchaseOrder, error) {
+
+	receipt := *purchase_order.PendingReceipt
+
+	client, err := common.GetDatabaseClient(ps.Logger, &ps.Config)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	material_svc := MaterialService{
+		Logger:   ps.Logger,
+		Config:   ps.Config,
+		Settings: ps.Settings,
+	}
+
+	indexes := make(map[string]int)
+	for index, line := range purchase_order.Lines {
+		indexes[line.Id] = index
+	}
+
+	for _, received := range receipt.Lines {
+		line := &purchase_order.Lines[indexes[received.LineId]]
+
+		_, err := material_svc.PushMaterialEntry(line.MaterialId, []models.MaterialEntry{{
+			Id:             received.EntryId,
+			Quantity:       received.Quantity,
+			Unit:           line.Unit,
+			PurchasePrice:  received.Quantity * line.UnitPrice,
+			Company:        purchase_order.SupplierName,
+			SupplierId:     purchase_order.SupplierId,
+			SKU:            line.SKU,
+			ExpirationDate: received.ExpirationDate,
+			LotNumber:      received.LotNumber,
+			LocationId:     purchase_order.LocationId,
+		}}, user_id)
+		if err != nil {
+			return purchase_order, err
+		}
+
+		line.ReceivedQuantity += received.Quantity
+
+		if received.InvoicedUnitPrice > 0 && !nearlyEqual(received.InvoicedUnitPrice, line.UnitPrice) {
+			err = ps.logMismatch(ctx, purchase_order, *line, PurchaseOrderMismatchPrice, line.UnitPrice, received.InvoicedUnitPrice, received.Comment, user_id)
+			if err != nil {
+				return purchase_order, err
+			}
+		}
+
+		if line.ReceivedQuantity > line.Quantity && !nearlyEqual(line.ReceivedQuantity, line.Quantity) {
+			err = ps.logMismatch(ctx, purchase_order, *line, PurchaseOrderMismatchOverReceived, line.Quantity, line.ReceivedQuantity, received.Comment, user_id)
+			if err != nil {
+				return purchase_order, err
+			}
+		}
+	}
+
+	fully_received := true
+	for _, line := range purchase_order.Lines {
+		if line.ReceivedQuantity < line.Quantity && !nearlyEqual(line.ReceivedQuantity, line.Quantity) {
+			fully_received = false
+		}
+	}
+
+	if receipt.CloseOrder && !fully_received {
+		for _, line := range purchase_order.Lines {
+			if line.ReceivedQuantity < line.Quantity && !nearlyEqual(line.ReceivedQuantity, line.Quantity) {
+				err = ps.logMismatch(ctx, purchase_order, line, PurchaseOrderMismatchShortReceived, line.Quantity, line.ReceivedQuantity, "closed short", user_id)
+				if err != nil {
+					return purchase_order, err
+				}
+			}
+		}
+	}
+
+	if len(receipt.Lines) > 0 {
+		purchase_order.Receipts = append(purchase_order.Receipts, receipt)
+	}
+
+	if fully_received || receipt.CloseOrder {
+		purchase_order.State = models.PurchaseOrderStateReceived
+		purchase_order.ReceivedAt = receipt.Date
+	} else {
+		purchase_order.State = models.PurchaseOrderStatePartiallyReceived
+	}
+
+	purchase_order.PendingReceipt = nil
+
+	err = ps.save(purchase_order)
+	if err != nil {
+		return purchase_order, err
+	}
+
+	log := models.LogPurchaseOrderReceive{
+		Log: models.Log{
+			Type:   models.LogTypePurchaseOrderReceive,
+			Id:     primitive.NewObjectID().Hex(),
+			Date:   receipt.Date,
+			UserId: user_id,
+		},
+		PurchaseOrderId: purchase_order.Id,
+		SupplierId:      purchase_order.SupplierId,
+		Receipt:         receipt,
+	}
+
+	_, err = client.Database(ps.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, log)
+	return purchase_order, err
+}
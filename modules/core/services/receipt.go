@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/aymerick/raymond"
+	"github.com/boombuler/barcode/qr"
 	"github.com/chromedp/cdproto/page"
 	"github.com/chromedp/chromedp"
 	"github.com/elmawardy/escpos"
@@ -26,8 +27,29 @@ type ReceiptService struct {
 	Logger   logger.ILogger
 }
 
-// Print is used to print a 80mm receipt
-func (rs *ReceiptService) Print(order models.Order, discount float64, service_cost float64, d time.Time, lang_code string, template_path string, printer_host string, shop_mode string) error {
+// Print is used to print a 80mm receipt, template is the handlebars content of the receipt template.
+func (rs *ReceiptService) Print(order models.Order, discount float64, service_cost float64, d time.Time, lang_code string, template string, printer_host string, shop_mode string) error {
+
+	output, err := rs.RenderHTML(order, discount, service_cost, d, lang_code, template, shop_mode)
+	if err != nil {
+		return err
+	}
+
+	return rs.PrintHTML(output, printer_host)
+}
+
+// PrintHTML prints an already rendered receipt html to the printer at printer_host.
+func (rs *ReceiptService) PrintHTML(output string, printer_host string) error {
+
+	buf, err := rs.RenderPNG(output)
+	if err != nil {
+		return err
+	}
+
+	img, err := png.Decode(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
 
 	socket, err := net.Dial("tcp", fmt.Sprintf("%s:9100", printer_host))
 
@@ -38,6 +60,27 @@ func (rs *ReceiptService) Print(order models.Order, discount float64, service_co
 
 	p := escpos.New(socket)
 
+	p.Size(1, 1).PrintImage(img)
+	p.LineFeed()
+
+	p.PrintAndCut()
+
+	return nil
+}
+
+// RenderHTML renders the receipt handlebars template content for the given order into html.
+func (rs *ReceiptService) RenderHTML(order models.Order, discount float64, service_cost float64, d time.Time, lang_code string, template_content string, shop_mode string) (string, error) {
+	data, err := rs.RenderData(order, discount, service_cost, d, lang_code, shop_mode)
+	if err != nil {
+		return "", err
+	}
+
+	return rs.RenderTemplate(template_content, data)
+}
+
+// RenderData builds the handlebars data context of the order receipt.
+func (rs *ReceiptService) RenderData(order models.Order, discount float64, service_cost float64, d time.Time, lang_code string, shop_mode string) (map[string]interface{}, error) {
+
 	lang_svc := LanguageService{
 		Config:   rs.Config,
 		Settings: rs.Settings,
@@ -46,20 +89,23 @@ func (rs *ReceiptService) Print(order models.Order, discount float64, service_co
 
 	lang, err := lang_svc.GetLanguage(lang_code)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	order_items := make([]map[string]interface{}, len(order.Items))
-	subtotal := 0
+	order_items := make([]map[string]interface{}, 0, len(order.Items))
+	subtotal := 0.0
 
+	// the item sale price is already the line total
 	for _, item := range order.Items {
 		order_items = append(order_items,
-			map[string]interface{}{"name": item.Product.Name, "quantity": item.Quantity, "price": item.SalePrice * item.Quantity},
+			map[string]interface{}{"name": item.Product.Name, "quantity": item.Quantity, "price": item.SalePrice, "comment": item.Comment},
 		)
-		subtotal += int(item.SalePrice) * int(item.Quantity)
+		subtotal += item.SalePrice
 	}
 
-	total := subtotal - int(discount)
+	// the same as the order sale price stored on submit, also right for the orders not priced yet or priced
+	// without their discount when finished
+	total := subtotal - discount
 
 	custom_data := []struct {
 		Key   string
@@ -98,6 +144,12 @@ func (rs *ReceiptService) Print(order models.Order, discount float64, service_co
 		"is_kitchen_mode": shop_mode == "kitchen",
 	}
 
+	if order.InvoiceNumber > 0 {
+		data["has_invoice_number"] = true
+		data["t_invoice_number"] = lang.Pack["invoice_number"]
+		data["invoice_number"] = order.InvoiceNumber
+	}
+
 	if order.IsDelivery {
 		data["is_delivery"] = true
 		data["t_delivery_address"] = lang.Pack["delivery_address"]
@@ -111,11 +163,78 @@ func (rs *ReceiptService) Print(order models.Order, discount float64, service_co
 		data["is_delivery"] = false
 	}
 
-	template, err := raymond.ParseFile(template_path)
+	if rs.Config.DigitalReceipts.Enabled && order.Id != "" {
+		digital_receipt_svc := DigitalReceiptService{
+			Logger:   rs.Logger,
+			Config:   rs.Config,
+			Settings: rs.Settings,
+		}
+
+		url, _, err := digital_receipt_svc.ReceiptURL(order.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		data["digital_receipt_url"] = url
+		data["t_digital_receipt"] = lang.Pack["digital_receipt"]
+	}
+
+	if rs.Settings.Fiscal.EInvoiceEnabled {
+		invoice := NewEInvoice(rs.Settings.Fiscal, total, d)
+
+		payload, err := invoice.EncodeTLV()
+		if err != nil {
+			return nil, err
+		}
+
+		data["has_einvoice"] = true
+		data["t_seller"] = lang.Pack["seller"]
+		data["t_vat_number"] = lang.Pack["vat_number"]
+		data["t_vat"] = lang.Pack["vat"]
+		data["t_total_excluding_vat"] = lang.Pack["total_excluding_vat"]
+		data["seller_name"] = invoice.SellerName
+		data["vat_number"] = invoice.VATNumber
+		data["vat_total"] = fmt.Sprintf("%.2f", invoice.VATTotal)
+		data["total_with_vat"] = fmt.Sprintf("%.2f", invoice.Total)
+		data["total_excluding_vat"] = fmt.Sprintf("%.2f", invoice.Total-invoice.VATTotal)
+		data["einvoice_timestamp"] = invoice.Timestamp.Format(time.RFC3339)
+		data["einvoice_qr"] = payload
+	} else {
+		data["has_einvoice"] = false
+	}
+
+	return data, nil
+}
+
+// RenderTemplate parses the handlebars template content and executes it with the receipt data.
+func (rs *ReceiptService) RenderTemplate(template_content string, data map[string]interface{}) (string, error) {
+	template, err := raymond.Parse(template_content)
 	if err != nil {
-		return err
+		return "", err
 	}
 
+	RegisterReceiptCodeHelpers(template)
+
+	// {{digital_receipt_qrcode 200}} renders the QR code of the order digital receipt link, nothing when digital receipts are disabled
+	template.RegisterHelper("digital_receipt_qrcode", func(size int) raymond.SafeString {
+		url, ok := data["digital_receipt_url"].(string)
+		if !ok || url == "" {
+			return ""
+		}
+
+		code, err := qr.Encode(url, qr.M, qr.Auto)
+		if err != nil {
+			return ""
+		}
+
+		tag, err := barcodeImageTag(code, size, size)
+		if err != nil {
+			return ""
+		}
+
+		return raymond.SafeString(tag)
+	})
+
 	template.RegisterHelper("getByKey", func(items []struct {
 		Key   string
 		Value string
@@ -139,10 +258,11 @@ func (rs *ReceiptService) Print(order models.Order, discount float64, service_co
 		return fmt.Sprintf("%.2f", f1+f2)
 	})
 
-	output, err := template.Exec(data)
-	if err != nil {
-		return err
-	}
+	return template.Exec(data)
+}
+
+// RenderPNG renders the receipt html into a png image with the 80mm printer width.
+func (rs *ReceiptService) RenderPNG(output string) ([]byte, error) {
 
 	// create context
 	ctx, cancel := chromedp.NewContext(
@@ -171,7 +291,7 @@ func (rs *ReceiptService) Print(order models.Order, discount float64, service_co
 
 	// Capture the screenshot
 	var buf []byte
-	err = chromedp.Run(ctx,
+	err := chromedp.Run(ctx,
 		chromedp.EmulateViewport(int64(width), 0, chromedp.EmulateScale(1.0)),
 		chromedp.Navigate(uri),
 		chromedp.ActionFunc(func(ctx context.Context) error {
@@ -183,22 +303,8 @@ func (rs *ReceiptService) Print(order models.Order, discount float64, service_co
 	)
 
 	if err != nil {
-		return err
-	}
-
-	// if err := os.WriteFile("fullScreenshot.png", buf, 0o644); err != nil {
-	// 	log.Fatal(err)
-	// }
-
-	img, err := png.Decode(bytes.NewReader(buf))
-	if err != nil {
-		return err
+		return nil, err
 	}
 
-	p.Size(1, 1).PrintImage(img)
-	p.LineFeed()
-
-	p.PrintAndCut()
-
-	return nil
+	return buf, nil
 }
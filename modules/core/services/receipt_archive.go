@@ -0,0 +1,213 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ReceiptArchiveService prints client receipts while archiving their rendered data, and reprints archived receipts.
+type ReceiptArchiveService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// PrintClientReceipt renders and prints the client receipt of the order, then archives the rendered data.
+func (as *ReceiptArchiveService) PrintClientReceipt(order models.Order, lang_code string, template_content string, printer_host string, user_id string) (receipt models.ReceiptArchive, err error) {
+	receipt_svc := ReceiptService{
+		Config:   as.Config,
+		Logger:   as.Logger,
+		Settings: as.Settings,
+	}
+
+	data, err := receipt_svc.RenderData(order, order.Discount, 0, order.SubmittedAt, lang_code, as.Settings.ShopMode)
+	if err != nil {
+		return receipt, err
+	}
+
+	output, err := receipt_svc.RenderTemplate(template_content, data)
+	if err != nil {
+		return receipt, err
+	}
+
+	err = receipt_svc.PrintHTML(output, printer_host)
+	if err != nil {
+		return receipt, err
+	}
+
+	receipt = models.ReceiptArchive{
+		Id:              primitive.NewObjectID().Hex(),
+		OrderId:         order.Id,
+		DisplayId:       order.DisplayId,
+		PrintedAt:       time.Now(),
+		UserId:          user_id,
+		LangCode:        lang_code,
+		PrinterHost:     printer_host,
+		TemplateContent: template_content,
+		Data:            bson.M(data),
+	}
+
+	client, err := common.GetDatabaseClient(as.Logger, &as.Config)
+	if err != nil {
+		return receipt, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = client.Database(as.Config.Databases[0].Database).Collection("receipts").InsertOne(ctx, receipt)
+	return receipt, err
+}
+
+// GetOrderReceipts returns the archived client receipts of the order, the original receipt first.
+func (as *ReceiptArchiveService) GetOrderReceipts(order_id string) (receipts []models.ReceiptArchive, err error) {
+	receipts = make([]models.ReceiptArchive, 0)
+
+	client, err := common.GetDatabaseClient(as.Logger, &as.Config)
+	if err != nil {
+		return receipts, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	cursor, err := client.Database(as.Config.Databases[0].Database).Collection("receipts").Find(ctx, bson.M{"order_id": order_id}, options.Find().SetSort(bson.M{"printed_at": 1}))
+	if err != nil {
+		return receipts, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &receipts)
+	return receipts, err
+}
+
+// GetReceipt returns an archived client receipt by id.
+func (as *ReceiptArchiveService) GetReceipt(receipt_id string) (receipt models.ReceiptArchive, err error) {
+	client, err := common.GetDatabaseClient(as.Logger, &as.Config)
+	if err != nil {
+		return receipt, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = client.Database(as.Config.Databases[0].Database).Collection("receipts").FindOne(ctx, bson.M{"id": receipt_id}).Decode(&receipt)
+	return receipt, err
+}
+
+// RenderArchivedHTML renders an archived receipt with its original template and data,
+// is_duplicate sets the "is_duplicate" data field used by templates to mark copies.
+func (as *ReceiptArchiveService) RenderArchivedHTML(receipt models.ReceiptArchive, is_duplicate bool) (string, error) {
+	receipt_svc := ReceiptService{
+		Config:   as.Config,
+		Logger:   as.Logger,
+		Settings: as.Settings,
+	}
+
+	lang_svc := LanguageService{
+		Config:   as.Config,
+		Settings: as.Settings,
+		Logger:   as.Logger,
+	}
+
+	lang, err := lang_svc.GetLanguage(receipt.LangCode)
+	if err != nil {
+		return "", err
+	}
+
+	data := map[string]interface{}(receipt.Data)
+
+	// custom data is stored as documents, the getByKey helper expects the key/value structs
+	if stored_custom_data, ok := data["custom_data"].(primitive.A); ok {
+		custom_data := []struct {
+			Key   string
+			Value string
+		}{}
+
+		for _, element := range stored_custom_data {
+			if kv, ok := element.(bson.M); ok {
+				key, _ := kv["key"].(string)
+				value, _ := kv["value"].(string)
+				custom_data = append(custom_data, struct {
+					Key   string
+					Value string
+				}{
+					Key:   key,
+					Value: value,
+				})
+			}
+		}
+
+		data["custom_data"] = custom_data
+	}
+
+	data["is_duplicate"] = is_duplicate
+	data["t_duplicate"] = lang.Pack["duplicate"]
+
+	return receipt_svc.RenderTemplate(receipt.TemplateContent, data)
+}
+
+// Reprint prints an archived receipt marked as duplicate and logs the reprint with the requesting user,
+// an empty printer_host reprints on the printer of the original receipt.
+func (as *ReceiptArchiveService) Reprint(receipt_id string, printer_host string, user_id string) (err error) {
+	receipt, err := as.GetReceipt(receipt_id)
+	if err != nil {
+		return err
+	}
+
+	if printer_host == "" {
+		printer_host = receipt.PrinterHost
+	}
+
+	output, err := as.RenderArchivedHTML(receipt, true)
+	if err != nil {
+		return err
+	}
+
+	receipt_svc := ReceiptService{
+		Config:   as.Config,
+		Logger:   as.Logger,
+		Settings: as.Settings,
+	}
+
+	err = receipt_svc.PrintHTML(output, printer_host)
+	if err != nil {
+		return err
+	}
+
+	client, err := common.GetDatabaseClient(as.Logger, &as.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = client.Database(as.Config.Databases[0].Database).Collection("receipts").UpdateOne(ctx, bson.M{"id": receipt.Id}, bson.M{"$inc": bson.M{"reprints_count": 1}})
+	if err != nil {
+		return err
+	}
+
+	log := models.LogReceiptReprint{
+		Log: models.Log{
+			Id:     primitive.NewObjectID().Hex(),
+			Type:   models.LogTypeReceiptReprint,
+			Date:   time.Now(),
+			UserId: user_id,
+		},
+		ReceiptId:   receipt.Id,
+		OrderId:     receipt.OrderId,
+		PrinterHost: printer_host,
+	}
+
+	_, err = client.Database(as.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, log)
+	return err
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aymerick/raymond"
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// DefaultClientReceiptTemplateFile is the bundled client receipt template used when no template is assigned to the printer.
+	DefaultClientReceiptTemplateFile = "order_receipt_0.handlebars"
+	// DefaultKitchenReceiptTemplateFile is the bundled kitchen receipt template used when no template is assigned to the printer.
+	DefaultKitchenReceiptTemplateFile = "kitchen_receipt_0.handlebars"
+)
+
+// ReceiptTemplateService manages the receipt templates stored in the database.
+type ReceiptTemplateService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// ValidateTemplate checks that the content is a valid handlebars template.
+func (ts *ReceiptTemplateService) ValidateTemplate(content string) error {
+	if content == "" {
+		return fmt.Errorf("template content is empty")
+	}
+
+	_, err := raymond.Parse(content)
+	if err != nil {
+		return fmt.Errorf("invalid handlebars template: %w", err)
+	}
+
+	return nil
+}
+
+// GetTemplates retrieves a page of receipt templates without their versions history.
+func (ts *ReceiptTemplateService) GetTemplates(page_number int, page_size int) (templates []models.ReceiptTemplate, totalRecords int64, err error) {
+	templates = make([]models.ReceiptTemplate, 0)
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return templates, 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	collection := client.Database(ts.Config.Databases[0].Database).Collection("receipt_templates")
+
+	totalRecords, err = collection.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		return templates, 0, err
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSort(bson.M{"name": 1})
+	findOptions.SetSkip(int64((page_number - 1) * page_size))
+	findOptions.SetLimit(int64(page_size))
+	findOptions.SetProjection(bson.M{"versions": 0})
+
+	cursor, err := collection.Find(ctx, bson.M{}, findOptions)
+	if err != nil {
+		return templates, totalRecords, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var template models.ReceiptTemplate
+		if err := cursor.Decode(&template); err != nil {
+			return templates, totalRecords, err
+		}
+
+		template.Versions = make([]models.ReceiptTemplateVersion, 0)
+		templates = append(templates, template)
+	}
+
+	return templates, totalRecords, cursor.Err()
+}
+
+// GetTemplate retrieves a receipt template with its versions history.
+func (ts *ReceiptTemplateService) GetTemplate(template_id string) (template models.ReceiptTemplate, err error) {
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return template, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err = client.Database(ts.Config.Databases[0].Database).Collection("receipt_templates").FindOne(ctx, bson.M{"id": template_id}).Decode(&template)
+	return template, err
+}
+
+// InsertTemplate validates and inserts a new receipt template as version 1.
+func (ts *ReceiptTemplateService) InsertTemplate(template models.ReceiptTemplate, user_id string) (models.ReceiptTemplate, error) {
+	if template.Type != models.ReceiptTemplateTypeClient && template.Type != models.ReceiptTemplateTypeKitchen {
+		return template, fmt.Errorf("invalid template type %q", template.Type)
+	}
+
+	err := ts.ValidateTemplate(template.Content)
+	if err != nil {
+		return template, err
+	}
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return template, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+
+	template.Id = primitive.NewObjectID().Hex()
+	template.ActiveVersion = 1
+	template.CreatedAt = now
+	template.UpdatedAt = now
+	template.Versions = []models.ReceiptTemplateVersion{
+		{
+			Version:   1,
+			Content:   template.Content,
+			CreatedAt: now,
+			UserId:    user_id,
+		},
+	}
+
+	_, err = client.Database(ts.Config.Databases[0].Database).Collection("receipt_templates").InsertOne(ctx, template)
+	return template, err
+}
+
+// UpdateTemplate validates the new content and saves it as a new active version of the template.
+func (ts *ReceiptTemplateService) UpdateTemplate(template_id string, template models.ReceiptTemplate, user_id string) (updated models.ReceiptTemplate, err error) {
+	existing, err := ts.GetTemplate(template_id)
+	if err != nil {
+		return updated, err
+	}
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return updated, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	set := bson.M{"updated_at": time.Now()}
+	update := bson.M{}
+
+	if template.Name != "" {
+		set["name"] = template.Name
+	}
+
+	if template.Content != "" && template.Content != existing.Content {
+		err = ts.ValidateTemplate(template.Content)
+		if err != nil {
+			return updated, err
+		}
+
+		version := models.ReceiptTemplateVersion{
+			Version:   len(existing.Versions) + 1,
+			Content:   template.Content,
+			CreatedAt: time.Now(),
+			UserId:    user_id,
+		}
+
+		set["content"] = version.Content
+		set["active_version"] = version.Version
+		update["$push"] = bson.M{"versions": version}
+	}
+
+	update["$set"] = set
+
+	_, err = client.Database(ts.Config.Databases[0].Database).Collection("receipt_templates").UpdateOne(ctx, bson.M{"id": template_id}, update)
+	if err != nil {
+		return updated, err
+	}
+
+	return ts.GetTemplate(template_id)
+}
+
+// ActivateVersion sets an older version of the template as the active content.
+func (ts *ReceiptTemplateService) ActivateVersion(template_id string, version int) (err error) {
+	template, err := ts.GetTemplate(template_id)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range template.Versions {
+		if v.Version == version {
+			client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+
+			_, err = client.Database(ts.Config.Databases[0].Database).Collection("receipt_templates").UpdateOne(ctx, bson.M{"id": template_id}, bson.M{"$set": bson.M{
+				"content":        v.Content,
+				"active_version": v.Version,
+				"updated_at":     time.Now(),
+			}})
+			return err
+		}
+	}
+
+	return fmt.Errorf("version %d not found in template %s", version, template_id)
+}
+
+// DeleteTemplate deletes a receipt template, it fails if the template is still assigned to a printer.
+func (ts *ReceiptTemplateService) DeleteTemplate(template_id string) (err error) {
+	if ts.Settings.ClientReceiptPrinter.TemplateId == template_id || ts.Settings.KitchenReceiptPrinter.TemplateId == template_id {
+		return fmt.Errorf("template %s is assigned to a printer", template_id)
+	}
+
+	for _, station := range ts.Settings.KitchenStations {
+		if station.Printer.TemplateId == template_id {
+			return fmt.Errorf("template %s is assigned to the %s station printer", template_id, station.Name)
+		}
+	}
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	_, err = client.Database(ts.Config.Databases[0].Database).Collection("receipt_templates").DeleteOne(ctx, bson.M{"id": template_id})
+	return err
+}
+
+// GetPrinterTemplate returns the template content assigned to the printer,
+// falling back to the bundled template file when the printer has no template.
+func (ts *ReceiptTemplateService) GetPrinterTemplate(printer models.PrinterSettings, default_file string) (content string, err error) {
+	if printer.TemplateId != "" {
+		template, err := ts.GetTemplate(printer.TemplateId)
+		if err == nil {
+			return template.Content, nil
+		}
+
+		ts.Logger.Error(fmt.Sprintf("failed to load receipt template %s, using %s: %s", printer.TemplateId, default_file, err.Error()))
+	}
+
+	pwd, err := os.Getwd()
+	if err != nil {
+		return content, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(pwd, "assets", "core", "templates", default_file))
+	if err != nil {
+		return content, err
+	}
+
+	return string(data), nil
+}
+
+// SampleOrder returns a fake order used to preview receipt templates.
+func (ts *ReceiptTemplateService) SampleOrder() models.Order {
+	return models.Order{
+		Id:          primitive.NilObjectID.Hex(),
+		DisplayId:   "A-1",
+		SubmittedAt: time.Now(),
+		Discount:    5,
+		IsPaid:      true,
+		Items: []models.OrderItem{
+			{
+				Product:   models.Product{Name: "Margherita Pizza"},
+				Quantity:  2,
+				SalePrice: 120,
+			},
+			{
+				Product:   models.Product{Name: "Cola"},
+				Quantity:  1,
+				SalePrice: 25,
+			},
+		},
+		CustomData: map[string]string{
+			"table": "4",
+		},
+	}
+}
@@ -0,0 +1,373 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// ReorderService suggests the materials to restock from their consumption, par levels and supplier lead times.
+type ReorderService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// consumedQuantities returns the quantity of each material consumed by the orders since from.
+func (rs ReorderService) consumedQuantities(ctx context.Context, from time.Time) (map[string]float64, error) {
+	consumed := make(map[string]float64)
+
+	client, err := common.GetDatabaseClient(rs.Logger, &rs.Config)
+	if err != nil {
+		return consumed, err
+	}
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"type": models.LogTypeMaterialConsume,
+			"date": bson.M{"$gte": from},
+		}},
+		bson.M{"$group": bson.M{
+			// the order consumption logs name the material component_id, the others material_id
+			"_id":      bson.M{"$ifNull": bson.A{"$component_id", "$material_id"}},
+			"quantity": bson.M{"$sum": "$quantity"},
+		}},
+	}
+
+	cursor, err := client.Database(rs.Config.Databases[0].Database).Collection("logs").Aggregate(ctx, pipeline)
+	if err != nil {
+		return consumed, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var result struct {
+			MaterialId string  `bson:"_id"`
+			Quantity   float64 `bson:"quantity"`
+		}
+
+		if err := cursor.Decode(&result); err != nil {
+			return consumed, err
+		}
+
+		consumed[result.MaterialId] = result.Quantity
+	}
+
+	return consumed, cursor.Err()
+}
+
+// orderedQuantities returns the quantity of each material, in its unit, ordered from the suppliers and not received
+// yet. Draft purchase orders count as ordered so that suggestions turned into purchase orders aren't suggested again.
+func (rs ReorderService) orderedQuantities(ctx context.Context, materials map[string]models.Material) (map[string]float64, error) {
+	ordered := make(map[string]float64)
+
+	client, err := common.GetDatabaseClient(rs.Logger, &rs.Config)
+	if err != nil {
+		return ordered, err
+	}
+
+	cursor, err := client.Database(rs.Config.Databases[0].Database).Collection("purchase_orders").Find(ctx, bson.M{
+		"state": bson.M{"$in": bson.A{models.PurchaseOrderStateDraft, models.PurchaseOrderStateSent, models.PurchaseOrderStatePartiallyReceived}},
+	})
+	if err != nil {
+		return ordered, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var purchase_order models.PurchaseOrder
+		if err := cursor.Decode(&purchase_order); err != nil {
+			return ordered, err
+		}
+
+		for _, line := range purchase_order.Lines {
+			material, ok := materials[line.MaterialId]
+			if !ok || line.ReceivedQuantity >= line.Quantity {
+				continue
+			}
+
+			quantity, err := ToMaterialUnit(material, line.Quantity-line.ReceivedQuantity, line.Unit)
+			if err != nil {
+				rs.Logger.Error(err.Error())
+				continue
+			}
+
+			ordered[line.MaterialId] += quantity
+		}
+	}
+
+	return ordered, cursor.Err()
+}
+
+// cheapestSupplier returns the supplier selling the material at the lowest price per unit of the material.
+func cheapestSupplier(material models.Material, suppliers []models.Supplier) (supplier models.Supplier, supplier_material models.SupplierMaterial, found bool) {
+	lowest := math.Inf(1)
+
+	for _, candidate := range suppliers {
+		for _, candidate_material := range candidate.Materials {
+			if candidate_material.MaterialId != material.Id {
+				continue
+			}
+
+			quantity, err := ToMaterialUnit(material, 1, candidate_material.Unit)
+			if err != nil || quantity <= 0 {
+				continue
+			}
+
+			if price := candidate_material.Price / quantity; price < lowest {
+				lowest = price
+				supplier = candidate
+				supplier_material = candidate_material
+				found = true
+			}
+		}
+	}
+
+	return supplier, supplier_material, found
+}
+
+// GetReorderSuggestions returns the materials whose stock on hand and on order fell below their reorder point,
+// averaging their consumption over the last days, the inventory consumption days when 0. A material is reordered
+// when its stock drops below what it consumes over the supplier lead time plus its par level, and is restocked up
+// to that point. When all is set every material is returned, even those not needing a restock.
+func (rs ReorderService) GetReorderSuggestions(days int, all bool) (report models.ReorderReport, err error) {
+
+	if days <= 0 {
+		days = rs.Settings.Inventory.ConsumptionDays
+	}
+	if days <= 0 {
+		days = 28
+	}
+
+	report = models.ReorderReport{
+		To:          time.Now(),
+		Days:        days,
+		Suggestions: make([]models.ReorderSuggestion, 0),
+	}
+	report.From = report.To.AddDate(0, 0, -days)
+
+	client, err := common.GetDatabaseClient(rs.Logger, &rs.Config)
+	if err != nil {
+		return report, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db := client.Database(rs.Config.Databases[0].Database)
+
+	cursor, err := db.Collection("materials").Find(ctx, bson.M{})
+	if err != nil {
+		return report, err
+	}
+
+	materials_list := make([]models.Material, 0)
+	err = cursor.All(ctx, &materials_list)
+	if err != nil {
+		return report, err
+	}
+
+	materials := make(map[string]models.Material)
+	for _, material := range materials_list {
+		materials[material.Id] = material
+	}
+
+	cursor, err = db.Collection("suppliers").Find(ctx, bson.M{})
+	if err != nil {
+		return report, err
+	}
+
+	suppliers := make([]models.Supplier, 0)
+	err = cursor.All(ctx, &suppliers)
+	if err != nil {
+		return report, err
+	}
+
+	consumed, err := rs.consumedQuantities(ctx, report.From)
+	if err != nil {
+		return report, err
+	}
+
+	ordered, err := rs.orderedQuantities(ctx, materials)
+	if err != nil {
+		return report, err
+	}
+
+	for _, material := range materials_list {
+		suggestion := models.ReorderSuggestion{
+			MaterialId:       material.Id,
+			MaterialName:     material.Name,
+			Unit:             material.Unit,
+			OnOrder:          ordered[material.Id],
+			DailyConsumption: consumed[material.Id] / float64(days),
+			OrderUnit:        material.Unit,
+		}
+
+		for _, entry := range material.Entries {
+			suggestion.OnHand += entry.Quantity
+		}
+
+		if suggestion.DailyConsumption > 0 {
+			days_of_stock := math.Round(suggestion.OnHand/suggestion.DailyConsumption*100) / 100
+			suggestion.DaysOfStock = &days_of_stock
+		}
+
+		supplier, supplier_material, found := cheapestSupplier(material, suppliers)
+		if found {
+			suggestion.SupplierId = supplier.Id
+			suggestion.SupplierName = supplier.Name
+			suggestion.SKU = supplier_material.SKU
+			suggestion.LeadTimeDays = supplier.LeadTimeDays
+			suggestion.OrderUnit = supplier_material.Unit
+			suggestion.UnitPrice = supplier_material.Price
+		}
+
+		cover_days := material.Settings.CoverDays
+		if cover_days <= 0 {
+			cover_days = rs.Settings.Inventory.CoverDays
+		}
+
+		suggestion.ParLevel = material.Settings.ParLevel
+		if suggestion.ParLevel <= 0 {
+			suggestion.ParLevel = suggestion.DailyConsumption * float64(cover_days)
+		}
+
+		suggestion.ReorderPoint = suggestion.ParLevel + suggestion.DailyConsumption*float64(suggestion.LeadTimeDays)
+
+		if missing := suggestion.ReorderPoint - suggestion.OnHand - suggestion.OnOrder; missing > 0 && !nearlyEqual(missing, 0) {
+			suggestion.SuggestedQuantity = missing
+
+			// the supplier sells whole units of its own, an empty order unit is the material unit
+			order_unit_quantity, err := ToMaterialUnit(material, 1, suggestion.OrderUnit)
+			if err != nil || order_unit_quantity <= 0 {
+				order_unit_quantity = 1
+				suggestion.OrderUnit = material.Unit
+				suggestion.UnitPrice = 0
+			}
+
+			suggestion.OrderQuantity = math.Ceil(missing/order_unit_quantity - 0.000001)
+			suggestion.EstimatedCost = suggestion.OrderQuantity * suggestion.UnitPrice
+		} else if !all {
+			continue
+		}
+
+		report.EstimatedCost += suggestion.EstimatedCost
+		report.Suggestions = append(report.Suggestions, suggestion)
+	}
+
+	// the materials running out soonest first, those consumed nothing last
+	sort.SliceStable(report.Suggestions, func(i, j int) bool {
+		if report.Suggestions[i].DaysOfStock == nil || report.Suggestions[j].DaysOfStock == nil {
+			return report.Suggestions[j].DaysOfStock == nil && report.Suggestions[i].DaysOfStock != nil
+		}
+
+		return *report.Suggestions[i].DaysOfStock < *report.Suggestions[j].DaysOfStock
+	})
+
+	return report, nil
+}
+
+// CreateReorderPurchaseOrders turns the reorder suggestions into a draft purchase order per supplier, limited to
+// material_ids when given. The suggestions without a supplier are left out.
+func (rs ReorderService) CreateReorderPurchaseOrders(material_ids []string, days int, user_id string) ([]models.PurchaseOrder, error) {
+	purchase_orders := make([]models.PurchaseOrder, 0)
+
+	report, err := rs.GetReorderSuggestions(days, false)
+	if err != nil {
+		return purchase_orders, err
+	}
+
+	selected := make(map[string]bool)
+	for _, material_id := range material_ids {
+		selected[material_id] = true
+	}
+
+	suppliers := make([]string, 0)
+	lines := make(map[string][]models.PurchaseOrderLine)
+
+	for _, suggestion := range report.Suggestions {
+		if suggestion.SupplierId == "" || suggestion.OrderQuantity <= 0 {
+			continue
+		}
+
+		if len(selected) > 0 && !selected[suggestion.MaterialId] {
+			continue
+		}
+
+		if _, ok := lines[suggestion.SupplierId]; !ok {
+			suppliers = append(suppliers, suggestion.SupplierId)
+		}
+
+		lines[suggestion.SupplierId] = append(lines[suggestion.SupplierId], models.PurchaseOrderLine{
+			MaterialId: suggestion.MaterialId,
+			SKU:        suggestion.SKU,
+			Quantity:   suggestion.OrderQuantity,
+			Unit:       suggestion.OrderUnit,
+		})
+	}
+
+	purchase_order_svc := PurchaseOrderService{
+		Logger:   rs.Logger,
+		Config:   rs.Config,
+		Settings: rs.Settings,
+	}
+
+	for _, supplier_id := range suppliers {
+		purchase_order, err := purchase_order_svc.CreatePurchaseOrder(models.PurchaseOrder{
+			SupplierId: supplier_id,
+			Lines:      lines[supplier_id],
+			Notes:      "Created from the reorder suggestions",
+		}, user_id)
+		if err != nil {
+			return purchase_orders, err
+		}
+
+		purchase_orders = append(purchase_orders, purchase_order)
+	}
+
+	return purchase_orders, nil
+}
+
+// WriteShoppingList writes the reorder suggestions as a shopping list, a row per material to order.
+func WriteShoppingList(report models.ReorderReport, writer SalesExportWriter) error {
+	err := writer.WriteRow([]interface{}{"Material", "Supplier", "SKU", "Quantity", "Unit", "Unit Price", "Estimated Cost", "On Hand", "Days Of Stock"})
+	if err != nil {
+		return err
+	}
+
+	for _, suggestion := range report.Suggestions {
+		if suggestion.OrderQuantity <= 0 {
+			continue
+		}
+
+		days_of_stock := ""
+		if suggestion.DaysOfStock != nil {
+			days_of_stock = strconv.FormatFloat(*suggestion.DaysOfStock, 'f', 2, 64)
+		}
+
+		err = writer.WriteRow([]interface{}{
+			suggestion.MaterialName,
+			suggestion.SupplierName,
+			suggestion.SKU,
+			suggestion.OrderQuantity,
+			suggestion.OrderUnit,
+			suggestion.UnitPrice,
+			suggestion.EstimatedCost,
+			math.Round(suggestion.OnHand*100) / 100,
+			days_of_stock,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
@@ -24,6 +24,8 @@ type SalesService struct {
 	Logger logger.ILogger
 	// Config is the configuration for the sales service.
 	Config config.Config
+	// Settings is used for the tax breakdown of the sales exports.
+	Settings models.Settings
 }
 
 // format 2006-01-02
@@ -162,15 +164,23 @@ func (ss *SalesService) AddOrderItemToDayRefund(refund_request dto.OrderItemRefu
 		ProductAdd:      products_adds,
 	}
 
+	business_day, err := NewBusinessDay(ss.Config)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	day := business_day.Of(now)
+
 	collection := client.Database(ss.Config.Databases[0].Database).Collection(ss.Config.Databases[0].Tables["sales"])
-	filter := bson.M{"date": time.Now().Format("2006-01-02")}
+	filter := bson.M{"date": day}
 
 	count, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
 		return err
 	}
 	if count == 0 {
-		_, err = collection.InsertOne(ctx, bson.M{"date": time.Now().Format("2006-01-02"), "refunds": []models.ItemRefund{sales_refund}, "orders": []bson.M{}, "refunds_value": refund_request.RefundValue})
+		_, err = collection.InsertOne(ctx, bson.M{"date": day, "refunds": []models.ItemRefund{sales_refund}, "orders": []bson.M{}, "refunds_value": refund_request.RefundValue})
 		if err != nil {
 			return err
 		}
@@ -186,7 +196,7 @@ func (ss *SalesService) AddOrderItemToDayRefund(refund_request dto.OrderItemRefu
 		Log: models.Log{
 			Type:   models.LogTypeOrderItemRefunded,
 			Id:     primitive.NewObjectID().Hex(),
-			Date:   time.Now(),
+			Date:   now,
 			UserId: user_id,
 		},
 		OrderId:         sales_refund.OrderId,
@@ -252,15 +262,24 @@ func (ss *SalesService) AddOrderToSalesDay(order models.Order, items_cost []mode
 		Costs: items_cost,
 	}
 
+	business_day, err := NewBusinessDay(ss.Config)
+	if err != nil {
+		return err
+	}
+
+	// the log keeps the same moment so that the day can be recomputed from the logs
+	now := time.Now()
+	day := business_day.Of(now)
+
 	collection := client.Database(ss.Config.Databases[0].Database).Collection(ss.Config.Databases[0].Tables["sales"])
-	filter := bson.M{"date": time.Now().Format("2006-01-02")}
+	filter := bson.M{"date": day}
 
 	count, err := collection.CountDocuments(ctx, filter)
 	if err != nil {
 		return err
 	}
 	if count == 0 {
-		_, err = collection.InsertOne(ctx, bson.M{"date": time.Now().Format("2006-01-02"), "refunds": []bson.M{}, "orders": []models.SalesPerDayOrder{sales_order}, "costs": sales_order.Order.Cost, "total_sales": sales_order.Order.SalePrice})
+		_, err = collection.InsertOne(ctx, bson.M{"date": day, "refunds": []bson.M{}, "orders": []models.SalesPerDayOrder{sales_order}, "costs": sales_order.Order.Cost, "total_sales": sales_order.Order.SalePrice})
 		if err != nil {
 			return err
 		}
@@ -275,7 +294,7 @@ func (ss *SalesService) AddOrderToSalesDay(order models.Order, items_cost []mode
 		Log: models.Log{
 			Type:   models.LogTypeSalesPerDayOrder,
 			Id:     primitive.NewObjectID().Hex(),
-			Date:   time.Now(),
+			Date:   now,
 			UserId: user_id,
 		},
 		SalesPerDayOrder: sales_order,
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	SalesGroupByHour          = "hour"
+	SalesGroupByWeekday       = "weekday"
+	SalesGroupByProduct       = "product"
+	SalesGroupByCategory      = "category"
+	SalesGroupByPaymentSource = "payment_source"
+	SalesGroupByServiceStyle  = "service_style"
+	SalesGroupByUser          = "user"
+)
+
+var ErrInvalidSalesGroupBy = errors.New("group_by must be one of hour, weekday, product, category, payment_source, service_style or user")
+
+// timezone returns the timezone used to compute hours and weekdays in the aggregation pipelines,
+// the server offset is used when none is configured.
+func (ss *SalesService) timezone() string {
+	if ss.Config.TimeZone != "" {
+		return ss.Config.TimeZone
+	}
+
+	return time.Now().Format("-07:00")
+}
+
+// orderGroupKey returns the aggregation expression keying an order found at the order path, e.g. $orders.
+func (ss *SalesService) orderGroupKey(group_by string, order string) interface{} {
+	switch group_by {
+	case SalesGroupByHour:
+		return bson.M{"$hour": bson.M{"date": order + ".submitted_at", "timezone": ss.timezone()}}
+	case SalesGroupByWeekday:
+		// late night sales belong to the weekday of their business day
+		business_day_date := bson.M{"$subtract": bson.A{order + ".submitted_at", int64(ss.Config.BusinessDayCutoffHour) * int64(time.Hour/time.Millisecond)}}
+		return bson.M{"$isoDayOfWeek": bson.M{"date": business_day_date, "timezone": ss.timezone()}}
+	case SalesGroupByPaymentSource:
+		return order + ".payment_source"
+	case SalesGroupByServiceStyle:
+		return bson.M{"$switch": bson.M{
+			"branches": bson.A{
+				bson.M{"case": order + ".is_delivery", "then": "delivery"},
+				bson.M{"case": order + ".is_take_away", "then": "takeaway"},
+				bson.M{"case": order + ".is_dine_in", "then": "dine_in"},
+			},
+			"default": "",
+		}}
+	}
+
+	return nil
+}
+
+// categoryLookup returns the stages joining the first category listing the product at product_path.
+func categoryLookup(product_path string) []bson.M {
+	return []bson.M{
+		{"$lookup": bson.M{
+			"from":         "categories",
+			"localField":   product_path,
+			"foreignField": "products.id",
+			"as":           "categories",
+		}},
+		{"$addFields": bson.M{"category": bson.M{"$arrayElemAt": bson.A{"$categories", 0}}}},
+	}
+}
+
+// salesPipeline returns the collection and the pipeline aggregating the sold orders between from and to.
+func (ss *SalesService) salesPipeline(group_by string, from time.Time, to time.Time) (collection string, pipeline []bson.M) {
+	in_range := bson.M{"$gte": from, "$lt": to}
+
+	// sales per user come from the logs, the sales days don't keep who closed the order
+	if group_by == SalesGroupByUser {
+		pipeline = []bson.M{
+			{"$match": bson.M{"type": models.LogTypeSalesPerDayOrder, "sales_per_day_order.submitted_at": in_range}},
+			{"$group": bson.M{
+				"_id":      "$user_id",
+				"orders":   bson.M{"$addToSet": "$sales_per_day_order.id"},
+				"quantity": bson.M{"$sum": bson.M{"$sum": "$sales_per_day_order.items.quantity"}},
+				"revenue":  bson.M{"$sum": "$sales_per_day_order.sale_price"},
+				"cost":     bson.M{"$sum": "$sales_per_day_order.cost"},
+			}},
+		}
+
+		return "logs", append(pipeline, bson.M{"$project": salesProjection()})
+	}
+
+	// the day documents are keyed on the business day, so they are matched loosely before the orders are
+	pipeline = []bson.M{
+		{"$match": bson.M{"date": bson.M{"$gte": from.AddDate(0, 0, -1).Format("2006-01-02"), "$lte": to.AddDate(0, 0, 1).Format("2006-01-02")}}},
+		{"$unwind": "$orders"},
+		{"$match": bson.M{"orders.submitted_at": in_range}},
+	}
+
+	group := bson.M{
+		"orders": bson.M{"$addToSet": "$orders.id"},
+	}
+
+	switch group_by {
+	case SalesGroupByProduct, SalesGroupByCategory:
+		pipeline = append(pipeline, bson.M{"$unwind": "$orders.items"})
+
+		if group_by == SalesGroupByProduct {
+			group["_id"] = "$orders.items.product.id"
+			group["name"] = bson.M{"$first": "$orders.items.product.name"}
+		} else {
+			pipeline = append(pipeline, categoryLookup("orders.items.product.id")...)
+			group["_id"] = bson.M{"$ifNull": bson.A{"$category.id", ""}}
+			group["name"] = bson.M{"$first": "$category.name"}
+		}
+
+		group["quantity"] = bson.M{"$sum": "$orders.items.quantity"}
+		group["revenue"] = bson.M{"$sum": "$orders.items.sale_price"}
+		group["cost"] = bson.M{"$sum": "$orders.items.cost"}
+	default:
+		group["_id"] = ss.orderGroupKey(group_by, "$orders")
+		group["quantity"] = bson.M{"$sum": bson.M{"$sum": "$orders.items.quantity"}}
+		group["revenue"] = bson.M{"$sum": "$orders.sale_price"}
+		group["cost"] = bson.M{"$sum": "$orders.cost"}
+	}
+
+	pipeline = append(pipeline, bson.M{"$group": group}, bson.M{"$project": salesProjection()})
+
+	return ss.Config.Databases[0].Tables["sales"], pipeline
+}
+
+func salesProjection() bson.M {
+	return bson.M{
+		"name":         1,
+		"quantity":     1,
+		"revenue":      1,
+		"cost":         1,
+		"orders_count": bson.M{"$size": "$orders"},
+	}
+}
+
+// refundsPipeline returns the pipeline aggregating the refunds recorded on the sales days between from and to,
+// each refund is keyed like the sale it refunds.
+func (ss *SalesService) refundsPipeline(group_by string, from time.Time, to time.Time, business_day BusinessDay) []bson.M {
+	pipeline := []bson.M{
+		{"$match": bson.M{"date": bson.M{"$gte": business_day.Of(from), "$lte": business_day.Of(to.Add(-time.Nanosecond))}}},
+		{"$unwind": "$refunds"},
+	}
+
+	var key interface{}
+
+	switch group_by {
+	case SalesGroupByProduct:
+		key = "$refunds.product_id"
+	case SalesGroupByCategory:
+		pipeline = append(pipeline, categoryLookup("refunds.product_id")...)
+		key = bson.M{"$ifNull": bson.A{"$category.id", ""}}
+	case SalesGroupByUser:
+		pipeline = append(pipeline, bson.M{"$lookup": bson.M{
+			"from":         "logs",
+			"localField":   "refunds.order_id",
+			"foreignField": "sales_per_day_order.id",
+			"as":           "sale",
+		}})
+		key = bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$sale.user_id", 0}}, ""}}
+	default:
+		pipeline = append(pipeline,
+			bson.M{"$lookup": bson.M{
+				"from":         "orders",
+				"localField":   "refunds.order_id",
+				"foreignField": "id",
+				"as":           "order",
+			}},
+			bson.M{"$unwind": bson.M{"path": "$order", "preserveNullAndEmptyArrays": true}},
+		)
+		key = ss.orderGroupKey(group_by, "$order")
+	}
+
+	return append(pipeline, bson.M{"$group": bson.M{
+		"_id":           key,
+		"refunds_count": bson.M{"$sum": 1},
+		"refunds_value": bson.M{"$sum": "$refunds.amount"},
+		"refunds_cost":  bson.M{"$sum": "$refunds.item_cost"},
+	}})
+}
+
+func aggregateSalesGroups(ctx context.Context, collection *mongo.Collection, pipeline []bson.M) ([]models.SalesAnalyticsGroup, error) {
+	groups := make([]models.SalesAnalyticsGroup, 0)
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return groups, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &groups)
+	return groups, err
+}
+
+// GetSalesAnalytics aggregates the sales between from and to grouped by hour of day, weekday, product,
+// category, payment source, service style or user, see the SalesGroupBy constants.
+func (ss *SalesService) GetSalesAnalytics(group_by string, from time.Time, to time.Time) ([]models.SalesAnalyticsGroup, error) {
+	switch group_by {
+	case SalesGroupByHour, SalesGroupByWeekday, SalesGroupByProduct, SalesGroupByCategory, SalesGroupByPaymentSource, SalesGroupByServiceStyle, SalesGroupByUser:
+	default:
+		return nil, ErrInvalidSalesGroupBy
+	}
+
+	business_day, err := NewBusinessDay(ss.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db := client.Database(ss.Config.Databases[0].Database)
+
+	sales_collection, sales_pipeline := ss.salesPipeline(group_by, from, to)
+	sales, err := aggregateSalesGroups(ctx, db.Collection(sales_collection), sales_pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	refunds, err := aggregateSalesGroups(ctx, db.Collection(ss.Config.Databases[0].Tables["sales"]), ss.refundsPipeline(group_by, from, to, business_day))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]models.SalesAnalyticsGroup, 0, len(sales))
+	indexes := make(map[string]int)
+
+	for _, group := range sales {
+		indexes[fmt.Sprint(group.Key)] = len(groups)
+		groups = append(groups, group)
+	}
+
+	for _, refund := range refunds {
+		index, ok := indexes[fmt.Sprint(refund.Key)]
+		if !ok {
+			index = len(groups)
+			indexes[fmt.Sprint(refund.Key)] = index
+			groups = append(groups, models.SalesAnalyticsGroup{Key: refund.Key})
+		}
+
+		groups[index].RefundsCount = refund.RefundsCount
+		groups[index].RefundsValue = refund.RefundsValue
+		groups[index].RefundsCost = refund.RefundsCost
+	}
+
+	for index := range groups {
+		groups[index].Margin = groups[index].Revenue - groups[index].Cost
+
+		number, is_number := groups[index].Key.(int32)
+		switch {
+		case group_by == SalesGroupByHour && is_number:
+			groups[index].Name = fmt.Sprintf("%02d:00", number)
+		case group_by == SalesGroupByWeekday && is_number:
+			groups[index].Name = time.Weekday(number % 7).String()
+		}
+	}
+
+	if group_by == SalesGroupByHour || group_by == SalesGroupByWeekday {
+		sort.SliceStable(groups, func(i, j int) bool {
+			a, _ := groups[i].Key.(int32)
+			b, _ := groups[j].Key.(int32)
+			return a < b
+		})
+	} else {
+		sort.SliceStable(groups, func(i, j int) bool {
+			return groups[i].Revenue > groups[j].Revenue
+		})
+	}
+
+	return groups, nil
+}
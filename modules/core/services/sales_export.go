@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"github.com/xuri/excelize/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	SalesExportFormatCSV  = "csv"
+	SalesExportFormatXLSX = "xlsx"
+)
+
+var ErrInvalidSalesExportFormat = errors.New("format must be csv or xlsx")
+
+// SalesExportColumn is a column of the sales exports.
+type SalesExportColumn struct {
+	Key    string
+	Header string
+	Value  func(line models.SalesExportLine) interface{}
+}
+
+// SalesExportColumns are the available columns of the sales exports, in their default order.
+var SalesExportColumns = []SalesExportColumn{
+	{"date", "Date", func(l models.SalesExportLine) interface{} { return l.Date.Format("2006-01-02 15:04:05") }},
+	{"business_day", "Business Day", func(l models.SalesExportLine) interface{} { return l.BusinessDay }},
+	{"order_id", "Order Id", func(l models.SalesExportLine) interface{} { return l.OrderId }},
+	{"display_id", "Display Id", func(l models.SalesExportLine) interface{} { return l.DisplayId }},
+	{"invoice_number", "Invoice Number", func(l models.SalesExportLine) interface{} { return l.InvoiceNumber }},
+	{"payment_source", "Payment Source", func(l models.SalesExportLine) interface{} { return l.PaymentSource }},
+	{"service_style", "Service Style", func(l models.SalesExportLine) interface{} { return l.ServiceStyle }},
+	{"customer_name", "Customer", func(l models.SalesExportLine) interface{} { return l.CustomerName }},
+	{"item_id", "Item Id", func(l models.SalesExportLine) interface{} { return l.ItemId }},
+	{"product_id", "Product Id", func(l models.SalesExportLine) interface{} { return l.ProductId }},
+	{"product_name", "Product", func(l models.SalesExportLine) interface{} { return l.ProductName }},
+	{"quantity", "Quantity", func(l models.SalesExportLine) interface{} { return l.Quantity }},
+	{"unit_price", "Unit Price", func(l models.SalesExportLine) interface{} { return l.UnitPrice }},
+	{"sale_price", "Sale Price", func(l models.SalesExportLine) interface{} { return l.SalePrice }},
+	{"discount", "Discount", func(l models.SalesExportLine) interface{} { return l.Discount }},
+	{"net_sales", "Net Sales", func(l models.SalesExportLine) interface{} { return l.NetSales }},
+	{"tax", "Tax", func(l models.SalesExportLine) interface{} { return l.Tax }},
+	{"cost", "Cost", func(l models.SalesExportLine) interface{} { return l.Cost }},
+	{"margin", "Margin", func(l models.SalesExportLine) interface{} { return l.Margin }},
+}
+
+// GetSalesExportColumns returns the export columns with the given keys in the given order,
+// all the columns are returned when no keys are given.
+func GetSalesExportColumns(keys []string) ([]SalesExportColumn, error) {
+	if len(keys) == 0 {
+		return SalesExportColumns, nil
+	}
+
+	columns := make([]SalesExportColumn, 0, len(keys))
+
+	for _, key := range keys {
+		found := false
+		for _, column := range SalesExportColumns {
+			if column.Key == strings.TrimSpace(key) {
+				columns = append(columns, column)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown export column %q", key)
+		}
+	}
+
+	return columns, nil
+}
+
+// SalesExportWriter writes the rows of a sales export in a file format.
+type SalesExportWriter interface {
+	WriteRow(values []interface{}) error
+	// Close writes out whatever is buffered, it must be called once all rows are written.
+	Close() error
+}
+
+type csvSalesExportWriter struct {
+	writer *csv.Writer
+}
+
+func (cw *csvSalesExportWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = fmt.Sprintf("%v", value)
+	}
+
+	return cw.writer.Write(record)
+}
+
+func (cw *csvSalesExportWriter) Close() error {
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+// xlsxSalesExportWriter uses the excelize stream writer, rows past its memory limit are kept in a temporary file
+// until the workbook is written out on Close.
+type xlsxSalesExportWriter struct {
+	out    io.Writer
+	file   *excelize.File
+	stream *excelize.StreamWriter
+	row    int
+}
+
+func (xw *xlsxSalesExportWriter) WriteRow(values []interface{}) error {
+	xw.row++
+
+	cell, err := excelize.CoordinatesToCellName(1, xw.row)
+	if err != nil {
+		return err
+	}
+
+	return xw.stream.SetRow(cell, values)
+}
+
+func (xw *xlsxSalesExportWriter) Close() error {
+	defer xw.file.Close()
+
+	err := xw.stream.Flush()
+	if err != nil {
+		return err
+	}
+
+	return xw.file.Write(xw.out)
+}
+
+// NewSalesExportWriter returns a writer of the format (csv or xlsx) writing to out.
+func NewSalesExportWriter(format string, out io.Writer) (SalesExportWriter, error) {
+	switch format {
+	case SalesExportFormatCSV:
+		return &csvSalesExportWriter{writer: csv.NewWriter(out)}, nil
+	case SalesExportFormatXLSX:
+		file := excelize.NewFile()
+		stream, err := file.NewStreamWriter("Sheet1")
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		return &xlsxSalesExportWriter{out: out, file: file, stream: stream}, nil
+	}
+
+	return nil, ErrInvalidSalesExportFormat
+}
+
+// serviceStyle returns dine_in, takeaway or delivery.
+func serviceStyle(order models.Order) string {
+	switch {
+	case order.IsDelivery:
+		return "delivery"
+	case order.IsTakeAway:
+		return "takeaway"
+	case order.IsDineIn:
+		return "dine_in"
+	}
+
+	return ""
+}
+
+// ExportSales streams a line per sold order item between from and to, ordered by submission time,
+// to the writer. The rows are read from a cursor so the range is never loaded in memory at once.
+func (ss *SalesService) ExportSales(from time.Time, to time.Time, columns []SalesExportColumn, writer SalesExportWriter) error {
+	business_day, err := NewBusinessDay(ss.Config)
+	if err != nil {
+		return err
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"date": bson.M{"$gte": business_day.Of(from), "$lte": business_day.Of(to.Add(-time.Nanosecond))}}},
+		{"$unwind": "$orders"},
+		{"$match": bson.M{"orders.submitted_at": bson.M{"$gte": from, "$lt": to}}},
+		{"$sort": bson.M{"orders.submitted_at": 1}},
+		{"$project": bson.M{
+			"_id": 0,
+			"order": bson.M{
+				"id":             "$orders.id",
+				"display_id":     "$orders.display_id",
+				"submitted_at":   "$orders.submitted_at",
+				"discount":       "$orders.discount",
+				"payment_source": "$orders.payment_source",
+				"invoice_number": "$orders.invoice_number",
+				"customer":       "$orders.customer",
+				"is_delivery":    "$orders.is_delivery",
+				"is_take_away":   "$orders.is_take_away",
+				"is_dine_in":     "$orders.is_dine_in",
+			},
+			"items_total": bson.M{"$sum": "$orders.items.sale_price"},
+			"item":        "$orders.items",
+		}},
+		{"$unwind": "$item"},
+	}
+
+	cursor, err := client.Database(ss.Config.Databases[0].Database).Collection(ss.Config.Databases[0].Tables["sales"]).Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	headers := make([]interface{}, len(columns))
+	for i, column := range columns {
+		headers[i] = column.Header
+	}
+
+	err = writer.WriteRow(headers)
+	if err != nil {
+		return err
+	}
+
+	for cursor.Next(ctx) {
+		var row struct {
+			Order      models.Order     `bson:"order"`
+			Item       models.OrderItem `bson:"item"`
+			ItemsTotal float64          `bson:"items_total"`
+		}
+
+		if err := cursor.Decode(&row); err != nil {
+			return err
+		}
+
+		line := models.SalesExportLine{
+			Date:          row.Order.SubmittedAt.In(business_day.Location),
+			BusinessDay:   business_day.Of(row.Order.SubmittedAt),
+			OrderId:       row.Order.Id,
+			DisplayId:     row.Order.DisplayId,
+			InvoiceNumber: row.Order.InvoiceNumber,
+			PaymentSource: row.Order.PaymentSource,
+			ServiceStyle:  serviceStyle(row.Order),
+			CustomerName:  row.Order.Customer.Name,
+			ItemId:        row.Item.Id,
+			ProductId:     row.Item.Product.Id,
+			ProductName:   row.Item.Product.Name,
+			Quantity:      row.Item.Quantity,
+			SalePrice:     row.Item.SalePrice,
+			Cost:          row.Item.Cost,
+		}
+
+		if line.Quantity != 0 {
+			line.UnitPrice = math.Round(line.SalePrice/line.Quantity*100) / 100
+		}
+
+		if row.ItemsTotal != 0 {
+			line.Discount = math.Round(row.Order.Discount*line.SalePrice/row.ItemsTotal*100) / 100
+		}
+
+		line.NetSales = line.SalePrice - line.Discount
+		line.Tax = NewEInvoice(ss.Settings.Fiscal, line.NetSales, row.Order.SubmittedAt).VATTotal
+		line.Margin = line.NetSales - line.Cost
+
+		values := make([]interface{}, len(columns))
+		for i, column := range columns {
+			values[i] = column.Value(line)
+		}
+
+		if err := writer.WriteRow(values); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
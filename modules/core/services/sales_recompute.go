@@ -0,0 +1,298 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// BusinessDaysRecompute summarizes the sales days rebuilt by RecomputeBusinessDays. Changed is the number of
+// documents left as they were because sales were added to them while rebuilding, running again moves them.
+type BusinessDaysRecompute struct {
+	Days         int
+	MovedOrders  int
+	MovedRefunds int
+	Changed      int
+}
+
+// salesDayBatch is the content taken out of a sales day document, grouped by the business day it moves to.
+type salesDayBatch struct {
+	Id   string               `bson:"id"`
+	Days []models.SalesPerDay `bson:"days"`
+}
+
+// salesDayDocument is a sales day along with its document id and the recompute bookkeeping. Recomputed is the
+// business day setup the document was rebuilt under, Batches the batches merged into it and Pending the batch
+// taken out of it and not merged yet.
+type salesDayDocument struct {
+	ObjectId           primitive.ObjectID `bson:"_id"`
+	models.SalesPerDay `bson:",inline"`
+	Recomputed         string         `bson:"recomputed,omitempty"`
+	Batches            []string       `bson:"batches,omitempty"`
+	Pending            *salesDayBatch `bson:"pending,omitempty"`
+}
+
+// logDates returns the moments of the logs of type log_type keyed by key_fields, oldest first.
+func (ss *SalesService) logDates(ctx context.Context, log_type string, key_fields ...string) (map[string][]time.Time, error) {
+	dates := make(map[string][]time.Time)
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return dates, err
+	}
+
+	projection := bson.M{"date": 1}
+	for _, field := range key_fields {
+		projection[field] = 1
+	}
+
+	cursor, err := client.Database(ss.Config.Databases[0].Database).Collection("logs").Find(ctx, bson.M{"type": log_type}, options.Find().SetProjection(projection).SetSort(bson.M{"date": 1}))
+	if err != nil {
+		return dates, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		log := cursor.Current
+
+		date, ok := log.Lookup("date").TimeOK()
+		if !ok {
+			continue
+		}
+
+		key := ""
+		for _, field := range key_fields {
+			value, _ := log.Lookup(strings.Split(field, ".")...).StringValueOK()
+			key += value + "/"
+		}
+
+		dates[key] = append(dates[key], date)
+	}
+
+	return dates, cursor.Err()
+}
+
+// RecomputeBusinessDays moves the historical orders and refunds to the business day they belong to under the
+// current timezone and cutoff hour. The moment of an order is taken from its sales log, falling back to its
+// submission time, refunds without a log stay on their day. When dry_run is set nothing is written.
+//
+// The documents are rebuilt one at a time: their sales are first taken out into a pending batch in the same
+// update, then merged into the rebuilt document of each business day, which records the batch so a batch is
+// never merged twice, and the emptied document is removed. An interrupted run is completed by the next one and
+// the documents already rebuilt under the same setup are skipped.
+func (ss *SalesService) RecomputeBusinessDays(dry_run bool) (result BusinessDaysRecompute, err error) {
+	business_day, err := NewBusinessDay(ss.Config)
+	if err != nil {
+		return result, err
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return result, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	order_dates, err := ss.logDates(ctx, models.LogTypeSalesPerDayOrder, "sales_per_day_order.id")
+	if err != nil {
+		return result, err
+	}
+
+	refund_dates, err := ss.logDates(ctx, models.LogTypeOrderItemRefunded, "order_id", "order_item_id")
+	if err != nil {
+		return result, err
+	}
+
+	setup := fmt.Sprintf("%s/%d", business_day.Location.String(), business_day.CutoffHour)
+
+	collection := client.Database(ss.Config.Databases[0].Database).Collection(ss.Config.Databases[0].Tables["sales"])
+
+	cursor, err := collection.Find(ctx, bson.M{}, options.Find().SetSort(bson.M{"date": 1}))
+	if err != nil {
+		return result, err
+	}
+
+	documents := make([]salesDayDocument, 0)
+	if err := cursor.All(ctx, &documents); err != nil {
+		return result, err
+	}
+
+	days := make(map[string]bool)
+
+	for _, document := range documents {
+
+		// the refund logs are taken in order, the ones of the refunds already rebuilt are used up as well
+		if document.Pending == nil && document.Recomputed == setup {
+			for _, refund := range document.Refunds {
+				key := refund.OrderId + "/" + refund.ItemId + "/"
+				if dates := refund_dates[key]; len(dates) > 0 {
+					refund_dates[key] = dates[1:]
+				}
+			}
+			continue
+		}
+
+		if document.Pending == nil {
+			batch := salesDayBatch{
+				Id:   primitive.NewObjectID().Hex(),
+				Days: make([]models.SalesPerDay, 0),
+			}
+
+			indexes := make(map[string]int)
+			day := func(date string) *models.SalesPerDay {
+				if _, ok := indexes[date]; !ok {
+					indexes[date] = len(batch.Days)
+					batch.Days = append(batch.Days, models.SalesPerDay{
+						Date:    date,
+						Orders:  make([]models.SalesPerDayOrder, 0),
+						Refunds: make([]models.ItemRefund, 0),
+					})
+				}
+
+				return &batch.Days[indexes[date]]
+			}
+
+			for _, order := range document.Orders {
+				date := document.Date
+				if dates := order_dates[order.Id+"/"]; len(dates) > 0 {
+					date = business_day.Of(dates[0])
+				} else if !order.Order.SubmittedAt.IsZero() {
+					date = business_day.Of(order.Order.SubmittedAt)
+				}
+
+				if date != document.Date {
+					result.MovedOrders++
+				}
+
+				sales_day := day(date)
+				sales_day.Orders = append(sales_day.Orders, order)
+				sales_day.Costs += order.Order.Cost
+				sales_day.TotalSales += order.Order.SalePrice
+			}
+
+			for _, refund := range document.Refunds {
+				date := document.Date
+				key := refund.OrderId + "/" + refund.ItemId + "/"
+				if dates := refund_dates[key]; len(dates) > 0 {
+					date = business_day.Of(dates[0])
+					refund_dates[key] = dates[1:]
+				}
+
+				if date != document.Date {
+					result.MovedRefunds++
+				}
+
+				sales_day := day(date)
+				sales_day.Refunds = append(sales_day.Refunds, refund)
+				sales_day.RefundsValue += refund.Amount
+			}
+
+			for _, sales_day := range batch.Days {
+				days[sales_day.Date] = true
+			}
+
+			if dry_run || len(batch.Days) == 0 {
+				continue
+			}
+
+			// sales are only ever pushed to a document, the same sizes tell nothing was added since it was read
+			update_result, err := collection.UpdateOne(ctx, bson.M{
+				"_id":     document.ObjectId,
+				"orders":  bson.M{"$size": len(document.Orders)},
+				"refunds": bson.M{"$size": len(document.Refunds)},
+				"pending": bson.M{"$exists": false},
+			}, bson.M{
+				"$set": bson.M{
+					"pending":       batch,
+					"orders":        bson.A{},
+					"refunds":       bson.A{},
+					"costs":         0,
+					"total_sales":   0,
+					"refunds_value": 0,
+				},
+			})
+			if err != nil {
+				return result, err
+			}
+
+			if update_result.MatchedCount == 0 {
+				result.Changed++
+				continue
+			}
+
+			document.Pending = &batch
+		} else {
+			for _, sales_day := range document.Pending.Days {
+				days[sales_day.Date] = true
+			}
+
+			if dry_run {
+				continue
+			}
+		}
+
+		err = ss.mergeSalesDayBatch(ctx, collection, document, setup)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	result.Days = len(days)
+
+	return result, nil
+}
+
+// mergeSalesDayBatch merges the pending batch of the document into the rebuilt document of each of its days, then
+// removes the document if nothing was added to it meanwhile.
+func (ss *SalesService) mergeSalesDayBatch(ctx context.Context, collection *mongo.Collection, document salesDayDocument, setup string) error {
+	batch := document.Pending
+
+	for _, sales_day := range batch.Days {
+		merged, err := collection.CountDocuments(ctx, bson.M{"date": sales_day.Date, "recomputed": setup, "batches": batch.Id})
+		if err != nil {
+			return err
+		}
+
+		if merged > 0 {
+			continue
+		}
+
+		_, err = collection.UpdateOne(ctx, bson.M{"date": sales_day.Date, "recomputed": setup}, bson.M{
+			"$push": bson.M{
+				"orders":  bson.M{"$each": sales_day.Orders},
+				"refunds": bson.M{"$each": sales_day.Refunds},
+			},
+			"$inc": bson.M{
+				"costs":         sales_day.Costs,
+				"total_sales":   sales_day.TotalSales,
+				"refunds_value": sales_day.RefundsValue,
+			},
+			"$addToSet": bson.M{"batches": batch.Id},
+		}, options.Update().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := collection.UpdateOne(ctx, bson.M{"_id": document.ObjectId, "pending.id": batch.Id}, bson.M{"$unset": bson.M{"pending": ""}})
+	if err != nil {
+		return err
+	}
+
+	_, err = collection.DeleteOne(ctx, bson.M{
+		"_id":     document.ObjectId,
+		"orders":  bson.M{"$size": 0},
+		"refunds": bson.M{"$size": 0},
+		"pending": bson.M{"$exists": false},
+	})
+	return err
+}
@@ -0,0 +1,418 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reconcileDay holds a sales day as stored, summed across its documents, and as rebuilt from the logs.
+type reconcileDay struct {
+	ids      []primitive.ObjectID
+	stored   models.SalesPerDay
+	expected models.SalesPerDay
+}
+
+func refundKey(order_id string, item_id string) string {
+	return order_id + "/" + item_id
+}
+
+// nearlyEqual compares amounts to the cent.
+func nearlyEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+// ReconcileSalesDays rebuilds the sales days between the business days from and to (inclusive) from the sales,
+// finish and refund logs and the orders collection, and reports the days whose stored aggregates or contents
+// differ. Orders finished without a sales log are rebuilt from their finish log, the tips are taken from the
+// orders as they are updated after the sale. Stored orders and refunds without a log in the range are kept.
+// When rewrite is set the differing days are replaced by their rebuilt version.
+func (ss *SalesService) ReconcileSalesDays(from string, to string, rewrite bool) (result models.SalesReconciliation, err error) {
+	result = models.SalesReconciliation{
+		From:          from,
+		To:            to,
+		Discrepancies: make([]models.SalesDayDiscrepancy, 0),
+	}
+
+	business_day, err := NewBusinessDay(ss.Config)
+	if err != nil {
+		return result, err
+	}
+
+	start, end, err := business_day.Range(from, to)
+	if err != nil {
+		return result, err
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return result, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	db := client.Database(ss.Config.Databases[0].Database)
+	sales_collection := db.Collection(ss.Config.Databases[0].Tables["sales"])
+	logs_collection := db.Collection("logs")
+
+	days := make(map[string]*reconcileDay)
+
+	day := func(date string) *reconcileDay {
+		if _, ok := days[date]; !ok {
+			days[date] = &reconcileDay{
+				stored:   models.SalesPerDay{Date: date, Orders: make([]models.SalesPerDayOrder, 0), Refunds: make([]models.ItemRefund, 0)},
+				expected: models.SalesPerDay{Date: date, Orders: make([]models.SalesPerDayOrder, 0), Refunds: make([]models.ItemRefund, 0)},
+			}
+		}
+
+		return days[date]
+	}
+
+	cursor, err := sales_collection.Find(ctx, bson.M{"date": bson.M{"$gte": from, "$lte": to}})
+	if err != nil {
+		return result, err
+	}
+
+	for cursor.Next(ctx) {
+		var document salesDayDocument
+		if err := cursor.Decode(&document); err != nil {
+			cursor.Close(ctx)
+			return result, err
+		}
+
+		sales_day := day(document.Date)
+		sales_day.ids = append(sales_day.ids, document.ObjectId)
+		sales_day.stored.Orders = append(sales_day.stored.Orders, document.Orders...)
+		sales_day.stored.Refunds = append(sales_day.stored.Refunds, document.Refunds...)
+		sales_day.stored.Costs += document.Costs
+		sales_day.stored.TotalSales += document.TotalSales
+		sales_day.stored.RefundsValue += document.RefundsValue
+	}
+	cursor.Close(ctx)
+	if err := cursor.Err(); err != nil {
+		return result, err
+	}
+
+	in_range := bson.M{"$gte": start, "$lt": end}
+
+	// known holds the orders sold in the range according to the logs
+	known := make(map[string]bool)
+
+	cursor, err = logs_collection.Find(ctx, bson.M{"type": models.LogTypeSalesPerDayOrder, "date": in_range}, options.Find().SetSort(bson.M{"date": 1}))
+	if err != nil {
+		return result, err
+	}
+
+	for cursor.Next(ctx) {
+		var log models.LogSalesPerDayOrder
+		if err := cursor.Decode(&log); err != nil {
+			cursor.Close(ctx)
+			return result, err
+		}
+
+		if known[log.SalesPerDayOrder.Id] {
+			continue
+		}
+		known[log.SalesPerDayOrder.Id] = true
+
+		sales_day := day(business_day.Of(log.Date))
+		sales_day.expected.Orders = append(sales_day.expected.Orders, log.SalesPerDayOrder)
+	}
+	cursor.Close(ctx)
+	if err := cursor.Err(); err != nil {
+		return result, err
+	}
+
+	finished := make(map[string]models.LogOrderFinish)
+
+	cursor, err = logs_collection.Find(ctx, bson.M{"type": models.LogTypeOrderFinish, "date": in_range}, options.Find().SetSort(bson.M{"date": 1}))
+	if err != nil {
+		return result, err
+	}
+
+	for cursor.Next(ctx) {
+		var log models.LogOrderFinish
+		if err := cursor.Decode(&log); err != nil {
+			cursor.Close(ctx)
+			return result, err
+		}
+
+		if _, ok := finished[log.OrderId]; !ok && !known[log.OrderId] {
+			finished[log.OrderId] = log
+		}
+	}
+	cursor.Close(ctx)
+	if err := cursor.Err(); err != nil {
+		return result, err
+	}
+
+	if len(finished) > 0 {
+		ids := make([]string, 0, len(finished))
+		for id := range finished {
+			ids = append(ids, id)
+		}
+
+		// the sale of an order finished in the range may have been logged after it
+		cursor, err = logs_collection.Find(ctx, bson.M{"type": models.LogTypeSalesPerDayOrder, "sales_per_day_order.id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"sales_per_day_order.id": 1}))
+		if err != nil {
+			return result, err
+		}
+
+		for cursor.Next(ctx) {
+			id, _ := cursor.Current.Lookup("sales_per_day_order", "id").StringValueOK()
+			delete(finished, id)
+		}
+		cursor.Close(ctx)
+		if err := cursor.Err(); err != nil {
+			return result, err
+		}
+
+		ids = ids[:0]
+		for id := range finished {
+			ids = append(ids, id)
+		}
+
+		cursor, err = db.Collection("orders").Find(ctx, bson.M{"id": bson.M{"$in": ids}, "state": "finished"})
+		if err != nil {
+			return result, err
+		}
+
+		for cursor.Next(ctx) {
+			var order models.Order
+			if err := cursor.Decode(&order); err != nil {
+				cursor.Close(ctx)
+				return result, err
+			}
+
+			finish := finished[order.Id]
+			order.Cost = finish.Cost
+			order.SalePrice = finish.SalePrice
+			known[order.Id] = true
+
+			sales_day := day(business_day.Of(finish.Date))
+			sales_day.expected.Orders = append(sales_day.expected.Orders, models.SalesPerDayOrder{
+				Id:    order.Id,
+				Order: order,
+				Costs: finish.Items,
+			})
+		}
+		cursor.Close(ctx)
+		if err := cursor.Err(); err != nil {
+			return result, err
+		}
+	}
+
+	logged_refunds := make(map[string]int)
+
+	cursor, err = logs_collection.Find(ctx, bson.M{"type": models.LogTypeOrderItemRefunded, "date": in_range}, options.Find().SetSort(bson.M{"date": 1}))
+	if err != nil {
+		return result, err
+	}
+
+	for cursor.Next(ctx) {
+		var log models.LogOrderItemRefund
+		if err := cursor.Decode(&log); err != nil {
+			cursor.Close(ctx)
+			return result, err
+		}
+
+		logged_refunds[refundKey(log.OrderId, log.ItemId)]++
+
+		sales_day := day(business_day.Of(log.Date))
+		sales_day.expected.Refunds = append(sales_day.expected.Refunds, models.ItemRefund{
+			OrderId:         log.OrderId,
+			ItemId:          log.ItemId,
+			ProductId:       log.ProductId,
+			Reason:          log.Reason,
+			Amount:          log.Amount,
+			ItemCost:        log.ItemCost,
+			Destination:     log.Destination,
+			MaterialRerunds: log.MaterialRerunds,
+			ProductAdd:      log.ProductAdd,
+		})
+	}
+	cursor.Close(ctx)
+	if err := cursor.Err(); err != nil {
+		return result, err
+	}
+
+	dates := make([]string, 0, len(days))
+	for date := range days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+
+	// the stored orders and refunds without a log in the range are kept on the day they are stored on
+	kept := make(map[string]bool)
+	order_ids := make([]string, 0)
+
+	for _, date := range dates {
+		sales_day := days[date]
+
+		for _, order := range sales_day.stored.Orders {
+			if !known[order.Id] && !kept[order.Id] {
+				kept[order.Id] = true
+				sales_day.expected.Orders = append(sales_day.expected.Orders, order)
+			}
+		}
+
+		for _, refund := range sales_day.stored.Refunds {
+			key := refundKey(refund.OrderId, refund.ItemId)
+			if logged_refunds[key] > 0 {
+				logged_refunds[key]--
+				continue
+			}
+
+			sales_day.expected.Refunds = append(sales_day.expected.Refunds, refund)
+		}
+
+		for _, order := range sales_day.expected.Orders {
+			order_ids = append(order_ids, order.Id)
+		}
+	}
+
+	tips := make(map[string]float64)
+
+	cursor, err = db.Collection("orders").Find(ctx, bson.M{"id": bson.M{"$in": order_ids}}, options.Find().SetProjection(bson.M{"id": 1, "tips": 1}))
+	if err != nil {
+		return result, err
+	}
+
+	for cursor.Next(ctx) {
+		var order models.Order
+		if err := cursor.Decode(&order); err != nil {
+			cursor.Close(ctx)
+			return result, err
+		}
+
+		tips[order.Id] = order.Tips
+	}
+	cursor.Close(ctx)
+	if err := cursor.Err(); err != nil {
+		return result, err
+	}
+
+	result.Days = len(dates)
+
+	for _, date := range dates {
+		sales_day := days[date]
+		expected := &sales_day.expected
+
+		sort.SliceStable(expected.Orders, func(i, j int) bool {
+			return expected.Orders[i].Order.SubmittedAt.Before(expected.Orders[j].Order.SubmittedAt)
+		})
+
+		discrepancy := models.SalesDayDiscrepancy{
+			Date:               date,
+			Documents:          len(sales_day.ids),
+			StoredOrders:       len(sales_day.stored.Orders),
+			ExpectedOrders:     len(expected.Orders),
+			StoredTotalSales:   sales_day.stored.TotalSales,
+			StoredCosts:        sales_day.stored.Costs,
+			StoredRefunds:      len(sales_day.stored.Refunds),
+			ExpectedRefunds:    len(expected.Refunds),
+			StoredRefundsValue: sales_day.stored.RefundsValue,
+			MissingOrders:      make([]string, 0),
+			UnloggedOrders:     make([]string, 0),
+			DuplicateOrders:    make([]string, 0),
+			MissingRefunds:     make([]string, 0),
+		}
+
+		stored_orders := make(map[string]int)
+		for _, order := range sales_day.stored.Orders {
+			stored_orders[order.Id]++
+			discrepancy.StoredTips += order.Order.Tips
+
+			if stored_orders[order.Id] == 2 {
+				discrepancy.DuplicateOrders = append(discrepancy.DuplicateOrders, order.Id)
+			}
+		}
+
+		for index := range expected.Orders {
+			order := &expected.Orders[index]
+			if order_tips, ok := tips[order.Id]; ok {
+				order.Order.Tips = order_tips
+			}
+
+			expected.Costs += order.Order.Cost
+			expected.TotalSales += order.Order.SalePrice
+			discrepancy.ExpectedTips += order.Order.Tips
+
+			switch {
+			case kept[order.Id]:
+				discrepancy.UnloggedOrders = append(discrepancy.UnloggedOrders, order.Id)
+			case stored_orders[order.Id] == 0:
+				discrepancy.MissingOrders = append(discrepancy.MissingOrders, order.Id)
+			}
+		}
+
+		stored_refunds := make(map[string]int)
+		for _, refund := range sales_day.stored.Refunds {
+			stored_refunds[refundKey(refund.OrderId, refund.ItemId)]++
+		}
+
+		for _, refund := range expected.Refunds {
+			expected.RefundsValue += refund.Amount
+
+			key := refundKey(refund.OrderId, refund.ItemId)
+			if stored_refunds[key] > 0 {
+				stored_refunds[key]--
+				continue
+			}
+
+			discrepancy.MissingRefunds = append(discrepancy.MissingRefunds, key)
+		}
+
+		discrepancy.ExpectedTotalSales = expected.TotalSales
+		discrepancy.ExpectedCosts = expected.Costs
+		discrepancy.ExpectedRefundsValue = expected.RefundsValue
+
+		differs := discrepancy.Documents > 1 ||
+			discrepancy.StoredOrders != discrepancy.ExpectedOrders ||
+			discrepancy.StoredRefunds != discrepancy.ExpectedRefunds ||
+			len(discrepancy.MissingOrders) > 0 ||
+			len(discrepancy.DuplicateOrders) > 0 ||
+			len(discrepancy.MissingRefunds) > 0 ||
+			!nearlyEqual(discrepancy.StoredTotalSales, discrepancy.ExpectedTotalSales) ||
+			!nearlyEqual(discrepancy.StoredCosts, discrepancy.ExpectedCosts) ||
+			!nearlyEqual(discrepancy.StoredTips, discrepancy.ExpectedTips) ||
+			!nearlyEqual(discrepancy.StoredRefundsValue, discrepancy.ExpectedRefundsValue)
+
+		if !differs {
+			continue
+		}
+
+		result.Discrepancies = append(result.Discrepancies, discrepancy)
+
+		if !rewrite {
+			continue
+		}
+
+		// the rebuilt day is written before the stored documents are removed so that a failure never loses sales
+		if len(expected.Orders) > 0 || len(expected.Refunds) > 0 {
+			_, err = sales_collection.InsertOne(ctx, expected)
+			if err != nil {
+				return result, err
+			}
+		}
+
+		if len(sales_day.ids) > 0 {
+			_, err = sales_collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": sales_day.ids}})
+			if err != nil {
+				return result, err
+			}
+		}
+	}
+
+	result.Rewritten = rewrite
+
+	return result, nil
+}
@@ -9,6 +9,7 @@ package services
 import (
 	"context"
 	"log"
+	"time"
 
 	"github.com/nutrixpos/pos/common"
 	"github.com/nutrixpos/pos/common/config"
@@ -57,8 +58,14 @@ func (s *Seeder) SeedSettings() error {
 		settingsCollection := db.Collection("settings")
 		settings := models.Settings{
 			Id: primitive.NewObjectID().Hex(),
-			Inventory: models.MaterialSettings{
-				StockAlertTreshold: 1000,
+			Inventory: models.InventorySettings{
+				MaterialSettings: models.MaterialSettings{
+					StockAlertTreshold: 1000,
+					CoverDays:          7,
+					ExpiryWarningDays:  14,
+				},
+				CostAlertThresholdPercent: 15,
+				ConsumptionDays:           28,
 			},
 			Orders: models.OrderSettings{
 				Queues: []models.OrderQueueSettings{
@@ -79,14 +86,21 @@ func (s *Seeder) SeedSettings() error {
 			KitchenReceiptPrinter: models.PrinterSettings{
 				Host: "192.168.123.123",
 			},
+			KitchenStations: []models.KitchenStation{},
 			PaymentSources: []models.PaymentSource{
 				{
-					Name: "Cash",
+					Name:   "Cash",
+					IsCash: true,
 				},
 				{
 					Name: "Card",
 				},
 			},
+			WasteDigest: models.WasteDigestSettings{
+				Weekday: int(time.Monday),
+				Hour:    8,
+				TopN:    5,
+			},
 		}
 		_, err = settingsCollection.InsertOne(ctx, settings)
 		if err != nil {
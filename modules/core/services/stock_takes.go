@@ -0,0 +1,539 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidStockTake      = errors.New("invalid stock take")
+	ErrInvalidStockTakeState = errors.New("the stock take state doesn't allow this operation")
+)
+
+// StockTakeService manages the physical counts of the stock and the adjustments posted from them.
+type StockTakeService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+type GetStockTakesParams struct {
+	PageNumber int
+	PageSize   int
+	State      string
+}
+
+// GetStockTakes returns the stock takes newest first, without their lines.
+func (ss StockTakeService) GetStockTakes(params GetStockTakesParams) (stock_takes []models.StockTake, total_records int, err error) {
+
+	stock_takes = make([]models.StockTake, 0)
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return stock_takes, total_records, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := client.Database(ss.Config.Databases[0].Database).Collection("stock_takes")
+
+	filter := bson.M{}
+	if params.State != "" {
+		filter["state"] = params.State
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((params.PageNumber - 1) * params.PageSize))
+	findOptions.SetLimit(int64(params.PageSize))
+	findOptions.SetSort(bson.M{"created_at": -1})
+	findOptions.SetProjection(bson.M{"lines": 0})
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return stock_takes, total_records, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &stock_takes)
+	if err != nil {
+		return stock_takes, total_records, err
+	}
+
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return stock_takes, total_records, err
+	}
+	total_records = int(count)
+
+	return stock_takes, total_records, err
+}
+
+func (ss StockTakeService) GetStockTake(stock_take_id string) (stock_take models.StockTake, err error) {
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = client.Database(ss.Config.Databases[0].Database).Collection("stock_takes").FindOne(ctx, bson.M{"id": stock_take_id}).Decode(&stock_take)
+	return stock_take, err
+}
+
+// save replaces the stored stock take with the given one.
+func (ss StockTakeService) save(stock_take models.StockTake) error {
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.Database(ss.Config.Databases[0].Database).Collection("stock_takes").ReplaceOne(ctx, bson.M{"id": stock_take.Id}, stock_take)
+	return err
+}
+
+// computeVariance fills the variance of the counted lines and the totals of the stock take.
+func computeVariance(stock_take *models.StockTake) {
+	stock_take.CountedLines = 0
+	stock_take.ExpectedValue = 0
+	stock_take.VarianceValue = 0
+
+	for index := range stock_take.Lines {
+		line := &stock_take.Lines[index]
+
+		stock_take.ExpectedValue += line.ExpectedQuantity * line.UnitCost
+
+		if line.CountedQuantity == nil {
+			line.VarianceQuantity = 0
+			line.VarianceValue = 0
+			continue
+		}
+
+		stock_take.CountedLines++
+		line.VarianceQuantity = *line.CountedQuantity - line.ExpectedQuantity
+		line.VarianceValue = line.VarianceQuantity * line.UnitCost
+		stock_take.VarianceValue += line.VarianceValue
+	}
+}
+
+// CreateStockTake opens a stock take with a line per entry of its materials, all of them when no material
+// is given, holding the quantity expected in stock now. Only the entries at its location are counted when set.
+func (ss StockTakeService) CreateStockTake(stock_take models.StockTake, user_id string) (models.StockTake, error) {
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return stock_take, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	filter := bson.M{}
+	if len(stock_take.MaterialIds) > 0 {
+		filter["id"] = bson.M{"$in": stock_take.MaterialIds}
+	} else {
+		stock_take.MaterialIds = make([]string, 0)
+	}
+
+	cursor, err := client.Database(ss.Config.Databases[0].Database).Collection("materials").Find(ctx, filter, options.Find().SetSort(bson.M{"name": 1}))
+	if err != nil {
+		return stock_take, err
+	}
+	defer cursor.Close(ctx)
+
+	materials := make([]models.Material, 0)
+	if err := cursor.All(ctx, &materials); err != nil {
+		return stock_take, err
+	}
+
+	if len(stock_take.MaterialIds) > 0 && len(materials) != len(stock_take.MaterialIds) {
+		return stock_take, fmt.Errorf("%w: some of the materials were not found", ErrInvalidStockTake)
+	}
+
+	stock_take.Id = primitive.NewObjectID().Hex()
+	stock_take.State = models.StockTakeStateOpen
+	stock_take.CreatedAt = time.Now()
+	stock_take.PostedAt = time.Time{}
+	stock_take.PostedBy = ""
+	stock_take.UserId = user_id
+	stock_take.Lines = make([]models.StockTakeLine, 0)
+
+	if stock_take.Name == "" {
+		stock_take.Name = fmt.Sprintf("Stock take %s", stock_take.CreatedAt.Format("2006-01-02"))
+	}
+
+	default_location_id := ""
+	if stock_take.LocationId != "" {
+		location_svc := LocationService{
+			Logger: ss.Logger,
+			Config: ss.Config,
+		}
+
+		if _, err := location_svc.GetLocation(stock_take.LocationId); err != nil {
+			return stock_take, fmt.Errorf("%w: location %s not found", ErrInvalidStockTake, stock_take.LocationId)
+		}
+
+		default_location_id, err = location_svc.GetDefaultLocationId()
+		if err != nil {
+			return stock_take, err
+		}
+	}
+
+	for _, material := range materials {
+		for _, entry := range material.Entries {
+			if !entryAt(entry, stock_take.LocationId, default_location_id) {
+				continue
+			}
+
+			unit_cost := 0.0
+			if entry.PurchaseQuantity != 0 {
+				unit_cost = entry.PurchasePrice / entry.PurchaseQuantity
+			}
+
+			stock_take.Lines = append(stock_take.Lines, models.StockTakeLine{
+				MaterialId:       material.Id,
+				MaterialName:     material.Name,
+				EntryId:          entry.Id,
+				SKU:              entry.SKU,
+				Unit:             material.Unit,
+				UnitCost:         unit_cost,
+				ExpectedQuantity: entry.Quantity,
+			})
+		}
+	}
+
+	if len(stock_take.Lines) == 0 {
+		return stock_take, fmt.Errorf("%w: there are no material entries to count", ErrInvalidStockTake)
+	}
+
+	computeVariance(&stock_take)
+
+	_, err = client.Database(ss.Config.Databases[0].Database).Collection("stock_takes").InsertOne(ctx, stock_take)
+	return stock_take, err
+}
+
+// RecordCounts sets the counted quantity of entries of an open stock take, counting an entry again replaces
+// its previous count.
+func (ss StockTakeService) RecordCounts(stock_take_id string, counts []models.StockTakeCount, user_id string) (stock_take models.StockTake, err error) {
+
+	stock_take, err = ss.GetStockTake(stock_take_id)
+	if err != nil {
+		return stock_take, err
+	}
+
+	if stock_take.State != models.StockTakeStateOpen {
+		return stock_take, fmt.Errorf("%w: only open stock takes can be counted", ErrInvalidStockTakeState)
+	}
+
+	if len(counts) == 0 {
+		return stock_take, fmt.Errorf("%w: at least one count is required", ErrInvalidStockTake)
+	}
+
+	indexes := make(map[string]int)
+	for index, line := range stock_take.Lines {
+		indexes[line.MaterialId+"@"+line.EntryId] = index
+	}
+
+	material_svc := MaterialService{
+		Logger: ss.Logger,
+		Config: ss.Config,
+	}
+
+	materials := make(map[string]models.Material)
+	now := time.Now()
+
+	for _, count := range counts {
+		index, ok := indexes[count.MaterialId+"@"+count.EntryId]
+		if !ok {
+			return stock_take, fmt.Errorf("%w: entry %s of material %s isn't part of the stock take", ErrInvalidStockTake, count.EntryId, count.MaterialId)
+		}
+
+		if count.Quantity < 0 {
+			return stock_take, fmt.Errorf("%w: counted quantity of entry %s can't be negative", ErrInvalidStockTake, count.EntryId)
+		}
+
+		quantity := count.Quantity
+		if count.Unit != "" {
+			material, ok := materials[count.MaterialId]
+			if !ok {
+				material, err = material_svc.GetMaterial(count.MaterialId)
+				if err != nil {
+					return stock_take, err
+				}
+				materials[count.MaterialId] = material
+			}
+
+			quantity, err = ToMaterialUnit(material, count.Quantity, count.Unit)
+			if err != nil {
+				return stock_take, err
+			}
+		}
+
+		line := &stock_take.Lines[index]
+		line.CountedQuantity = &quantity
+		line.CountedAt = now
+		line.CountedBy = user_id
+		line.Comment = count.Comment
+	}
+
+	computeVariance(&stock_take)
+
+	return stock_take, ss.save(stock_take)
+}
+
+// PostStockTake sets the quantity of every counted entry to the counted one and logs each adjustment, the
+// uncounted entries are left as they are. Entries deleted since the stock take was opened are skipped.
+func (ss StockTakeService) PostStockTake(stock_take_id string, user_id string) (stock_take models.StockTake, err error) {
+
+	stock_take, err = ss.GetStockTake(stock_take_id)
+	if err != nil {
+		return stock_take, err
+	}
+
+	if stock_take.State != models.StockTakeStateOpen {
+		return stock_take, fmt.Errorf("%w: only open stock takes can be posted", ErrInvalidStockTakeState)
+	}
+
+	computeVariance(&stock_take)
+
+	if stock_take.CountedLines == 0 {
+		return stock_take, fmt.Errorf("%w: nothing was counted", ErrInvalidStockTake)
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return stock_take, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	material_svc := MaterialService{
+		Logger: ss.Logger,
+		Config: ss.Config,
+	}
+
+	materials := make(map[string]models.Material)
+	now := time.Now()
+
+	for index := range stock_take.Lines {
+		line := &stock_take.Lines[index]
+
+		if line.CountedQuantity == nil {
+			continue
+		}
+
+		material, ok := materials[line.MaterialId]
+		if !ok {
+			material, err = material_svc.GetMaterial(line.MaterialId)
+			if err != nil {
+				ss.Logger.Error(fmt.Sprintf("stock take %s: material %s: %s", stock_take.Id, line.MaterialId, err.Error()))
+				continue
+			}
+			materials[line.MaterialId] = material
+		}
+
+		found := false
+		previous := 0.0
+		for _, entry := range material.Entries {
+			if entry.Id == line.EntryId {
+				found = true
+				previous = entry.Quantity
+				break
+			}
+		}
+
+		if !found {
+			continue
+		}
+
+		line.AdjustedQuantity = *line.CountedQuantity - previous
+		if nearlyEqual(line.AdjustedQuantity, 0) {
+			line.AdjustedQuantity = 0
+			continue
+		}
+
+		_, err = client.Database(ss.Config.Databases[0].Database).Collection("materials").UpdateOne(ctx, bson.M{"id": line.MaterialId, "entries.id": line.EntryId}, bson.M{
+			"$inc": bson.M{
+				"entries.$.quantity": line.AdjustedQuantity,
+			},
+		})
+		if err != nil {
+			return stock_take, err
+		}
+
+		log := models.LogStockTakeAdjustment{
+			Log: models.Log{
+				Type:   models.LogTypeStockTakeAdjustment,
+				Id:     primitive.NewObjectID().Hex(),
+				Date:   now,
+				UserId: user_id,
+			},
+			StockTakeId: stock_take.Id,
+			MaterialId:  line.MaterialId,
+			EntryId:     line.EntryId,
+			Previous:    previous,
+			Counted:     *line.CountedQuantity,
+			Quantity:    line.AdjustedQuantity,
+			Value:       line.AdjustedQuantity * line.UnitCost,
+		}
+
+		_, err = client.Database(ss.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, log)
+		if err != nil {
+			return stock_take, err
+		}
+	}
+
+	stock_take.State = models.StockTakeStatePosted
+	stock_take.PostedAt = now
+	stock_take.PostedBy = user_id
+
+	return stock_take, ss.save(stock_take)
+}
+
+// CancelStockTake discards an open stock take without adjusting the stock.
+func (ss StockTakeService) CancelStockTake(stock_take_id string) (stock_take models.StockTake, err error) {
+
+	stock_take, err = ss.GetStockTake(stock_take_id)
+	if err != nil {
+		return stock_take, err
+	}
+
+	if stock_take.State != models.StockTakeStateOpen {
+		return stock_take, fmt.Errorf("%w: only open stock takes can be cancelled", ErrInvalidStockTakeState)
+	}
+
+	stock_take.State = models.StockTakeStateCancelled
+
+	return stock_take, ss.save(stock_take)
+}
+
+// DeleteStockTake deletes an open or cancelled stock take, the posted ones are kept as the history of the adjustments.
+func (ss StockTakeService) DeleteStockTake(stock_take_id string) error {
+
+	stock_take, err := ss.GetStockTake(stock_take_id)
+	if err != nil {
+		return err
+	}
+
+	if stock_take.State == models.StockTakeStatePosted {
+		return fmt.Errorf("%w: posted stock takes can't be deleted", ErrInvalidStockTakeState)
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.Database(ss.Config.Databases[0].Database).Collection("stock_takes").DeleteOne(ctx, bson.M{"id": stock_take_id})
+	return err
+}
+
+// GetStockTakeReport returns the stock takes posted between from and to and the variance of each counted
+// material summed over them.
+func (ss StockTakeService) GetStockTakeReport(from time.Time, to time.Time) (report models.StockTakeReport, err error) {
+
+	report = models.StockTakeReport{
+		From:       from,
+		To:         to,
+		StockTakes: make([]models.StockTakeSummary, 0),
+		Materials:  make([]models.StockTakeMaterialVariance, 0),
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return report, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := client.Database(ss.Config.Databases[0].Database).Collection("stock_takes").Find(ctx, bson.M{
+		"state":     models.StockTakeStatePosted,
+		"posted_at": bson.M{"$gte": from, "$lt": to},
+	}, options.Find().SetSort(bson.M{"posted_at": 1}))
+	if err != nil {
+		return report, err
+	}
+	defer cursor.Close(ctx)
+
+	indexes := make(map[string]int)
+
+	for cursor.Next(ctx) {
+		var stock_take models.StockTake
+		if err := cursor.Decode(&stock_take); err != nil {
+			return report, err
+		}
+
+		report.VarianceValue += stock_take.VarianceValue
+		report.StockTakes = append(report.StockTakes, models.StockTakeSummary{
+			Id:            stock_take.Id,
+			Name:          stock_take.Name,
+			PostedAt:      stock_take.PostedAt,
+			CountedLines:  stock_take.CountedLines,
+			ExpectedValue: stock_take.ExpectedValue,
+			VarianceValue: stock_take.VarianceValue,
+		})
+
+		counted := make(map[string]bool)
+
+		for _, line := range stock_take.Lines {
+			if line.CountedQuantity == nil {
+				continue
+			}
+
+			index, ok := indexes[line.MaterialId]
+			if !ok {
+				index = len(report.Materials)
+				indexes[line.MaterialId] = index
+				report.Materials = append(report.Materials, models.StockTakeMaterialVariance{
+					MaterialId:   line.MaterialId,
+					MaterialName: line.MaterialName,
+					Unit:         line.Unit,
+				})
+			}
+
+			material := &report.Materials[index]
+			if !counted[line.MaterialId] {
+				counted[line.MaterialId] = true
+				material.StockTakes++
+			}
+
+			material.ExpectedQuantity += line.ExpectedQuantity
+			material.CountedQuantity += *line.CountedQuantity
+			material.VarianceQuantity += line.VarianceQuantity
+			material.VarianceValue += line.VarianceValue
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return report, err
+	}
+
+	sort.SliceStable(report.Materials, func(i, j int) bool {
+		return math.Abs(report.Materials[i].VarianceValue) > math.Abs(report.Materials[j].VarianceValue)
+	})
+
+	return report, nil
+}
@@ -0,0 +1,622 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidStockTransfer      = errors.New("invalid stock transfer")
+	ErrInvalidStockTransferState = errors.New("the stock transfer state doesn't allow this operation")
+)
+
+// StockTransferService moves stock between locations, the stock is in transit from its dispatch to its receipt.
+type StockTransferService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+type GetStockTransfersParams struct {
+	PageNumber int
+	PageSize   int
+	State      string
+	// LocationId only returns the transfers from or to the location when set.
+	LocationId string
+}
+
+// GetStockTransfers returns the stock transfers, the latest dispatched first.
+func (ts StockTransferService) GetStockTransfers(params GetStockTransfersParams) (transfers []models.StockTransfer, total_records int, err error) {
+
+	transfers = make([]models.StockTransfer, 0)
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return transfers, total_records, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := client.Database(ts.Config.Databases[0].Database).Collection("stock_transfers")
+
+	filter := bson.M{}
+	if params.State != "" {
+		filter["state"] = params.State
+	}
+	if params.LocationId != "" {
+		filter["$or"] = bson.A{bson.M{"from_location_id": params.LocationId}, bson.M{"to_location_id": params.LocationId}}
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((params.PageNumber - 1) * params.PageSize))
+	findOptions.SetLimit(int64(params.PageSize))
+	findOptions.SetSort(bson.M{"dispatched_at": -1})
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return transfers, total_records, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &transfers)
+	if err != nil {
+		return transfers, total_records, err
+	}
+
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return transfers, total_records, err
+	}
+	total_records = int(count)
+
+	return transfers, total_records, err
+}
+
+func (ts StockTransferService) GetStockTransfer(transfer_id string) (transfer models.StockTransfer, err error) {
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = client.Database(ts.Config.Databases[0].Database).Collection("stock_transfers").FindOne(ctx, bson.M{"id": transfer_id}).Decode(&transfer)
+	return transfer, err
+}
+
+// save replaces the stored stock transfer with the given one.
+func (ts StockTransferService) save(ctx context.Context, transfer models.StockTransfer) error {
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return err
+	}
+
+	_, err = client.Database(ts.Config.Databases[0].Database).Collection("stock_transfers").ReplaceOne(ctx, bson.M{"id": transfer.Id}, transfer)
+	return err
+}
+
+// log records the stock transfer in the logs with the given type.
+func (ts StockTransferService) log(ctx context.Context, log_type string, transfer models.StockTransfer, user_id string) error {
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return err
+	}
+
+	log := models.LogStockTransfer{
+		Log: models.Log{
+			Type:   log_type,
+			Id:     primitive.NewObjectID().Hex(),
+			Date:   time.Now(),
+			UserId: user_id,
+		},
+		Transfer: transfer,
+	}
+
+	_, err = client.Database(ts.Config.Databases[0].Database).Collection("logs").InsertOne(ctx, log)
+	return err
+}
+
+// DispatchStockTransfer takes the quantity of every line out of the entries at the source location and puts it in
+// transit to the destination one. The entries are taken in the order of the material allocation strategy, or only
+// the entry of the line when given. Nothing is taken unless every line is covered.
+func (ts StockTransferService) DispatchStockTransfer(transfer models.StockTransfer, user_id string) (models.StockTransfer, error) {
+
+	if transfer.FromLocationId == "" || transfer.ToLocationId == "" {
+		return transfer, fmt.Errorf("%w: from_location_id and to_location_id are required", ErrInvalidStockTransfer)
+	}
+
+	if transfer.FromLocationId == transfer.ToLocationId {
+		return transfer, fmt.Errorf("%w: the stock must be transferred to another location", ErrInvalidStockTransfer)
+	}
+
+	if len(transfer.Lines) == 0 {
+		return transfer, fmt.Errorf("%w: at least one line is required", ErrInvalidStockTransfer)
+	}
+
+	location_svc := LocationService{
+		Logger: ts.Logger,
+		Config: ts.Config,
+	}
+
+	for _, location_id := range []string{transfer.FromLocationId, transfer.ToLocationId} {
+		if _, err := location_svc.GetLocation(location_id); err != nil {
+			return transfer, fmt.Errorf("%w: location %s not found", ErrInvalidStockTransfer, location_id)
+		}
+	}
+
+	material_svc := MaterialService{
+		Logger:   ts.Logger,
+		Config:   ts.Config,
+		Settings: ts.Settings,
+	}
+
+	// the stock left at the source location, so that lines of the same material don't take the same stock twice
+	materials := make(map[string]models.Material)
+
+	for index := range transfer.Lines {
+		line := &transfer.Lines[index]
+
+		if line.Quantity <= 0 {
+			return transfer, fmt.Errorf("%w: quantity of line %d must be positive", ErrInvalidStockTransfer, index+1)
+		}
+
+		material, ok := materials[line.MaterialId]
+		if !ok {
+			found, err := material_svc.GetMaterial(line.MaterialId)
+			if err != nil {
+				return transfer, fmt.Errorf("%w: material %s not found", ErrInvalidStockTransfer, line.MaterialId)
+			}
+
+			material, err = material_svc.atLocation(found, transfer.FromLocationId)
+			if err != nil {
+				return transfer, err
+			}
+		}
+
+		quantity, err := ToMaterialUnit(material, line.Quantity, line.Unit)
+		if err != nil {
+			return transfer, err
+		}
+
+		strategy := material_svc.EntryAllocationStrategy(material)
+		if line.EntryId != "" {
+			strategy = models.EntryAllocationExplicit
+		}
+
+		shares, remaining := AllocateEntries(material, strategy, line.EntryId, quantity)
+		if remaining > 0 {
+			return transfer, fmt.Errorf("%w: only %f %s of %s is available at the source location, %f requested", ErrInvalidStockTransfer, quantity-remaining, material.Unit, material.Name, quantity)
+		}
+
+		for _, share := range shares {
+			for entry_index := range material.Entries {
+				if material.Entries[entry_index].Id == share.EntryId {
+					material.Entries[entry_index].Quantity -= share.Quantity
+				}
+			}
+		}
+		materials[line.MaterialId] = material
+
+		line.Id = primitive.NewObjectID().Hex()
+		line.MaterialName = material.Name
+		line.Shares = shares
+		line.ReceivedQuantity = 0
+		line.ReceivedEntryIds = make([]string, 0)
+	}
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return transfer, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, line := range transfer.Lines {
+		for _, share := range line.Shares {
+			_, err = client.Database(ts.Config.Databases[0].Database).Collection("materials").UpdateOne(ctx, bson.M{"id": line.MaterialId, "entries.id": share.EntryId}, bson.M{
+				"$inc": bson.M{
+					"entries.$.quantity": -share.Quantity,
+				},
+			})
+			if err != nil {
+				return transfer, err
+			}
+		}
+	}
+
+	transfer.Id = primitive.NewObjectID().Hex()
+	transfer.State = models.StockTransferStateDispatched
+	transfer.DispatchedAt = time.Now()
+	transfer.DispatchedBy = user_id
+	transfer.ReceivedAt = time.Time{}
+	transfer.ReceivedBy = ""
+
+	_, err = client.Database(ts.Config.Databases[0].Database).Collection("stock_transfers").InsertOne(ctx, transfer)
+	if err != nil {
+		return transfer, err
+	}
+
+	return transfer, ts.log(ctx, models.LogTypeStockTransferDispatch, transfer, user_id)
+}
+
+// sharesQuantity returns the quantity the line took from the source entries, in the material unit.
+func sharesQuantity(line models.StockTransferLine) (quantity float64) {
+	for _, share := range line.Shares {
+		quantity += share.Quantity
+	}
+
+	return quantity
+}
+
+// claim moves a dispatched transfer to the given state along with the fields in set, it fails when the transfer
+// is no longer dispatched so only one receipt or cancellation moves its stock.
+func (ts StockTransferService) claim(ctx context.Context, client *mongo.Client, transfer_id string, state string, set bson.M) error {
+
+	set["state"] = state
+
+	result, err := client.Database(ts.Config.Databases[0].Database).Collection("stock_transfers").UpdateOne(ctx, bson.M{"id": transfer_id, "state": models.StockTransferStateDispatched}, bson.M{
+		"$set": set,
+	})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("%w: the stock transfer is no longer dispatched", ErrInvalidStockTransferState)
+	}
+
+	return nil
+}
+
+// receivedShares splits the received quantity of the line over its shares, the losses are taken from the last
+// shares. It returns the quantity received from each share in the order of the shares.
+func receivedShares(line models.StockTransferLine) []float64 {
+	quantities := make([]float64, len(line.Shares))
+
+	left := line.ReceivedQuantity
+	for index, share := range line.Shares {
+		quantity := share.Quantity
+		if left < quantity {
+			quantity = left
+		}
+		if quantity < 0 || nearlyEqual(quantity, 0) {
+			quantity = 0
+		}
+		left -= quantity
+
+		quantities[index] = quantity
+	}
+
+	return quantities
+}
+
+// ReceiveStockTransfer adds the stock of a dispatched transfer to the destination location as new entries keeping
+// the unit cost, expiration date and origin of the entries it was taken from. Lines default to being fully
+// received, received lists the lines received short with their quantity in the material unit, the rest is lost
+// in transit and logged as waste. The receipt is recorded on the transfer before any stock is added, a receipt
+// that was interrupted is completed by the next call instead of receiving the given lines.
+func (ts StockTransferService) ReceiveStockTransfer(transfer_id string, received []models.StockTransferReceiptLine, user_id string) (transfer models.StockTransfer, err error) {
+
+	transfer, err = ts.GetStockTransfer(transfer_id)
+	if err != nil {
+		return transfer, err
+	}
+
+	if transfer.State == models.StockTransferStateReceiving {
+		return ts.completeReceipt(transfer)
+	}
+
+	if transfer.State != models.StockTransferStateDispatched {
+		return transfer, fmt.Errorf("%w: only dispatched stock transfers can be received", ErrInvalidStockTransferState)
+	}
+
+	indexes := make(map[string]int)
+	for index, line := range transfer.Lines {
+		indexes[line.Id] = index
+		transfer.Lines[index].ReceivedQuantity = sharesQuantity(line)
+	}
+
+	for _, receipt_line := range received {
+		index, ok := indexes[receipt_line.LineId]
+		if !ok {
+			return transfer, fmt.Errorf("%w: line %s not found", ErrInvalidStockTransfer, receipt_line.LineId)
+		}
+
+		if receipt_line.Quantity < 0 || receipt_line.Quantity > transfer.Lines[index].ReceivedQuantity && !nearlyEqual(receipt_line.Quantity, transfer.Lines[index].ReceivedQuantity) {
+			return transfer, fmt.Errorf("%w: received quantity of line %s must be between 0 and the dispatched quantity", ErrInvalidStockTransfer, receipt_line.LineId)
+		}
+
+		transfer.Lines[index].ReceivedQuantity = receipt_line.Quantity
+	}
+
+	for index := range transfer.Lines {
+		line := &transfer.Lines[index]
+		line.ReceivedEntryIds = make([]string, len(line.Shares))
+		line.LostLogIds = make([]string, len(line.Shares))
+
+		for share_index, quantity := range receivedShares(*line) {
+			if quantity > 0 {
+				line.ReceivedEntryIds[share_index] = primitive.NewObjectID().Hex()
+			}
+
+			if lost := line.Shares[share_index].Quantity - quantity; lost > 0 && !nearlyEqual(lost, 0) {
+				line.LostLogIds[share_index] = primitive.NewObjectID().Hex()
+			}
+		}
+	}
+
+	transfer.ReceivedAt = time.Now()
+	transfer.ReceivedBy = user_id
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return transfer, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = ts.claim(ctx, client, transfer_id, models.StockTransferStateReceiving, bson.M{
+		"lines":       transfer.Lines,
+		"received_at": transfer.ReceivedAt,
+		"received_by": transfer.ReceivedBy,
+	})
+	if err != nil {
+		return transfer, err
+	}
+
+	transfer.State = models.StockTransferStateReceiving
+
+	return ts.completeReceipt(transfer)
+}
+
+// completeReceipt adds the stock of a transfer being received to the destination location and logs its losses.
+// The entries and the logs have their ids already so completing it again doesn't add them twice.
+func (ts StockTransferService) completeReceipt(transfer models.StockTransfer) (models.StockTransfer, error) {
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return transfer, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	material_svc := MaterialService{
+		Logger: ts.Logger,
+		Config: ts.Config,
+	}
+
+	for _, line := range transfer.Lines {
+		material, err := material_svc.GetMaterial(line.MaterialId)
+		if err != nil {
+			return transfer, err
+		}
+
+		sources := make(map[string]models.MaterialEntry)
+		for _, entry := range material.Entries {
+			sources[entry.Id] = entry
+		}
+
+		for index, quantity := range receivedShares(line) {
+			share := line.Shares[index]
+
+			if index < len(line.LostLogIds) && line.LostLogIds[index] != "" {
+				err = ts.logLoss(ctx, client, transfer, line, share, line.LostLogIds[index], share.Quantity-quantity)
+				if err != nil {
+					return transfer, err
+				}
+			}
+
+			if index >= len(line.ReceivedEntryIds) || line.ReceivedEntryIds[index] == "" {
+				continue
+			}
+
+			// the source entry may have been deleted since, its share still carries the unit cost
+			source := sources[share.EntryId]
+			entry_id := line.ReceivedEntryIds[index]
+
+			_, err = client.Database(ts.Config.Databases[0].Database).Collection("materials").UpdateOne(ctx, bson.M{"id": line.MaterialId, "entries.id": bson.M{"$ne": entry_id}}, bson.M{
+				"$push": bson.M{"entries": bson.M{
+					"id":                entry_id,
+					"purchase_quantity": quantity,
+					"price":             quantity * share.UnitCost,
+					"quantity":          quantity,
+					"company":           source.Company,
+					"sku":               source.SKU,
+					"lot_number":        source.LotNumber,
+					"expiration_date":   source.ExpirationDate,
+					"supplier_id":       source.SupplierId,
+					"location_id":       transfer.ToLocationId,
+				}},
+			})
+			if err != nil {
+				return transfer, err
+			}
+		}
+	}
+
+	transfer.State = models.StockTransferStateReceived
+
+	err = ts.save(ctx, transfer)
+	if err != nil {
+		return transfer, err
+	}
+
+	return transfer, ts.log(ctx, models.LogTypeStockTransferReceive, transfer, transfer.ReceivedBy)
+}
+
+// logLoss logs the quantity of a share lost in transit as waste at the destination location under log_id, logging
+// it again replaces the log. The stock already left the source entry on dispatch so no entry is decremented.
+func (ts StockTransferService) logLoss(ctx context.Context, client *mongo.Client, transfer models.StockTransfer, line models.StockTransferLine, share models.MaterialEntryShare, log_id string, quantity float64) error {
+
+	log_waste := models.LogWasteMaterial{
+		Log: models.Log{
+			Type:   models.LogTypeMaterialWaste,
+			Date:   transfer.ReceivedAt,
+			Id:     log_id,
+			UserId: transfer.ReceivedBy,
+		},
+		MaterialId: line.MaterialId,
+		EntryId:    share.EntryId,
+		Quantity:   quantity,
+		Reason:     fmt.Sprintf("lost in transit, stock transfer %s", transfer.Id),
+		Value:      quantity * share.UnitCost,
+		LocationId: transfer.ToLocationId,
+	}
+
+	_, err := client.Database(ts.Config.Databases[0].Database).Collection("logs").ReplaceOne(ctx, bson.M{"id": log_id}, log_waste, options.Replace().SetUpsert(true))
+	return err
+}
+
+// CancelStockTransfer returns the stock of a dispatched transfer to the entries it was taken from. The entries
+// deleted since are skipped. A cancellation that was interrupted is completed by the next call.
+func (ts StockTransferService) CancelStockTransfer(transfer_id string, user_id string) (transfer models.StockTransfer, err error) {
+
+	transfer, err = ts.GetStockTransfer(transfer_id)
+	if err != nil {
+		return transfer, err
+	}
+
+	if transfer.State == models.StockTransferStateCancelling {
+		return ts.completeCancel(transfer, user_id)
+	}
+
+	if transfer.State != models.StockTransferStateDispatched {
+		return transfer, fmt.Errorf("%w: only dispatched stock transfers can be cancelled", ErrInvalidStockTransferState)
+	}
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return transfer, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = ts.claim(ctx, client, transfer_id, models.StockTransferStateCancelling, bson.M{})
+	if err != nil {
+		return transfer, err
+	}
+
+	transfer.State = models.StockTransferStateCancelling
+
+	return ts.completeCancel(transfer, user_id)
+}
+
+// completeCancel returns the shares of a transfer being cancelled to their entries. Each entry records the
+// transfer lines returned to it in the same update so completing it again doesn't return them twice.
+func (ts StockTransferService) completeCancel(transfer models.StockTransfer, user_id string) (models.StockTransfer, error) {
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return transfer, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	for _, line := range transfer.Lines {
+		returned := transfer.Id + "/" + line.Id
+
+		for _, share := range line.Shares {
+			_, err = client.Database(ts.Config.Databases[0].Database).Collection("materials").UpdateOne(ctx, bson.M{
+				"id":      line.MaterialId,
+				"entries": bson.M{"$elemMatch": bson.M{"id": share.EntryId, "returned_transfer_lines": bson.M{"$ne": returned}}},
+			}, bson.M{
+				"$inc":      bson.M{"entries.$.quantity": share.Quantity},
+				"$addToSet": bson.M{"entries.$.returned_transfer_lines": returned},
+			})
+			if err != nil {
+				return transfer, err
+			}
+		}
+	}
+
+	transfer.State = models.StockTransferStateCancelled
+
+	err = ts.save(ctx, transfer)
+	if err != nil {
+		return transfer, err
+	}
+
+	return transfer, ts.log(ctx, models.LogTypeStockTransferCancel, transfer, user_id)
+}
+
+// GetInTransit returns the quantity of each material dispatched and not received yet, per source and destination.
+// The transfers being received or cancelled are still counted until their stock is settled.
+func (ts StockTransferService) GetInTransit() ([]models.InTransitQuantity, error) {
+	in_transit := make([]models.InTransitQuantity, 0)
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return in_transit, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := client.Database(ts.Config.Databases[0].Database).Collection("stock_transfers").Find(ctx, bson.M{"state": bson.M{"$in": bson.A{models.StockTransferStateDispatched, models.StockTransferStateReceiving, models.StockTransferStateCancelling}}})
+	if err != nil {
+		return in_transit, err
+	}
+	defer cursor.Close(ctx)
+
+	transfers := make([]models.StockTransfer, 0)
+	if err := cursor.All(ctx, &transfers); err != nil {
+		return in_transit, err
+	}
+
+	indexes := make(map[string]int)
+
+	for _, transfer := range transfers {
+		counted := make(map[int]bool)
+
+		for _, line := range transfer.Lines {
+			key := fmt.Sprintf("%s@%s>%s", line.MaterialId, transfer.FromLocationId, transfer.ToLocationId)
+
+			index, ok := indexes[key]
+			if !ok {
+				index = len(in_transit)
+				indexes[key] = index
+				in_transit = append(in_transit, models.InTransitQuantity{
+					MaterialId:     line.MaterialId,
+					MaterialName:   line.MaterialName,
+					FromLocationId: transfer.FromLocationId,
+					ToLocationId:   transfer.ToLocationId,
+				})
+			}
+
+			in_transit[index].Quantity += sharesQuantity(line)
+
+			if !counted[index] {
+				counted[index] = true
+				in_transit[index].Transfers++
+			}
+		}
+	}
+
+	sort.SliceStable(in_transit, func(i, j int) bool {
+		return in_transit[i].MaterialName < in_transit[j].MaterialName
+	})
+
+	return in_transit, nil
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidSupplier = errors.New("invalid supplier")
+
+type SupplierService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+type GetSuppliersParams struct {
+	PageNumber int
+	PageSize   int
+	// MaterialId only returns the suppliers selling the material when set.
+	MaterialId string
+}
+
+func (ss SupplierService) GetSuppliers(params GetSuppliersParams) (suppliers []models.Supplier, suppliers_count int, err error) {
+
+	suppliers = make([]models.Supplier, 0)
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return suppliers, suppliers_count, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := client.Database(ss.Config.Databases[0].Database).Collection("suppliers")
+
+	filter := bson.M{}
+	if params.MaterialId != "" {
+		filter["materials.material_id"] = params.MaterialId
+	}
+
+	findOptions := options.Find()
+	findOptions.SetSkip(int64((params.PageNumber - 1) * params.PageSize))
+	findOptions.SetLimit(int64(params.PageSize))
+	findOptions.SetSort(bson.M{"name": 1})
+
+	cursor, err := collection.Find(ctx, filter, findOptions)
+	if err != nil {
+		return suppliers, suppliers_count, err
+	}
+	defer cursor.Close(ctx)
+
+	err = cursor.All(ctx, &suppliers)
+	if err != nil {
+		return suppliers, suppliers_count, err
+	}
+
+	count, err := collection.CountDocuments(ctx, filter)
+	if err != nil {
+		return suppliers, suppliers_count, err
+	}
+	suppliers_count = int(count)
+
+	return suppliers, suppliers_count, err
+}
+
+func (ss SupplierService) GetSupplier(supplier_id string) (supplier models.Supplier, err error) {
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = client.Database(ss.Config.Databases[0].Database).Collection("suppliers").FindOne(ctx, bson.M{"id": supplier_id}).Decode(&supplier)
+	return supplier, err
+}
+
+// validate checks the supplier has a name and that the materials it sells exist and are priced in a unit
+// convertible to theirs.
+func (ss SupplierService) validate(supplier models.Supplier) error {
+	if supplier.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidSupplier)
+	}
+
+	if supplier.LeadTimeDays < 0 {
+		return fmt.Errorf("%w: lead_time_days can't be negative", ErrInvalidSupplier)
+	}
+
+	material_svc := MaterialService{
+		Logger: ss.Logger,
+		Config: ss.Config,
+	}
+
+	for _, supplier_material := range supplier.Materials {
+		material, err := material_svc.GetMaterial(supplier_material.MaterialId)
+		if err != nil {
+			return fmt.Errorf("%w: material %s: %s", ErrInvalidSupplier, supplier_material.MaterialId, err.Error())
+		}
+
+		if _, err := ToMaterialUnit(material, 1, supplier_material.Unit); err != nil {
+			return fmt.Errorf("%w: material %s: %s", ErrInvalidSupplier, material.Name, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func (ss SupplierService) InsertSupplier(supplier models.Supplier) (afterInsert models.Supplier, err error) {
+
+	err = ss.validate(supplier)
+	if err != nil {
+		return afterInsert, err
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	supplier.Id = primitive.NewObjectID().Hex()
+
+	if supplier.Contacts == nil {
+		supplier.Contacts = make([]models.SupplierContact, 0)
+	}
+
+	if supplier.Materials == nil {
+		supplier.Materials = make([]models.SupplierMaterial, 0)
+	}
+
+	_, err = client.Database(ss.Config.Databases[0].Database).Collection("suppliers").InsertOne(ctx, supplier)
+	if err != nil {
+		return afterInsert, err
+	}
+
+	return supplier, nil
+}
+
+// UpdateSupplier replaces the details of the supplier with the given ones.
+func (ss SupplierService) UpdateSupplier(supplier models.Supplier, supplier_id string) (afterUpdate models.Supplier, err error) {
+
+	err = ss.validate(supplier)
+	if err != nil {
+		return afterUpdate, err
+	}
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	collection := client.Database(ss.Config.Databases[0].Database).Collection("suppliers")
+
+	supplier.Id = supplier_id
+
+	result, err := collection.ReplaceOne(ctx, bson.M{"id": supplier_id}, supplier)
+	if err != nil {
+		return afterUpdate, err
+	}
+
+	if result.MatchedCount == 0 {
+		return afterUpdate, fmt.Errorf("supplier %s not found", supplier_id)
+	}
+
+	return supplier, nil
+}
+
+func (ss SupplierService) DeleteSupplier(supplier_id string) (err error) {
+
+	client, err := common.GetDatabaseClient(ss.Logger, &ss.Config)
+	if err != nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = client.Database(ss.Config.Databases[0].Database).Collection("suppliers").DeleteOne(ctx, bson.M{"id": supplier_id})
+	return err
+}
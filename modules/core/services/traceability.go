@@ -0,0 +1,460 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidTraceQuery = errors.New("invalid trace query")
+
+// TraceabilityService traces the material lots forward to the orders, products and customers that used them.
+type TraceabilityService struct {
+	Logger   logger.ILogger
+	Config   config.Config
+	Settings models.Settings
+}
+
+// matchesTrace tells whether the entry is selected by the query.
+func matchesTrace(entry models.MaterialEntry, query models.TraceQuery) bool {
+	return (query.EntryId != "" && entry.Id == query.EntryId) ||
+		(query.SKU != "" && strings.EqualFold(entry.SKU, query.SKU)) ||
+		(query.LotNumber != "" && strings.EqualFold(entry.LotNumber, query.LotNumber))
+}
+
+// tracedEntry returns the trace of an entry of the material.
+func tracedEntry(material models.Material, entry models.MaterialEntry, source_entry_id string) models.TracedEntry {
+	return models.TracedEntry{
+		MaterialId:     material.Id,
+		MaterialName:   material.Name,
+		Unit:           material.Unit,
+		EntryId:        entry.Id,
+		SKU:            entry.SKU,
+		LotNumber:      entry.LotNumber,
+		Company:        entry.Company,
+		SupplierId:     entry.SupplierId,
+		LocationId:     entry.LocationId,
+		ExpirationDate: entry.ExpirationDate,
+		Quantity:       entry.Quantity,
+		SourceEntryId:  source_entry_id,
+	}
+}
+
+// tracedEntries returns the entries selected by the query and those transferred from them to other locations.
+func (ts TraceabilityService) tracedEntries(ctx context.Context, db *mongo.Database, query models.TraceQuery) ([]models.TracedEntry, error) {
+	entries := make([]models.TracedEntry, 0)
+
+	conditions := bson.A{}
+	if query.EntryId != "" {
+		conditions = append(conditions, bson.M{"entries.id": query.EntryId})
+	}
+	// the sku and lot number are matched case insensitively, the entries are matched one by one below
+	if query.SKU != "" {
+		conditions = append(conditions, bson.M{"entries.sku": bson.M{"$regex": "^" + regexp.QuoteMeta(query.SKU) + "$", "$options": "i"}})
+	}
+	if query.LotNumber != "" {
+		conditions = append(conditions, bson.M{"entries.lot_number": bson.M{"$regex": "^" + regexp.QuoteMeta(query.LotNumber) + "$", "$options": "i"}})
+	}
+
+	cursor, err := db.Collection("materials").Find(ctx, bson.M{"$or": conditions})
+	if err != nil {
+		return entries, err
+	}
+
+	materials := make([]models.Material, 0)
+	if err := cursor.All(ctx, &materials); err != nil {
+		return entries, err
+	}
+
+	traced := make(map[string]bool)
+	frontier := make([]string, 0)
+
+	for _, material := range materials {
+		for _, entry := range material.Entries {
+			if matchesTrace(entry, query) {
+				entries = append(entries, tracedEntry(material, entry, ""))
+				traced[entry.Id] = true
+				frontier = append(frontier, entry.Id)
+			}
+		}
+	}
+
+	// the stock received from a transfer is new entries at the destination, they hold the same lot
+	for len(frontier) > 0 {
+		cursor, err := db.Collection("stock_transfers").Find(ctx, bson.M{
+			"state":                 models.StockTransferStateReceived,
+			"lines.shares.entry_id": bson.M{"$in": frontier},
+		})
+		if err != nil {
+			return entries, err
+		}
+
+		transfers := make([]models.StockTransfer, 0)
+		if err := cursor.All(ctx, &transfers); err != nil {
+			return entries, err
+		}
+
+		in_frontier := make(map[string]bool)
+		for _, entry_id := range frontier {
+			in_frontier[entry_id] = true
+		}
+
+		sources := make(map[string]string)
+		received_ids := make([]string, 0)
+
+		for _, transfer := range transfers {
+			for _, line := range transfer.Lines {
+				// the received entries follow the order of the shares they were received from
+				for index, share := range line.Shares {
+					if index >= len(line.ReceivedEntryIds) || !in_frontier[share.EntryId] || traced[line.ReceivedEntryIds[index]] {
+						continue
+					}
+
+					traced[line.ReceivedEntryIds[index]] = true
+					sources[line.ReceivedEntryIds[index]] = share.EntryId
+					received_ids = append(received_ids, line.ReceivedEntryIds[index])
+				}
+			}
+		}
+
+		frontier = received_ids
+		if len(frontier) == 0 {
+			break
+		}
+
+		cursor, err = db.Collection("materials").Find(ctx, bson.M{"entries.id": bson.M{"$in": frontier}})
+		if err != nil {
+			return entries, err
+		}
+
+		materials := make([]models.Material, 0)
+		if err := cursor.All(ctx, &materials); err != nil {
+			return entries, err
+		}
+
+		for _, material := range materials {
+			for _, entry := range material.Entries {
+				if source_entry_id, ok := sources[entry.Id]; ok {
+					entries = append(entries, tracedEntry(material, entry, source_entry_id))
+				}
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// productNames collects the name of every product and sub-product of the items.
+func productNames(items []models.OrderItem, names map[string]string) {
+	for _, item := range items {
+		if item.Product.Id != "" && item.Product.Name != "" {
+			names[item.Product.Id] = item.Product.Name
+		}
+
+		productNames(item.SubItems, names)
+	}
+}
+
+// TraceLot lists every order, product and customer that used the material entries selected by the query, and the
+// orders served afterwards from the ready stock of the products those orders returned to it.
+func (ts TraceabilityService) TraceLot(query models.TraceQuery) (report models.TraceReport, err error) {
+
+	query.EntryId = strings.TrimSpace(query.EntryId)
+	query.SKU = strings.TrimSpace(query.SKU)
+	query.LotNumber = strings.TrimSpace(query.LotNumber)
+
+	report = models.TraceReport{
+		Date:      time.Now(),
+		Query:     query,
+		Entries:   make([]models.TracedEntry, 0),
+		Orders:    make([]models.TracedOrder, 0),
+		Products:  make([]models.TracedProduct, 0),
+		Customers: make([]models.TracedCustomer, 0),
+	}
+
+	if query.EntryId == "" && query.SKU == "" && query.LotNumber == "" {
+		return report, fmt.Errorf("%w: an entry id, sku or lot number is required", ErrInvalidTraceQuery)
+	}
+
+	client, err := common.GetDatabaseClient(ts.Logger, &ts.Config)
+	if err != nil {
+		return report, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	db := client.Database(ts.Config.Databases[0].Database)
+
+	report.Entries, err = ts.tracedEntries(ctx, db, query)
+	if err != nil {
+		return report, err
+	}
+
+	if len(report.Entries) == 0 {
+		return report, nil
+	}
+
+	entry_ids := make([]string, 0, len(report.Entries))
+	for _, entry := range report.Entries {
+		entry_ids = append(entry_ids, entry.EntryId)
+	}
+
+	orders := make(map[string]*models.TracedOrder)
+	order_ids := make([]string, 0)
+	products := make(map[string]*models.TracedProduct)
+	product_ids := make([]string, 0)
+	product_orders := make(map[string]map[string]bool)
+
+	tally := func(product_id string, order_id string, quantity float64, from_ready bool) {
+		product, ok := products[product_id]
+		if !ok {
+			product = &models.TracedProduct{ProductId: product_id}
+			products[product_id] = product
+			product_ids = append(product_ids, product_id)
+			product_orders[product_id] = make(map[string]bool)
+		}
+
+		product.Quantity += quantity
+		product.FromReady = product.FromReady || from_ready
+		if !product_orders[product_id][order_id] {
+			product_orders[product_id][order_id] = true
+			product.Orders++
+		}
+	}
+
+	order_products := make(map[string][]string)
+
+	cursor, err := db.Collection("logs").Find(ctx, bson.M{
+		"type":     models.LogTypeMaterialConsume,
+		"entry_id": bson.M{"$in": entry_ids},
+		"order_id": bson.M{"$nin": bson.A{nil, ""}},
+	})
+	if err != nil {
+		return report, err
+	}
+
+	for cursor.Next(ctx) {
+		var log struct {
+			EntryId   string  `bson:"entry_id"`
+			OrderId   string  `bson:"order_id"`
+			ProductId string  `bson:"recipe_id"`
+			Quantity  float64 `bson:"quantity"`
+		}
+
+		if err := cursor.Decode(&log); err != nil {
+			cursor.Close(ctx)
+			return report, err
+		}
+
+		order, ok := orders[log.OrderId]
+		if !ok {
+			order = &models.TracedOrder{
+				OrderId:  log.OrderId,
+				Via:      models.TraceViaMaterial,
+				EntryIds: make([]string, 0),
+			}
+			orders[log.OrderId] = order
+			order_ids = append(order_ids, log.OrderId)
+		}
+
+		order.Quantity += log.Quantity
+		order.EntryIds = appendUnique(order.EntryIds, log.EntryId)
+
+		if log.ProductId != "" {
+			tally(log.ProductId, log.OrderId, log.Quantity, false)
+			order_products[log.OrderId] = appendUnique(order_products[log.OrderId], log.ProductId)
+		}
+	}
+	cursor.Close(ctx)
+
+	// the products of a refunded order may go back to the ready stock, the orders served from it afterwards
+	// used the lot as well
+	frontier := order_ids
+	for len(frontier) > 0 {
+		cursor, err := db.Collection("logs").Find(ctx, bson.M{
+			"type":       models.LogTypeProductIncrease,
+			"order_id":   bson.M{"$in": frontier},
+			"product_id": bson.M{"$nin": bson.A{nil, ""}},
+		})
+		if err != nil {
+			return report, err
+		}
+
+		increases := make([]models.LogProductIncrease, 0)
+		if err := cursor.All(ctx, &increases); err != nil {
+			return report, err
+		}
+
+		frontier = make([]string, 0)
+
+		for _, increase := range increases {
+			cursor, err := db.Collection("logs").Find(ctx, bson.M{
+				"type":       models.LogTypeProductConsume,
+				"product_id": increase.ProductId,
+				"date":       bson.M{"$gte": increase.Date},
+			}, options.Find().SetSort(bson.M{"date": 1}))
+			if err != nil {
+				return report, err
+			}
+
+			consumptions := make([]models.LogProductConsume, 0)
+			if err := cursor.All(ctx, &consumptions); err != nil {
+				return report, err
+			}
+
+			// the increased quantity is served by the next consumptions of the product, the later ones are
+			// served from other stock
+			left := increase.Quantity
+			for _, consumption := range consumptions {
+				if left <= 0 || nearlyEqual(left, 0) {
+					break
+				}
+				left -= consumption.Quantity
+
+				tally(consumption.ProductId, consumption.OrderId, 0, true)
+
+				if _, ok := orders[consumption.OrderId]; ok {
+					continue
+				}
+
+				orders[consumption.OrderId] = &models.TracedOrder{
+					OrderId:      consumption.OrderId,
+					Via:          models.TraceViaReadyProduct,
+					ViaProductId: consumption.ProductId,
+					EntryIds:     make([]string, 0),
+				}
+				order_ids = append(order_ids, consumption.OrderId)
+				order_products[consumption.OrderId] = []string{consumption.ProductId}
+				frontier = append(frontier, consumption.OrderId)
+			}
+		}
+	}
+
+	names := make(map[string]string)
+
+	cursor, err = db.Collection("orders").Find(ctx, bson.M{"id": bson.M{"$in": order_ids}})
+	if err != nil {
+		return report, err
+	}
+
+	stored_orders := make([]models.Order, 0)
+	if err := cursor.All(ctx, &stored_orders); err != nil {
+		return report, err
+	}
+
+	for _, stored := range stored_orders {
+		order := orders[stored.Id]
+		order.DisplayId = stored.DisplayId
+		order.SubmittedAt = stored.SubmittedAt
+		order.State = stored.State
+		order.Customer = stored.Customer
+		order.LocationId = stored.LocationId
+
+		productNames(stored.Items, names)
+	}
+
+	// the products no order names anymore are looked up in the recipes
+	missing := make([]string, 0)
+	for _, product_id := range product_ids {
+		if _, ok := names[product_id]; !ok {
+			missing = append(missing, product_id)
+		}
+	}
+
+	if len(missing) > 0 {
+		cursor, err = db.Collection("recipes").Find(ctx, bson.M{"id": bson.M{"$in": missing}})
+		if err != nil {
+			return report, err
+		}
+
+		recipes := make([]models.Product, 0)
+		if err := cursor.All(ctx, &recipes); err != nil {
+			return report, err
+		}
+
+		for _, recipe := range recipes {
+			names[recipe.Id] = recipe.Name
+		}
+	}
+
+	customers := make(map[string]int)
+
+	for _, order_id := range order_ids {
+		order := orders[order_id]
+
+		order.Products = make([]string, 0)
+		for _, product_id := range order_products[order_id] {
+			name := names[product_id]
+			if name == "" {
+				name = product_id
+			}
+			order.Products = append(order.Products, name)
+		}
+
+		report.Orders = append(report.Orders, *order)
+
+		// customers are told apart by id, else phone, else name, anonymous orders have no customer to recall
+		key := order.Customer.Id
+		if key == "" {
+			key = order.Customer.Phone
+		}
+		if key == "" {
+			key = strings.ToLower(strings.TrimSpace(order.Customer.Name))
+		}
+		if key == "" {
+			continue
+		}
+
+		index, ok := customers[key]
+		if !ok {
+			index = len(report.Customers)
+			customers[key] = index
+			report.Customers = append(report.Customers, models.TracedCustomer{
+				Customer: order.Customer,
+				OrderIds: make([]string, 0),
+			})
+		}
+
+		report.Customers[index].OrderIds = append(report.Customers[index].OrderIds, order_id)
+	}
+
+	for _, product_id := range product_ids {
+		product := products[product_id]
+		product.ProductName = names[product_id]
+		report.Products = append(report.Products, *product)
+	}
+
+	sort.SliceStable(report.Orders, func(i, j int) bool {
+		return report.Orders[i].SubmittedAt.Before(report.Orders[j].SubmittedAt)
+	})
+
+	sort.SliceStable(report.Products, func(i, j int) bool {
+		return report.Products[i].Orders > report.Products[j].Orders
+	})
+
+	return report, nil
+}
+
+// appendUnique appends value to values unless it is already there.
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+
+	return append(values, value)
+}
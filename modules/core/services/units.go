@@ -0,0 +1,125 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/nutrixpos/pos/modules/core/models"
+)
+
+var ErrIncompatibleUnits = errors.New("units are not convertible")
+
+// Units is the registry of the units of measure, quantities convert between units of the same dimension.
+var Units = []models.Unit{
+	{Symbol: "mg", Name: "milligram", Dimension: models.UnitDimensionMass, Factor: 0.001, Aliases: []string{"milligrams"}},
+	{Symbol: "g", Name: "gram", Dimension: models.UnitDimensionMass, Factor: 1, Aliases: []string{"gm", "gr", "grams"}},
+	{Symbol: "kg", Name: "kilogram", Dimension: models.UnitDimensionMass, Factor: 1000, Aliases: []string{"kilo", "kilos", "kilograms"}},
+	{Symbol: "oz", Name: "ounce", Dimension: models.UnitDimensionMass, Factor: 28.349523125, Aliases: []string{"ounces"}},
+	{Symbol: "lb", Name: "pound", Dimension: models.UnitDimensionMass, Factor: 453.59237, Aliases: []string{"lbs", "pounds"}},
+	{Symbol: "ml", Name: "millilitre", Dimension: models.UnitDimensionVolume, Factor: 1, Aliases: []string{"milliliter", "millilitres", "milliliters"}},
+	{Symbol: "cl", Name: "centilitre", Dimension: models.UnitDimensionVolume, Factor: 10, Aliases: []string{"centiliter"}},
+	{Symbol: "dl", Name: "decilitre", Dimension: models.UnitDimensionVolume, Factor: 100, Aliases: []string{"deciliter"}},
+	{Symbol: "l", Name: "litre", Dimension: models.UnitDimensionVolume, Factor: 1000, Aliases: []string{"lt", "liter", "litres", "liters"}},
+	{Symbol: "tsp", Name: "teaspoon", Dimension: models.UnitDimensionVolume, Factor: 4.92892159375, Aliases: []string{"teaspoons"}},
+	{Symbol: "tbsp", Name: "tablespoon", Dimension: models.UnitDimensionVolume, Factor: 14.78676478125, Aliases: []string{"tablespoons"}},
+	{Symbol: "fl oz", Name: "fluid ounce", Dimension: models.UnitDimensionVolume, Factor: 29.5735295625, Aliases: []string{"floz", "fluid ounces"}},
+	{Symbol: "cup", Name: "cup", Dimension: models.UnitDimensionVolume, Factor: 236.5882365, Aliases: []string{"cups"}},
+	{Symbol: "gal", Name: "gallon", Dimension: models.UnitDimensionVolume, Factor: 3785.411784, Aliases: []string{"gallons"}},
+	{Symbol: "pcs", Name: "piece", Dimension: models.UnitDimensionCount, Factor: 1, Aliases: []string{"pc", "piece", "pieces", "unit", "units", "ea", "each"}},
+	{Symbol: "dozen", Name: "dozen", Dimension: models.UnitDimensionCount, Factor: 12, Aliases: []string{"dz", "doz"}},
+}
+
+// FindUnit looks a unit up by its symbol, name or alias, case insensitively.
+func FindUnit(unit string) (models.Unit, bool) {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+
+	for _, registered := range Units {
+		if registered.Symbol == unit || registered.Name == unit {
+			return registered, true
+		}
+
+		for _, alias := range registered.Aliases {
+			if alias == unit {
+				return registered, true
+			}
+		}
+	}
+
+	return models.Unit{}, false
+}
+
+// sameUnit tells whether two unit strings name the same unit, units missing from the registry are compared as text.
+func sameUnit(a string, b string) bool {
+	if strings.EqualFold(strings.TrimSpace(a), strings.TrimSpace(b)) {
+		return true
+	}
+
+	unit_a, found_a := FindUnit(a)
+	unit_b, found_b := FindUnit(b)
+
+	return found_a && found_b && unit_a.Symbol == unit_b.Symbol
+}
+
+// ConvertQuantity converts a quantity from a unit to another of the same dimension. A quantity without a unit, or
+// to convert to no unit, is returned as is, which keeps the materials and recipes entered before the units registry working.
+func ConvertQuantity(quantity float64, from string, to string) (float64, error) {
+	if strings.TrimSpace(from) == "" || strings.TrimSpace(to) == "" || sameUnit(from, to) {
+		return quantity, nil
+	}
+
+	from_unit, found_from := FindUnit(from)
+	to_unit, found_to := FindUnit(to)
+
+	if !found_from || !found_to || from_unit.Dimension != to_unit.Dimension {
+		return 0, fmt.Errorf("%w: %s to %s", ErrIncompatibleUnits, from, to)
+	}
+
+	return quantity * from_unit.Factor / to_unit.Factor, nil
+}
+
+// ToMaterialUnit converts a quantity given in unit, a registered unit or one of the material pack sizes,
+// to the unit the material is kept in.
+func ToMaterialUnit(material models.Material, quantity float64, unit string) (float64, error) {
+	for _, pack := range material.PackSizes {
+		if strings.EqualFold(strings.TrimSpace(pack.Name), strings.TrimSpace(unit)) {
+			return ConvertQuantity(quantity*pack.Quantity, pack.Unit, material.Unit)
+		}
+	}
+
+	return ConvertQuantity(quantity, unit, material.Unit)
+}
+
+// ValidateMaterialUnits checks that the pack sizes of the material convert to its unit.
+func ValidateMaterialUnits(material models.Material) error {
+	for _, pack := range material.PackSizes {
+		if strings.TrimSpace(pack.Name) == "" || pack.Quantity <= 0 {
+			return fmt.Errorf("pack size %q must have a name and a positive quantity", pack.Name)
+		}
+
+		if _, found := FindUnit(pack.Name); found {
+			return fmt.Errorf("pack size %q is already a registered unit", pack.Name)
+		}
+
+		if _, err := ConvertQuantity(pack.Quantity, pack.Unit, material.Unit); err != nil {
+			return fmt.Errorf("pack size %q: %w", pack.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// MaterialQuantity converts a quantity of the material given in unit, e.g. a recipe quantity, to the unit the
+// material is kept in.
+func (ms *MaterialService) MaterialQuantity(material_id string, quantity float64, unit string) (float64, error) {
+	if strings.TrimSpace(unit) == "" {
+		return quantity, nil
+	}
+
+	material, err := ms.GetMaterial(material_id)
+	if err != nil {
+		return 0, err
+	}
+
+	return ToMaterialUnit(material, quantity, unit)
+}
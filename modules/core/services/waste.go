@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nutrixpos/pos/common"
+	"github.com/nutrixpos/pos/common/config"
+	"github.com/nutrixpos/pos/common/logger"
+	"github.com/nutrixpos/pos/modules/core/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// WasteService reads the waste and disposal logs for reporting.
+type WasteService struct {
+	Logger logger.ILogger
+	Config config.Config
+}
+
+// materialCosts holds the name and the unit costs of a material entries, Average is used for deleted entries.
+type materialCosts struct {
+	Name    string
+	Entries map[string]float64
+	Average float64
+}
+
+func (ws *WasteService) materialCosts(ctx context.Context) (map[string]materialCosts, error) {
+	costs := make(map[string]materialCosts)
+
+	client, err := common.GetDatabaseClient(ws.Logger, &ws.Config)
+	if err != nil {
+		return costs, err
+	}
+
+	cursor, err := client.Database(ws.Config.Databases[0].Database).Collection("materials").Find(ctx, bson.M{})
+	if err != nil {
+		return costs, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var material models.Material
+		if err := cursor.Decode(&material); err != nil {
+			return costs, err
+		}
+
+		material_costs := materialCosts{
+			Name:    material.Name,
+			Entries: make(map[string]float64),
+		}
+
+		for _, entry := range material.Entries {
+			if entry.PurchaseQuantity == 0 {
+				continue
+			}
+
+			material_costs.Entries[entry.Id] = entry.PurchasePrice / entry.PurchaseQuantity
+			material_costs.Average += material_costs.Entries[entry.Id]
+		}
+
+		if len(material_costs.Entries) > 0 {
+			material_costs.Average /= float64(len(material_costs.Entries))
+		}
+
+		costs[material.Id] = material_costs
+	}
+
+	return costs, cursor.Err()
+}
+
+// itemUnitCost returns the cost of one unit of the order item.
+func itemUnitCost(item models.OrderItem) float64 {
+	if item.Quantity == 0 {
+		return 0
+	}
+
+	return item.Cost / item.Quantity
+}
+
+// GetWasteRecords returns the waste and disposal logs between from and to, oldest first. Logs written before
+// their value was recorded are valued at the current cost of their material entry.
+func (ws *WasteService) GetWasteRecords(from time.Time, to time.Time) ([]models.WasteRecord, error) {
+	records := make([]models.WasteRecord, 0)
+
+	client, err := common.GetDatabaseClient(ws.Logger, &ws.Config)
+	if err != nil {
+		return records, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	material_costs, err := ws.materialCosts(ctx)
+	if err != nil {
+		return records, err
+	}
+
+	materialValue := func(material_id string, entry_id string, quantity float64) float64 {
+		costs := material_costs[material_id]
+		if unit_cost, ok := costs.Entries[entry_id]; ok {
+			return quantity * unit_cost
+		}
+
+		return quantity * costs.Average
+	}
+
+	filter := bson.M{
+		"type": bson.M{"$in": bson.A{models.LogTypeMaterialWaste, models.LogTypeProductWaste, models.LogTypeOrderItemWaste, models.LogTypeDisposalAdd}},
+		"date": bson.M{"$gte": from, "$lt": to},
+	}
+
+	cursor, err := client.Database(ws.Config.Databases[0].Database).Collection("logs").Find(ctx, filter, options.Find().SetSort(bson.M{"date": 1}))
+	if err != nil {
+		return records, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		log_type, _ := cursor.Current.Lookup("type").StringValueOK()
+
+		switch log_type {
+		case models.LogTypeMaterialWaste:
+			var log models.LogWasteMaterial
+			if err := cursor.Decode(&log); err != nil {
+				return records, err
+			}
+
+			value := log.Value
+			if value == 0 {
+				value = materialValue(log.MaterialId, log.EntryId, log.Quantity)
+			}
+
+			records = append(records, models.WasteRecord{
+				Date:       log.Date,
+				Source:     models.WasteSourceMaterialWaste,
+				MaterialId: log.MaterialId,
+				EntryId:    log.EntryId,
+				Name:       material_costs[log.MaterialId].Name,
+				OrderId:    log.OrderId,
+				Reason:     log.Reason,
+				UserId:     log.UserId,
+				Quantity:   log.Quantity,
+				Value:      value,
+			})
+		case models.LogTypeProductWaste:
+			var log models.LogProductWaste
+			if err := cursor.Decode(&log); err != nil {
+				return records, err
+			}
+
+			records = append(records, models.WasteRecord{
+				Date:      log.Date,
+				Source:    models.WasteSourceProductWaste,
+				ProductId: log.ProductId,
+				Name:      log.Item.Product.Name,
+				OrderId:   log.OrderId,
+				Reason:    log.Reason,
+				UserId:    log.UserId,
+				Quantity:  log.Quantity,
+				Value:     log.Quantity * itemUnitCost(log.Item),
+			})
+		case models.LogTypeOrderItemWaste:
+			var log models.LogWasteOrderItem
+			if err := cursor.Decode(&log); err != nil {
+				return records, err
+			}
+
+			records = append(records, models.WasteRecord{
+				Date:      log.Date,
+				Source:    models.WasteSourceOrderItemWaste,
+				ProductId: log.Item.Product.Id,
+				Name:      log.Item.Product.Name,
+				OrderId:   log.OrderId,
+				Reason:    log.Reason,
+				UserId:    log.UserId,
+				Quantity:  log.Quantity,
+				Value:     log.Quantity * itemUnitCost(log.Item),
+			})
+		case models.LogTypeDisposalAdd:
+			var material_log models.LogDisposalMaterialAdd
+			if err := cursor.Decode(&material_log); err != nil {
+				return records, err
+			}
+
+			if material_log.Disposal.Type == models.TypeDisposalMaterial {
+				value := material_log.Value
+				if value == 0 {
+					value = materialValue(material_log.Disposal.MaterialId, material_log.Disposal.EntryId, material_log.Disposal.Quantity)
+				}
+
+				records = append(records, models.WasteRecord{
+					Date:       material_log.Date,
+					Source:     models.WasteSourceMaterialDisposal,
+					IsDisposal: true,
+					MaterialId: material_log.Disposal.MaterialId,
+					EntryId:    material_log.Disposal.EntryId,
+					Name:       material_costs[material_log.Disposal.MaterialId].Name,
+					OrderId:    material_log.Disposal.OrderId,
+					Reason:     material_log.Disposal.Comment,
+					UserId:     material_log.UserId,
+					Quantity:   material_log.Disposal.Quantity,
+					Value:      value,
+				})
+				continue
+			}
+
+			var product_log models.LogDisposalProductAdd
+			if err := cursor.Decode(&product_log); err != nil {
+				return records, err
+			}
+
+			records = append(records, models.WasteRecord{
+				Date:       product_log.Date,
+				Source:     models.WasteSourceProductDisposal,
+				IsDisposal: true,
+				ProductId:  product_log.Disposal.Item.Product.Id,
+				Name:       product_log.Disposal.Item.Product.Name,
+				OrderId:    product_log.Disposal.OrderId,
+				Reason:     product_log.Disposal.Comment,
+				UserId:     product_log.UserId,
+				Quantity:   product_log.Disposal.Quantity,
+				Value:      product_log.Disposal.Quantity * itemUnitCost(product_log.Disposal.Item),
+			})
+		}
+	}
+
+	return records, cursor.Err()
+}
+
+const (
+	WasteGroupByMaterial = "material"
+	WasteGroupByProduct  = "product"
+	WasteGroupByReason   = "reason"
+	WasteGroupByUser     = "user"
+	WasteGroupBySource   = "source"
+	WasteGroupByDay      = "day"
+	WasteGroupByWeek     = "week"
+	WasteGroupByMonth    = "month"
+)
+
+var ErrInvalidWasteGroupBy = errors.New("group_by must be one of material, product, reason, user, source, day, week or month")
+
+// isWastePeriod tells whether the grouping is a period of time rather than a dimension of the waste.
+func isWastePeriod(group_by string) bool {
+	return group_by == WasteGroupByDay || group_by == WasteGroupByWeek || group_by == WasteGroupByMonth
+}
+
+// wasteGroupKey returns the key and the display name of the group the record belongs to.
+func wasteGroupKey(group_by string, record models.WasteRecord, business_day BusinessDay) (key string, name string) {
+	switch group_by {
+	case WasteGroupByMaterial:
+		return record.MaterialId, record.Name
+	case WasteGroupByProduct:
+		return record.ProductId, record.Name
+	case WasteGroupByReason:
+		return record.Reason, record.Reason
+	case WasteGroupByUser:
+		return record.UserId, record.UserId
+	case WasteGroupBySource:
+		return record.Source, record.Source
+	}
+
+	day, _ := time.ParseInLocation("2006-01-02", business_day.Of(record.Date), business_day.Location)
+
+	switch group_by {
+	case WasteGroupByWeek:
+		year, week := day.ISOWeek()
+		key = fmt.Sprintf("%d-W%02d", year, week)
+	case WasteGroupByMonth:
+		key = day.Format("2006-01")
+	default:
+		key = day.Format("2006-01-02")
+	}
+
+	return key, key
+}
+
+// changePercent returns the change from previous to current in percent, nil when there is nothing to compare to.
+func changePercent(previous float64, current float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+
+	change := math.Round((current-previous)/previous*10000) / 100
+	return &change
+}
+
+// GetWasteAnalytics returns the waste and disposals between from and to grouped by material, product, reason,
+// user, source or period, see the WasteGroupBy constants. Each group is compared to the period of the same length
+// right before from, the groups are ordered by value and cut to the top ones unless top is 0. Period groups are
+// ordered chronologically and never cut.
+func (ws *WasteService) GetWasteAnalytics(group_by string, from time.Time, to time.Time, top int) (analytics models.WasteAnalytics, err error) {
+	switch group_by {
+	case WasteGroupByMaterial, WasteGroupByProduct, WasteGroupByReason, WasteGroupByUser, WasteGroupBySource, WasteGroupByDay, WasteGroupByWeek, WasteGroupByMonth:
+	default:
+		return analytics, ErrInvalidWasteGroupBy
+	}
+
+	business_day, err := NewBusinessDay(ws.Config)
+	if err != nil {
+		return analytics, err
+	}
+
+	previous_from := from.Add(-to.Sub(from))
+
+	analytics = models.WasteAnalytics{
+		From:         from,
+		To:           to,
+		PreviousFrom: previous_from,
+		Groups:       make([]models.WasteAnalyticsGroup, 0),
+	}
+
+	records, err := ws.GetWasteRecords(previous_from, to)
+	if err != nil {
+		return analytics, err
+	}
+
+	indexes := make(map[string]int)
+
+	for _, record := range records {
+		is_previous := record.Date.Before(from)
+
+		if is_previous {
+			analytics.PreviousTotalValue += record.Value
+		} else {
+			analytics.Count++
+			analytics.TotalValue += record.Value
+
+			if record.IsDisposal {
+				analytics.DisposalValue += record.Value
+			} else {
+				analytics.WasteValue += record.Value
+			}
+		}
+
+		// the prior period has no groups of its own when grouping by period
+		if is_previous && isWastePeriod(group_by) {
+			continue
+		}
+
+		if (group_by == WasteGroupByMaterial && record.MaterialId == "") || (group_by == WasteGroupByProduct && record.ProductId == "") {
+			continue
+		}
+
+		key, name := wasteGroupKey(group_by, record, business_day)
+
+		index, ok := indexes[key]
+		if !ok {
+			index = len(analytics.Groups)
+			indexes[key] = index
+			analytics.Groups = append(analytics.Groups, models.WasteAnalyticsGroup{Key: key, Name: name})
+		}
+
+		group := &analytics.Groups[index]
+		if group.Name == "" {
+			group.Name = name
+		}
+
+		if is_previous {
+			group.PreviousCount++
+			group.PreviousQuantity += record.Quantity
+			group.PreviousValue += record.Value
+		} else {
+			group.Count++
+			group.Quantity += record.Quantity
+			group.Value += record.Value
+		}
+	}
+
+	analytics.ValueChangePercent = changePercent(analytics.PreviousTotalValue, analytics.TotalValue)
+
+	for index := range analytics.Groups {
+		group := &analytics.Groups[index]
+		group.ValueChangePercent = changePercent(group.PreviousValue, group.Value)
+	}
+
+	if isWastePeriod(group_by) {
+		sort.SliceStable(analytics.Groups, func(i, j int) bool {
+			return analytics.Groups[i].Key < analytics.Groups[j].Key
+		})
+
+		return analytics, nil
+	}
+
+	sort.SliceStable(analytics.Groups, func(i, j int) bool {
+		return analytics.Groups[i].Value > analytics.Groups[j].Value
+	})
+
+	if top > 0 && len(analytics.Groups) > top {
+		analytics.Groups = analytics.Groups[:top]
+	}
+
+	return analytics, nil
+}